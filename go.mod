@@ -6,4 +6,6 @@ require (
 	github.com/apparentlymart/go-userdirs v0.0.0-20190512014041-4a23807e62b9
 	github.com/hashicorp/hcl2 v0.0.0-20190515223218-4b22149b7cef
 	github.com/spf13/cobra v0.0.4
+	github.com/zclconf/go-cty v0.0.0-20190426224007-b18a157db9e2
+	golang.org/x/crypto v0.0.0-20190426145343-a29dc8fdc734
 )
@@ -0,0 +1,14 @@
+package addrs
+
+// Args is the address of the arguments given on the command line when
+// running a command.
+//
+// There is only one Args address, because the arguments belong to whichever
+// command is being run.
+type Args struct{}
+
+func (a Args) isReference() {} // marker for interface Referenceable
+
+func (a Args) String() string {
+	return "args"
+}
@@ -0,0 +1,14 @@
+package addrs
+
+// AutoEnv is the address of the auto_env block of a configuration, which
+// decides the environment variables that the shell hook sets automatically.
+//
+// There is only one AutoEnv address, because a configuration can have at
+// most one auto_env block.
+type AutoEnv struct{}
+
+func (a AutoEnv) isReference() {} // marker for interface Referenceable
+
+func (a AutoEnv) String() string {
+	return "auto_env"
+}
@@ -6,6 +6,10 @@ import (
 
 // Helper identifies a particular helper defined in the configuration.
 type Helper struct {
+	// Module is the name of the module that declared the helper, or the
+	// empty string for a helper declared directly in the configuration.
+	Module string
+
 	Type string
 	Name string
 }
@@ -19,8 +23,21 @@ func MakeHelper(typeName, name string) Helper {
 	return Helper{Type: typeName, Name: name}
 }
 
+// InModule returns a copy of the address that belongs to the module with
+// the given name.
+//
+// It will panic if the module name is not a valid identifier.
+func (h Helper) InModule(module string) Helper {
+	assertValidName(module)
+	h.Module = module
+	return h
+}
+
 func (h Helper) isReference() {} // marker for interface Referenceable
 
 func (h Helper) String() string {
+	if h.Module != "" {
+		return fmt.Sprintf("module.%s.%s.%s", h.Module, h.Type, h.Name)
+	}
 	return fmt.Sprintf("%s.%s", h.Type, h.Name)
 }
@@ -0,0 +1,14 @@
+package addrs
+
+// Profile is the address of the profile selected for a run, whose attributes
+// describe the profile.
+//
+// There is only one Profile address, because only one profile can be
+// selected at a time.
+type Profile struct{}
+
+func (p Profile) isReference() {} // marker for interface Referenceable
+
+func (p Profile) String() string {
+	return "profile"
+}
@@ -0,0 +1,20 @@
+package addrs
+
+// Variable identifies an input variable declared in the envy configuration.
+type Variable struct {
+	Name string
+}
+
+// MakeVariable returns a Variable address for the given name.
+//
+// It will panic if the given name is not a valid identifier.
+func MakeVariable(name string) Variable {
+	assertValidName(name)
+	return Variable{Name: name}
+}
+
+func (v Variable) isReference() {} // marker for interface Referenceable
+
+func (v Variable) String() string {
+	return "var." + v.Name
+}
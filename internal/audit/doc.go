@@ -0,0 +1,8 @@
+// Package audit implements the append-only log in which envy records each
+// run of a command, so that users can later find out which commands used
+// which helpers, and when.
+//
+// The log is a file of JSON records, one per line. It records only the
+// addresses of objects and hashes of arguments, never any values that a
+// command or helper might consider sensitive.
+package audit // import "envy.pw/cli/internal/audit"
@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ActionEnv is the Record.Action for a use of a command that only printed
+// the environment variables it would set, as "envy env" does, which still
+// obtains the same credentials as running it would.
+const ActionEnv = "env"
+
+// ActionHook is the Record.Action for an evaluation of a configuration's
+// auto_env block by the shell hook, which exports the environment variables
+// it sets into the user's shell. The record's Command is then "auto_env".
+const ActionHook = "hook"
+
+// Record is a single entry in the audit log, describing one run of a
+// command.
+type Record struct {
+	// Start and End are when envy began preparing the run and when the run
+	// finished, respectively.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+
+	// Command is the address of the command that was run, such as
+	// "command.deploy", or "auto_env" for the shell hook.
+	Command string `json:"command"`
+
+	// Action is how the command was used, which is empty if it was run or
+	// otherwise one of the Action constants.
+	Action string `json:"action,omitempty"`
+
+	// ArgsHash is the result of HashArgs for the command line arguments
+	// given for the command, which can show whether two runs had the same
+	// arguments without revealing what they were.
+	ArgsHash string `json:"args_sha256"`
+
+	WorkingDir string `json:"working_dir"`
+	Profile    string `json:"profile,omitempty"`
+
+	// Helpers are the helpers that were evaluated during the run, in
+	// dependency order.
+	Helpers []Helper `json:"helpers"`
+
+	// PIDs are the process IDs of the child processes launched for the
+	// run, in the order they were launched. There is more than one if the
+	// child was restarted.
+	PIDs []int `json:"pids"`
+
+	// Status is the status that envy exited with at the end of the run.
+	Status int `json:"status"`
+}
+
+// Helper describes a helper that was evaluated during a run.
+type Helper struct {
+	// Addr is the address of the helper, such as "vault.db".
+	Addr string `json:"addr"`
+
+	// Cache is CacheHit or CacheMiss for helpers that can reuse something
+	// cached by an earlier run of envy, and empty for all others.
+	Cache string `json:"cache,omitempty"`
+}
+
+// The values of Helper.Cache.
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// HashArgs returns a hex-encoded SHA-256 hash of the given command line
+// arguments.
+func HashArgs(args []string) string {
+	h := sha256.New()
+	for _, arg := range args {
+		// Each argument is terminated by a zero byte, which can't appear
+		// inside it, so that different splits of the same text don't
+		// produce the same hash.
+		h.Write([]byte(arg))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Log is an audit log file.
+type Log struct {
+	path string
+}
+
+// NewLog returns the audit log whose records are kept in the file at the
+// given path, which is created when the first record is appended.
+func NewLog(path string) *Log {
+	return &Log{path: path}
+}
+
+// Path returns the path of the file containing the log's records.
+func (l *Log) Path() string {
+	return l.path
+}
+
+// Append adds the given record to the end of the log.
+//
+// Each record is written with a single write to a file opened in append
+// mode, so concurrent runs of envy don't interleave their records.
+func (l *Log) Append(rec *Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter selects records from the log. The zero value selects all of them.
+type Filter struct {
+	// Command, if set, selects only the runs of the command with this
+	// address.
+	Command string
+
+	// Helper, if set, selects only the runs that evaluated the helper with
+	// this address.
+	Helper string
+
+	// Since, if not zero, selects only the runs that ended at or after
+	// this time.
+	Since time.Time
+}
+
+func (f *Filter) matches(rec *Record) bool {
+	if f.Command != "" && rec.Command != f.Command {
+		return false
+	}
+	if !f.Since.IsZero() && rec.End.Before(f.Since) {
+		return false
+	}
+	if f.Helper != "" {
+		for _, h := range rec.Helpers {
+			if h.Addr == f.Helper {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// Query returns the records in the log that match the given filter, oldest
+// first. If the log file doesn't exist yet then the result is empty.
+func (l *Log) Query(f *Filter) ([]*Record, error) {
+	file, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var recs []*Record
+	sc := bufio.NewScanner(file)
+	sc.Buffer(nil, 1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		rec := &Record{}
+		if err := json.Unmarshal(sc.Bytes(), rec); err != nil {
+			return nil, fmt.Errorf("invalid record on line %d of %s: %s", line, l.path, err)
+		}
+		if f.matches(rec) {
+			recs = append(recs, rec)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return recs, nil
+}
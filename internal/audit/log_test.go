@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log := NewLog(filepath.Join(dir, "nested", "audit.log"))
+
+	recs, err := log.Query(&Filter{})
+	if err != nil {
+		t.Fatalf("unexpected error querying missing log: %s", err)
+	}
+	if len(recs) != 0 {
+		t.Fatalf("wrong number of records %d in missing log; want 0", len(recs))
+	}
+
+	base := time.Date(2019, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, rec := range []*Record{
+		{
+			End:     base,
+			Command: "command.deploy",
+			Helpers: []Helper{{Addr: "vault.db", Cache: CacheMiss}},
+			PIDs:    []int{100},
+		},
+		{
+			End:     base.Add(time.Hour),
+			Command: "command.deploy",
+			PIDs:    []int{200, 201},
+			Status:  1,
+		},
+		{
+			End:     base.Add(2 * time.Hour),
+			Command: "command.console",
+			Action:  ActionEnv,
+			Helpers: []Helper{{Addr: "vault.db"}},
+		},
+	} {
+		if err := log.Append(rec); err != nil {
+			t.Fatalf("unexpected error appending record: %s", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []int // the End times of the records, as hours after base
+	}{
+		{"all", Filter{}, []int{0, 1, 2}},
+		{"command", Filter{Command: "command.deploy"}, []int{0, 1}},
+		{"helper", Filter{Helper: "vault.db"}, []int{0, 2}},
+		{"since", Filter{Since: base.Add(time.Hour)}, []int{1, 2}},
+		{"combined", Filter{Command: "command.deploy", Helper: "vault.db", Since: base.Add(time.Minute)}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recs, err := log.Query(&test.filter)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			var got []int
+			for _, rec := range recs {
+				got = append(got, int(rec.End.Sub(base)/time.Hour))
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("wrong records %#v; want %#v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("wrong records %#v; want %#v", got, test.want)
+					break
+				}
+			}
+		})
+	}
+
+	t.Run("fields", func(t *testing.T) {
+		recs, err := log.Query(&Filter{})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		rec := recs[1]
+		if got, want := len(rec.PIDs), 2; got != want {
+			t.Fatalf("wrong number of pids %d; want %d", got, want)
+		}
+		if got, want := rec.Status, 1; got != want {
+			t.Errorf("wrong status %d; want %d", got, want)
+		}
+		if got, want := recs[0].Helpers[0].Cache, CacheMiss; got != want {
+			t.Errorf("wrong cache result %q; want %q", got, want)
+		}
+		if got, want := recs[0].Action, ""; got != want {
+			t.Errorf("wrong action %q for a run; want %q", got, want)
+		}
+		if got, want := recs[2].Action, ActionEnv; got != want {
+			t.Errorf("wrong action %q; want %q", got, want)
+		}
+	})
+}
+
+func TestHashArgs(t *testing.T) {
+	if HashArgs([]string{"ab", "c"}) == HashArgs([]string{"a", "bc"}) {
+		t.Errorf("different arguments have the same hash")
+	}
+	if got, want := HashArgs([]string{"plan"}), HashArgs([]string{"plan"}); got != want {
+		t.Errorf("same arguments have different hashes %s and %s", got, want)
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"envy.pw/cli/internal/audit"
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// auditCommand is a command for querying the audit log of command runs.
+type auditCommand struct {
+	Context *RunContext
+	Command string
+	Helper  string
+	Since   string
+	JSON    bool
+}
+
+func (c *auditCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	filter := &audit.Filter{
+		Command: c.Command,
+		Helper:  c.Helper,
+	}
+	if filter.Command != "" && !strings.HasPrefix(filter.Command, "command.") {
+		filter.Command = "command." + filter.Command
+	}
+	if c.Since != "" {
+		since, err := parseSince(c.Since, time.Now())
+		if err != nil {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Invalid --since option",
+				fmt.Sprintf("The --since option must be either a duration like \"24h\" or a time like \"2019-06-01T12:00:00Z\": %s.", err),
+			))
+			return 1, diags
+		}
+		filter.Since = since
+	}
+
+	log := audit.NewLog(c.Context.AuditLogPath())
+	recs, err := log.Query(filter)
+	if err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to read audit log",
+			fmt.Sprintf("Could not read the audit log %s: %s.", log.Path(), err),
+		))
+		return 1, diags
+	}
+
+	if c.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, rec := range recs {
+			enc.Encode(rec)
+		}
+		return 0, diags
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "START\tCOMMAND\tSTATUS\tPIDS\tHELPERS")
+	for _, rec := range recs {
+		pids := make([]string, len(rec.PIDs))
+		for i, pid := range rec.PIDs {
+			pids[i] = fmt.Sprint(pid)
+		}
+		helpers := make([]string, len(rec.Helpers))
+		for i, h := range rec.Helpers {
+			helpers[i] = h.Addr
+			if h.Cache != "" {
+				helpers[i] += " (cache " + h.Cache + ")"
+			}
+		}
+		command := rec.Command
+		if rec.Action != "" {
+			command += " (" + rec.Action + ")"
+		}
+		fmt.Fprintf(
+			w, "%s\t%s\t%d\t%s\t%s\n",
+			rec.Start.Local().Format(time.RFC3339),
+			command,
+			rec.Status,
+			orNone(strings.Join(pids, ",")),
+			orNone(strings.Join(helpers, ", ")),
+		)
+	}
+	w.Flush()
+	return 0, diags
+}
+
+// parseSince parses the argument of the --since option, which is either a
+// duration before now or an RFC 3339 timestamp.
+func parseSince(raw string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(raw); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/runs"
+)
+
+// prepareCommandCall does the work shared by all of the subcommands that
+// evaluate a configured command: it validates the command name at the
+// start of the given arguments, loads the configuration with the given
+// profile applied, and creates a runner and a call for the command.
+//
+// The arguments after the command name may start with options setting
+// input variables, as described for splitVariableArgs.
+func (c *RunContext) prepareCommandCall(args []string, profile string) (*runs.Runner, *runs.CommandCall, *configs.Config, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	cmdName := args[0]
+	if !addrs.ValidName(cmdName) {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Invalid command name",
+			fmt.Sprintf("The name %q is not a valid name for a command.", cmdName),
+		))
+		return nil, nil, nil, diags
+	}
+
+	cfg, moreDiags := c.LoadConfig()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, nil, nil, diags
+	}
+
+	if profile == "" {
+		profile = configs.DefaultProfileName
+	}
+	cfg, hclDiags := cfg.WithProfile(profile)
+	diags = diags.Append(hclDiags)
+	if hclDiags.HasErrors() {
+		return nil, nil, nil, diags
+	}
+
+	runner, moreDiags := c.NewRunner()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, nil, nil, diags
+	}
+
+	vars, args, moreDiags := splitVariableArgs(args[1:])
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, nil, nil, diags
+	}
+
+	call := &runs.CommandCall{
+		Addr:       addrs.MakeCommand(cmdName),
+		Args:       args,
+		Environ:    os.Environ(),
+		Variables:  vars,
+		Profile:    profile,
+		WorkingDir: c.WorkingDir,
+	}
+	return runner, call, cfg, diags
+}
@@ -17,6 +17,8 @@ func Execute() {
 	var ctx *RunContext
 	var configDir string
 	var workingDir string
+	var logLevel string
+	var requireTrust bool
 
 	type Command interface {
 		Run() (int, nvdiags.Diagnostics)
@@ -29,12 +31,23 @@ func Execute() {
 		Long:  `A command launcher that can generate and provide dynamic credentials and other data to programs that need them.`,
 		Args:  cobra.NoArgs,
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// Logging must be configured before we change directory, so
+			// that a relative ENVY_LOG_PATH is relative to where envy was
+			// launched.
+			if err := configureLogging(logLevel); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+				os.Exit(1)
+			}
+
 			var err error
 			ctx, err = newRunContext(configDir, workingDir)
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
 				os.Exit(1)
 			}
+			if requireTrust {
+				ctx.RequireTrust = true
+			}
 
 			// We'll switch to the config directory as our working directory
 			// so that paths in the config are config-relative.
@@ -50,21 +63,326 @@ func Execute() {
 	}
 	rootCmd.Flags().StringVarP(&configDir, "config-dir", "c", "", "directory to search for configuration files")
 	rootCmd.Flags().StringVarP(&workingDir, "working-dir", "w", "", "directory to use as the working directory when running commands")
+	rootCmd.Flags().BoolVar(&requireTrust, "require-trust", false, "refuse to load project configuration that hasn't been trusted with \"envy trust\" (default $ENVY_REQUIRE_TRUST)")
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "", "log verbosity (trace, debug or info), overriding ENVY_LOG")
 
+	var runProfile string
 	var runCmd = &cobra.Command{
-		Use:   "run <command-name> [args...]",
+		Use:   "run [--profile NAME] <command-name> [--var-NAME=VALUE...] [args...]",
 		Short: "Run a configured command",
 		Args:  cobra.MinimumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			command = &runCommand{
 				Context: ctx,
 				Args:    args,
+				Profile: runProfile,
 			}
 		},
 	}
+	runCmd.Flags().StringVar(&runProfile, "profile", "", "name of the configuration profile to use")
 	runCmd.Flags().SetInterspersed(false) // Everything after the command name appaers in "args", including flag-like strings
 	rootCmd.AddCommand(runCmd)
 
+	var showProfile string
+	var showCmd = &cobra.Command{
+		Use:   "show [--profile NAME] <command-name> [--var-NAME=VALUE...] [args...]",
+		Short: "Show what a configured command would run, hiding sensitive values",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &showCommand{
+				Context: ctx,
+				Args:    args,
+				Profile: showProfile,
+			}
+		},
+	}
+	showCmd.Flags().StringVar(&showProfile, "profile", "", "name of the configuration profile to use")
+	showCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(showCmd)
+
+	var envProfile, envFormat string
+	var envCmd = &cobra.Command{
+		Use:   "env [--profile NAME] [--format FORMAT] <command-name> [--var-NAME=VALUE...] [args...]",
+		Short: "Print the environment variables that a configured command would set",
+		Long: `Print the environment variables that a configured command would set, without
+running the command.
+
+The supported formats are "dotenv", "posix" (export commands for eval in a
+POSIX shell), "fish" (set commands for eval in fish), and "json".
+
+Sensitive values are printed verbatim, so take care where the output goes.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &envCommand{
+				Context: ctx,
+				Args:    args,
+				Profile: envProfile,
+				Format:  envFormat,
+			}
+		},
+	}
+	envCmd.Flags().StringVar(&envProfile, "profile", "", "name of the configuration profile to use")
+	envCmd.Flags().StringVar(&envFormat, "format", "dotenv", "output format: dotenv, posix, fish or json")
+	envCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(envCmd)
+
+	var shellProfile string
+	var shellCmd = &cobra.Command{
+		Use:   "shell [--profile NAME] <command-name> [--var-NAME=VALUE...]",
+		Short: "Launch an interactive shell with a configured command's environment",
+		Long: `Launch your shell, as given by $SHELL, with the environment that a configured
+command would have, including any helpers it depends on, which keep running
+until the shell exits.
+
+The shell's prompt is marked with the command name, which is also available
+to scripts in the ENVY_SHELL environment variable.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &shellCommand{
+				Context: ctx,
+				Args:    args,
+				Profile: shellProfile,
+			}
+		},
+	}
+	shellCmd.Flags().StringVar(&shellProfile, "profile", "", "name of the configuration profile to use")
+	shellCmd.Flags().SetInterspersed(false)
+	rootCmd.AddCommand(shellCmd)
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "hook <bash|zsh|fish>",
+		Short: "Print shell code that exports a project's auto_env environment at each prompt",
+		Long: `Print shell code that installs a prompt hook, which exports the environment
+variables from the auto_env block of the project configuration for the
+current directory, and restores the values they had before, or removes them,
+when leaving the project.
+
+To install the hook, add one of the following to your shell's startup file:
+
+    eval "$(envy hook bash)"     # ~/.bashrc
+    eval "$(envy hook zsh)"      # ~/.zshrc
+    envy hook fish | source      # ~/.config/fish/config.fish
+
+The hook only evaluates project configuration directories that you have
+trusted with "envy trust", because otherwise changing into a directory could
+run whatever its configuration says. For any other directory, it prints a
+reminder instead.
+
+The hook remembers the environment until the configuration files change or
+the auto_env block's cache period elapses, so most prompts don't need to
+load the configuration at all. Each time it does evaluate the block, it
+records that in the audit log shown by "envy audit".`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh", "fish"},
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &hookCommand{
+				Shell: args[0],
+			}
+		},
+	})
+	rootCmd.AddCommand(&cobra.Command{
+		Use:    "hook-eval <bash|zsh|fish>",
+		Short:  "Print shell code to update the auto_env environment for the current directory",
+		Args:   cobra.ExactArgs(1),
+		Hidden: true, // only for use by the code that "envy hook" prints
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &hookEvalCommand{
+				Context: ctx,
+				Shell:   args[0],
+			}
+		},
+	})
+
+	var fmtCheck, fmtDiff bool
+	var fmtCmd = &cobra.Command{
+		Use:   "fmt [--check] [--diff] [PATH...]",
+		Short: "Rewrite configuration files in the canonical format",
+		Long: `Rewrite configuration files in the canonical format for the HCL native
+syntax, printing the name of each file that changed.
+
+Each PATH is either a configuration file or a directory whose configuration
+files are to be formatted. By default, the files in all of the configuration
+directories that Envy would load are formatted. JSON configuration files are
+left unchanged.
+
+With --check, no files are changed, and the exit status is 3 if any files
+aren't formatted. With --diff, the changes are printed as a unified diff
+instead of printing the file names.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &fmtCommand{
+				Context: ctx,
+				Paths:   args,
+				Check:   fmtCheck,
+				Diff:    fmtDiff,
+			}
+		},
+	}
+	fmtCmd.Flags().BoolVar(&fmtCheck, "check", false, "only check whether the files are formatted, without changing them")
+	fmtCmd.Flags().BoolVar(&fmtDiff, "diff", false, "print the changes as a unified diff")
+	rootCmd.AddCommand(fmtCmd)
+
+	var auditCmdName, auditHelper, auditSince string
+	var auditJSON bool
+	var auditCmd = &cobra.Command{
+		Use:   "audit [--command NAME] [--helper ADDR] [--since WHEN] [--json]",
+		Short: "Show the audit log of command runs",
+		Long: `Show the audit log, in which Envy records each run of a command: when it
+ran, the command's address, a hash of its arguments, the working directory,
+the helpers it evaluated and whether they reused anything cached by an
+earlier run, and the IDs and exit status of its child processes. Uses of
+"envy env", which obtain the same credentials without running the command,
+are recorded too, marked with "(env)", as are the shell hook's evaluations
+of a project's auto_env block, shown as "auto_env (hook)".
+
+The log never contains the values of arguments, helper results, or
+environment variables.
+
+The --since option accepts either a duration before now, like "24h", or a
+time like "2019-06-01T12:00:00Z". With --json, each matching record is
+printed as a line of JSON, exactly as it appears in the log.`,
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &auditCommand{
+				Context: ctx,
+				Command: auditCmdName,
+				Helper:  auditHelper,
+				Since:   auditSince,
+				JSON:    auditJSON,
+			}
+		},
+	}
+	auditCmd.Flags().StringVar(&auditCmdName, "command", "", "show only runs of the command with this name")
+	auditCmd.Flags().StringVar(&auditHelper, "helper", "", "show only runs that evaluated the helper with this address, such as vault.db")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "show only runs that ended after this duration ago or time")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "print the matching records as JSON lines")
+	rootCmd.AddCommand(auditCmd)
+
+	var configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manage project configuration directories",
+	}
+	var signKeyFile string
+	var configSignCmd = &cobra.Command{
+		Use:   "sign [--key-file PATH] [DIR]",
+		Short: "Sign a project configuration directory",
+		Long: `Sign the files in a project configuration directory with an ed25519 key,
+writing a detached signature to the file envy.sig in the directory.
+
+Users who have trusted the key with "envy trust --key" can then load the
+configuration when Envy requires trust, for as long as none of its files
+change. DIR defaults to the project configuration directory nearest the
+working directory.
+
+Unless --key-file is given, the key is read from signing.key in Envy's data
+directory, and is generated there the first time it's needed.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			c := &configSignCommand{
+				Context: ctx,
+				KeyFile: signKeyFile,
+			}
+			if len(args) != 0 {
+				c.Dir = args[0]
+			}
+			command = c
+		},
+	}
+	configSignCmd.Flags().StringVar(&signKeyFile, "key-file", "", "key file containing the ed25519 key to sign with")
+	configCmd.AddCommand(configSignCmd)
+	rootCmd.AddCommand(configCmd)
+
+	var trustKey string
+	var trustRemove bool
+	var trustCmd = &cobra.Command{
+		Use:   "trust [--remove] [DIR...] | trust [--remove] --key KEY",
+		Short: "Trust project configuration directories or signing keys",
+		Long: `Record that you trust project configuration directories, so that Envy will
+load them when run with --require-trust or with ENVY_REQUIRE_TRUST set.
+
+A trusted directory must be trusted again after any of its files change.
+Since trust only covers the files in the directory itself, the sources of
+its modules must also be within it. With no arguments, all of the project
+configuration directories found from the working directory are trusted.
+
+With --key, trust any directory with a valid signature by the given public
+key, as printed by "envy config sign", instead.
+
+With --remove, forget the given directories or key.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &trustCommand{
+				Context: ctx,
+				Dirs:    args,
+				Key:     trustKey,
+				Remove:  trustRemove,
+			}
+		},
+	}
+	trustCmd.Flags().StringVar(&trustKey, "key", "", "trust directories signed with this public key")
+	trustCmd.Flags().BoolVar(&trustRemove, "remove", false, "remove trust instead of adding it")
+	rootCmd.AddCommand(trustCmd)
+
+	var secretKeyFile string
+	var secretCmd = &cobra.Command{
+		Use:   "secret",
+		Short: "Manage the encrypted secret store",
+		Long: `Manage the encrypted store of secrets that Envy keeps on your behalf.
+
+Secrets in the store can be used in configuration via the "secret" helper type.
+The store is unlocked either with a passphrase, which Envy will prompt for, or
+with a key file containing 32 random bytes encoded as base64.`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			rootCmd.PersistentPreRun(cmd, args)
+			if secretKeyFile != "" {
+				ctx.SecretKeyFile = secretKeyFile
+			}
+		},
+	}
+	secretCmd.PersistentFlags().StringVar(&secretKeyFile, "key-file", "", "key file to unlock the secret store with, instead of a passphrase (default $ENVY_SECRET_KEY_FILE)")
+	secretCmd.AddCommand(&cobra.Command{
+		Use:   "set <name>",
+		Short: "Create or replace a secret, reading its value from stdin",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &secretSetCommand{
+				Context: ctx,
+				Name:    args[0],
+			}
+		},
+	})
+	secretCmd.AddCommand(&cobra.Command{
+		Use:   "get <name>",
+		Short: "Print the value of a secret",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &secretGetCommand{
+				Context: ctx,
+				Name:    args[0],
+			}
+		},
+	})
+	secretCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the names of all secrets",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &secretListCommand{
+				Context: ctx,
+			}
+		},
+	})
+	secretCmd.AddCommand(&cobra.Command{
+		Use:   "rm <name>",
+		Short: "Remove a secret",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			command = &secretRemoveCommand{
+				Context: ctx,
+				Name:    args[0],
+			}
+		},
+	})
+	rootCmd.AddCommand(secretCmd)
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
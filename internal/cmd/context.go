@@ -5,10 +5,21 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
+	"sync"
 
+	"envy.pw/cli/internal/audit"
 	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/helpers/awssts"
+	"envy.pw/cli/internal/helpers/oauth2"
+	"envy.pw/cli/internal/helpers/prompt"
+	"envy.pw/cli/internal/helpers/secret"
+	"envy.pw/cli/internal/helpers/template"
+	"envy.pw/cli/internal/helpers/vault"
 	"envy.pw/cli/internal/nvdiags"
 	"envy.pw/cli/internal/runs"
+	"envy.pw/cli/internal/secrets"
 
 	"github.com/apparentlymart/go-userdirs/userdirs"
 )
@@ -18,6 +29,24 @@ import (
 type RunContext struct {
 	ConfigDir  string
 	WorkingDir string
+
+	// DataDir is the directory where envy keeps data it manages on the
+	// user's behalf, such as the secret store and the audit log.
+	DataDir string
+
+	// SecretKeyFile, if set, is the path to a key file to use to unlock the
+	// secret store instead of prompting for a passphrase.
+	SecretKeyFile string
+
+	// RequireTrust, if set, makes LoadConfig refuse to load any project
+	// configuration directory that the user hasn't trusted, either directly
+	// or by trusting a key that the directory is signed with.
+	RequireTrust bool
+
+	// helperSecretStore is the secret store opened on behalf of helpers,
+	// shared between them so that the user is prompted at most once.
+	helperSecretStore *secrets.Store
+	helperSecretLock  sync.Mutex
 }
 
 func newRunContext(configDir, workingDir string) (*RunContext, error) {
@@ -33,6 +62,11 @@ func newRunContext(configDir, workingDir string) (*RunContext, error) {
 		// "not found" error downstream.
 		os.MkdirAll(configDir, 0700)
 	}
+	// The caller will switch into the configuration directory, so we need
+	// an absolute path to still be able to find it afterwards.
+	if abs, err := filepath.Abs(configDir); err == nil {
+		configDir = abs
+	}
 
 	if workingDir == "" {
 		wd, err := os.Getwd()
@@ -42,26 +76,105 @@ func newRunContext(configDir, workingDir string) (*RunContext, error) {
 		workingDir = wd
 	}
 
+	// An invalid value is treated as false, like an unset variable.
+	requireTrust, _ := strconv.ParseBool(os.Getenv("ENVY_REQUIRE_TRUST"))
+
 	return &RunContext{
-		ConfigDir:  configDir,
-		WorkingDir: workingDir,
+		ConfigDir:     configDir,
+		WorkingDir:    workingDir,
+		DataDir:       dirs.DataHome(),
+		SecretKeyFile: os.Getenv("ENVY_SECRET_KEY_FILE"),
+		RequireTrust:  requireTrust,
 	}, nil
 }
 
-// LoadConfig loads a configuration from the context's configuration directory.
+// LoadConfig loads a configuration from the context's configuration
+// directory, overlaid with any project configuration directories found by
+// searching upwards from the working directory.
+//
+// If RequireTrust is set then any project configuration directories that
+// the user hasn't trusted are not loaded, and are reported as errors, as
+// are any modules of the trusted ones whose sources are outside them.
 func (c *RunContext) LoadConfig() (*configs.Config, nvdiags.Diagnostics) {
+	return c.loadLayers(c.configLayers())
+}
+
+// loadLayers loads a configuration from the given layers, checking that
+// project layers are trusted if RequireTrust is set.
+func (c *RunContext) loadLayers(layers []*configs.Layer) (*configs.Config, nvdiags.Diagnostics) {
 	var diags nvdiags.Diagnostics
-	cfg, hclDiags := configs.LoadConfig(c.ConfigDir)
+	var check configs.LayerCheck
+	if c.RequireTrust {
+		check = c.checkLayerTrusted
+		confineProjectModules(layers)
+	}
+	cfg, hclDiags := configs.LoadCheckedLayeredConfig(check, layers...)
 	diags = diags.Append(hclDiags)
 	return cfg, diags
 }
 
+// configLayers returns the configuration layers that LoadConfig loads, in
+// order of increasing precedence.
+func (c *RunContext) configLayers() []*configs.Layer {
+	layers := []*configs.Layer{configs.UserLayer(c.ConfigDir)}
+	for _, layer := range configs.FindProjectLayers(c.WorkingDir) {
+		if layer.Dir == c.ConfigDir {
+			// If the user explicitly selected a project directory as
+			// the configuration directory, it's only one layer.
+			continue
+		}
+		layers = append(layers, layer)
+	}
+	return layers
+}
+
 // NewRunner creates a runner using the settings from the context.
 func (c *RunContext) NewRunner() (*runs.Runner, nvdiags.Diagnostics) {
 	// TODO: Eventually this should be doing a bunch more work to compute
 	// various other contextual information, such as a set of available
 	// provider plugins.
-	return runs.NewRunner(), nil
+	runner := runs.NewRunner(c.helperTypes())
+	runner.SetAuditLog(audit.NewLog(c.AuditLogPath()))
+	return runner, nil
+}
+
+// AuditLogPath returns the path of the file containing the audit log of
+// command runs.
+func (c *RunContext) AuditLogPath() string {
+	return filepath.Join(c.DataDir, "audit.log")
+}
+
+// helperTypes returns the helper types that are available for this run.
+func (c *RunContext) helperTypes() helpers.Types {
+	return helpers.Types{
+		"aws_assume_role": awssts.NewType(),
+		"prompt":          prompt.NewType(promptTerminal),
+		"oauth2": oauth2.NewType(func() (*secrets.Store, error) {
+			return c.helperSecrets(true)
+		}),
+		"secret": secret.NewType(func() (*secrets.Store, error) {
+			return c.helperSecrets(false)
+		}),
+		"template": template.NewType(),
+		"vault":    vault.NewType(),
+	}
+}
+
+// helperSecrets opens the secret store for use by helpers, or returns the
+// store that was already opened for an earlier helper.
+//
+// If create is true and the store doesn't exist yet, it will be created.
+func (c *RunContext) helperSecrets(create bool) (*secrets.Store, error) {
+	c.helperSecretLock.Lock()
+	defer c.helperSecretLock.Unlock()
+	if c.helperSecretStore == nil {
+		store, err := c.OpenSecretStore(create)
+		if err != nil {
+			return nil, err
+		}
+		c.helperSecretStore = store
+	}
+	return c.helperSecretStore, nil
 }
 
 func supportedOS() bool {
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// envCommand is a command for printing the environment variables that a
+// command would set, in a format that other programs can consume.
+type envCommand struct {
+	Context *RunContext
+	Args    []string
+	Profile string
+	Format  string
+}
+
+// envFormats are the functions that write environment variables in each of
+// the formats supported by envCommand.
+var envFormats = map[string]func(w io.Writer, keys []string, env map[string]string) error{
+	"dotenv": writeDotenv,
+	"posix":  writePOSIXExports,
+	"fish":   writeFishExports,
+	"json":   writeJSONEnv,
+}
+
+func (c *envCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	write, ok := envFormats[c.Format]
+	if !ok {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Invalid output format",
+			fmt.Sprintf("There is no output format %q. The supported formats are \"dotenv\", \"posix\", \"fish\", and \"json\".", c.Format),
+		))
+		return 1, diags
+	}
+
+	runner, call, cfg, moreDiags := c.Context.prepareCommandCall(c.Args, c.Profile)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	env, moreDiags := runner.CommandEnvironment(context.Background(), call, cfg)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	if err := write(os.Stdout, keys, env); err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to write environment",
+			fmt.Sprintf("Could not write the environment variables: %s.", err),
+		))
+		return 1, diags
+	}
+	return 0, diags
+}
+
+// validEnvName matches the environment variable names that the shell and
+// .env formats can represent, which are written without any quoting.
+var validEnvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkEnvNames returns an error if any of the given environment variable
+// names doesn't match validEnvName, so that a malicious name can't inject
+// commands into the output.
+func checkEnvNames(keys []string) error {
+	for _, k := range keys {
+		if !validEnvName.MatchString(k) {
+			return fmt.Errorf("invalid environment variable name %q", k)
+		}
+	}
+	return nil
+}
+
+// writeDotenv writes the given environment variables in the ".env" file
+// format understood by many tools, with every value in double quotes.
+func writeDotenv(w io.Writer, keys []string, env map[string]string) error {
+	if err := checkEnvNames(keys); err != nil {
+		return err
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, `$`, `\$`)
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "%s=\"%s\"\n", k, r.Replace(env[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePOSIXExports writes the given environment variables as "export"
+// commands for a POSIX shell, suitable for use with "eval".
+func writePOSIXExports(w io.Writer, keys []string, env map[string]string) error {
+	if err := checkEnvNames(keys); err != nil {
+		return err
+	}
+	r := strings.NewReplacer(`'`, `'\''`)
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "export %s='%s'\n", k, r.Replace(env[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFishExports writes the given environment variables as "set" commands
+// for the fish shell, suitable for use with "eval".
+func writeFishExports(w io.Writer, keys []string, env map[string]string) error {
+	if err := checkEnvNames(keys); err != nil {
+		return err
+	}
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "set -gx %s '%s';\n", k, r.Replace(env[k])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeJSONEnv writes the given environment variables as a JSON object.
+func writeJSONEnv(w io.Writer, keys []string, env map[string]string) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(env)
+}
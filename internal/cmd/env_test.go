@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestEnvFormats(t *testing.T) {
+	keys := []string{"EMPTY", "PLAIN", "QUOTES", "SPECIAL"}
+	env := map[string]string{
+		"EMPTY":   "",
+		"PLAIN":   "hello world",
+		"QUOTES":  `it's "quoted"`,
+		"SPECIAL": "$HOME \\ `x`\nline",
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			"dotenv",
+			`EMPTY=""` + "\n" +
+				`PLAIN="hello world"` + "\n" +
+				`QUOTES="it's \"quoted\""` + "\n" +
+				`SPECIAL="\$HOME \\ ` + "`x`" + `\nline"` + "\n",
+		},
+		{
+			"posix",
+			"export EMPTY=''\n" +
+				"export PLAIN='hello world'\n" +
+				"export QUOTES='it'\\''s \"quoted\"'\n" +
+				"export SPECIAL='$HOME \\ `x`\nline'\n",
+		},
+		{
+			"fish",
+			"set -gx EMPTY '';\n" +
+				"set -gx PLAIN 'hello world';\n" +
+				"set -gx QUOTES 'it\\'s \"quoted\"';\n" +
+				"set -gx SPECIAL '$HOME \\\\ `x`\nline';\n",
+		},
+		{
+			"json",
+			"{\n" +
+				`  "EMPTY": "",` + "\n" +
+				`  "PLAIN": "hello world",` + "\n" +
+				`  "QUOTES": "it's \"quoted\"",` + "\n" +
+				`  "SPECIAL": "$HOME \\ ` + "`x`" + `\nline"` + "\n" +
+				"}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := envFormats[test.format](&buf, keys, env); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEnvFormatsPOSIXShell(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("no POSIX shell available")
+	}
+	keys := []string{"A", "B"}
+	env := map[string]string{
+		"A": `it's $(echo "not") run`,
+		"B": "two\nlines",
+	}
+	var script bytes.Buffer
+	if err := writePOSIXExports(&script, keys, env); err != nil {
+		t.Fatal(err)
+	}
+	script.WriteString(`printf '%s|%s' "$A" "$B"`)
+
+	got, err := exec.Command(sh, "-c", script.String()).Output()
+	if err != nil {
+		t.Fatalf("shell failed: %s", err)
+	}
+	if want := env["A"] + "|" + env["B"]; string(got) != want {
+		t.Errorf("wrong values in shell %q; want %q", got, want)
+	}
+}
+
+func TestEnvFormatsInvalidName(t *testing.T) {
+	writers := map[string]func(w io.Writer, keys []string) error{
+		"posix unset": writePOSIXUnsets,
+		"fish unset":  writeFishUnsets,
+	}
+	for name, write := range envFormats {
+		if name == "json" {
+			continue // JSON can represent any name
+		}
+		write := write
+		writers[name] = func(w io.Writer, keys []string) error {
+			env := make(map[string]string, len(keys))
+			for _, k := range keys {
+				env[k] = "value"
+			}
+			return write(w, keys, env)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"1ST",
+		"A B",
+		"A;rm -rf ~",
+		"$(id)",
+		"A=B",
+		"NAME\n",
+	}
+	for name, write := range writers {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(&buf, []string{"_OK", "Ok_2"}); err != nil {
+				t.Errorf("unexpected error for valid names: %s", err)
+			}
+			for _, key := range invalid {
+				buf.Reset()
+				err := write(&buf, []string{"VALID", key})
+				if err == nil || !strings.Contains(err.Error(), "invalid environment variable name") {
+					t.Errorf("wrong error for %q: %v", key, err)
+				}
+				if buf.Len() != 0 {
+					t.Errorf("wrote output for %q despite the invalid name:\n%s", key, buf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUnsetFormats(t *testing.T) {
+	keys := []string{"A", "B_2"}
+	tests := []struct {
+		name  string
+		write func(w io.Writer, keys []string) error
+		want  string
+	}{
+		{"posix", writePOSIXUnsets, "unset A\nunset B_2\n"},
+		{"fish", writeFishUnsets, "set -e A;\nset -e B_2;\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := test.write(&buf, keys); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := buf.String(); got != test.want {
+				t.Errorf("wrong output\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
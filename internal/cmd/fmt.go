@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/textdiff"
+)
+
+// fmtCommand is a command for rewriting configuration files in the
+// canonical format.
+type fmtCommand struct {
+	Context *RunContext
+	Paths   []string
+	Check   bool
+	Diff    bool
+}
+
+func (c *fmtCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	files, moreDiags := c.configFiles()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	unformatted := false
+	for _, path := range files {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			diags = diags.Append(fmtFileError(path, err))
+			continue
+		}
+		formatted, hclDiags := configs.FormatFile(path, src)
+		diags = diags.Append(hclDiags)
+		if hclDiags.HasErrors() || string(formatted) == string(src) {
+			continue
+		}
+		unformatted = true
+
+		name := c.displayPath(path)
+		if c.Diff {
+			os.Stdout.Write(textdiff.Unified("a/"+filepath.ToSlash(name), "b/"+filepath.ToSlash(name), src, formatted))
+		} else {
+			fmt.Println(name)
+		}
+		if c.Check {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err == nil {
+			err = ioutil.WriteFile(path, formatted, info.Mode())
+		}
+		if err != nil {
+			diags = diags.Append(fmtFileError(path, err))
+		}
+	}
+
+	if c.Check && unformatted {
+		return 3, diags
+	}
+	return 0, diags
+}
+
+// configFiles returns the paths of the native syntax configuration files
+// to format: either those given as arguments, or those in the given
+// directories, or by default those in all of the layers that LoadConfig
+// would load.
+func (c *fmtCommand) configFiles() ([]string, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	var files []string
+
+	if len(c.Paths) == 0 {
+		for _, layer := range c.Context.configLayers() {
+			more, err := nativeConfigFiles(layer.Dir)
+			if err != nil && !os.IsNotExist(err) {
+				diags = diags.Append(fmtFileError(layer.Dir, err))
+			}
+			files = append(files, more...)
+		}
+		return files, diags
+	}
+
+	for _, path := range c.Paths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.Context.WorkingDir, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			diags = diags.Append(fmtFileError(path, err))
+			continue
+		}
+		if info.IsDir() {
+			more, err := nativeConfigFiles(path)
+			if err != nil {
+				diags = diags.Append(fmtFileError(path, err))
+			}
+			files = append(files, more...)
+			continue
+		}
+		if !configs.IsConfigFile(filepath.Base(path)) {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Not a configuration file",
+				fmt.Sprintf("The file %s cannot be formatted, because its name doesn't have a .nv.hcl suffix.", path),
+			))
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, diags
+}
+
+// nativeConfigFiles returns the paths of the configuration files in the
+// given directory that use the native syntax. JSON configuration files are
+// left as their authors or generators wrote them.
+func nativeConfigFiles(dir string) ([]string, error) {
+	items, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for _, item := range items {
+		name := item.Name()
+		if item.IsDir() || !configs.IsConfigFile(name) || !strings.HasSuffix(name, ".nv.hcl") {
+			continue
+		}
+		ret = append(ret, filepath.Join(dir, name))
+	}
+	return ret, nil
+}
+
+// displayPath returns the given path relative to the working directory, if
+// it is within it, so that the output is as short as possible.
+func (c *fmtCommand) displayPath(path string) string {
+	rel, err := filepath.Rel(c.Context.WorkingDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return rel
+}
+
+func fmtFileError(path string, err error) nvdiags.Diagnostic {
+	return nvdiags.Sourceless(
+		nvdiags.Error,
+		"Failed to format configuration",
+		fmt.Sprintf("Could not format %s: %s.", path, err),
+	)
+}
@@ -0,0 +1,355 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/runs"
+	"envy.pw/cli/internal/trust"
+)
+
+// hookCommand is a command for printing the shell code that installs the
+// prompt hook, which keeps the auto_env environment of the current project
+// directory exported in an interactive shell.
+type hookCommand struct {
+	Shell string
+}
+
+// hookEvalCommand is the command that the prompt hook runs before each
+// prompt, which prints shell code to update the auto_env environment for
+// the current directory.
+type hookEvalCommand struct {
+	Context *RunContext
+	Shell   string
+}
+
+// autoEnvStateVar is the environment variable where the prompt hook records
+// what it last exported, so that it can tell whether anything needs to
+// change without loading the configuration at every prompt.
+const autoEnvStateVar = "ENVY_AUTO_ENV"
+
+// autoEnvRetryDelay is how long the prompt hook waits before evaluating an
+// auto_env block again after a failure, unless the configuration changes.
+const autoEnvRetryDelay = time.Minute
+
+// autoEnvState is the content of autoEnvStateVar, encoded as JSON.
+type autoEnvState struct {
+	// Fingerprint is the result of configs.LayersFingerprint for the
+	// configuration that the environment came from.
+	Fingerprint string `json:"fp"`
+
+	// Expires is the Unix time after which the environment must be
+	// evaluated again, or zero if it never expires.
+	Expires int64 `json:"exp,omitempty"`
+
+	// Keys are the names of the environment variables that the hook
+	// exported.
+	Keys []string `json:"keys,omitempty"`
+
+	// Saved are the values that the variables in Keys had before the hook
+	// first exported them, so that it can restore them once it no longer
+	// sets them. A null value means that the variable was unset.
+	Saved map[string]*string `json:"saved,omitempty"`
+}
+
+// hookShell describes how to integrate with one of the shells supported by
+// the prompt hook.
+type hookShell struct {
+	// Script is the code that installs the hook, where "%[1]s" is the
+	// quoted path of the envy executable.
+	Script string
+
+	Export func(w io.Writer, keys []string, env map[string]string) error
+	Unset  func(w io.Writer, keys []string) error
+}
+
+var hookShells = map[string]hookShell{
+	"bash": {
+		Script: `_envy_hook() {
+  local status=$?
+  eval "$(%[1]s hook-eval bash)"
+  return $status
+}
+if [[ ";${PROMPT_COMMAND:-};" != *";_envy_hook;"* ]]; then
+  PROMPT_COMMAND="_envy_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+		Export: writePOSIXExports,
+		Unset:  writePOSIXUnsets,
+	},
+	"zsh": {
+		Script: `_envy_hook() {
+  eval "$(%[1]s hook-eval zsh)"
+}
+typeset -ag precmd_functions chpwd_functions
+if (( ! ${precmd_functions[(I)_envy_hook]} )); then
+  precmd_functions=(_envy_hook $precmd_functions)
+fi
+if (( ! ${chpwd_functions[(I)_envy_hook]} )); then
+  chpwd_functions=(_envy_hook $chpwd_functions)
+fi
+`,
+		Export: writePOSIXExports,
+		Unset:  writePOSIXUnsets,
+	},
+	"fish": {
+		Script: `function __envy_hook --on-event fish_prompt
+    %[1]s hook-eval fish | source
+end
+`,
+		Export: writeFishExports,
+		Unset:  writeFishUnsets,
+	},
+}
+
+func (c *hookCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	shell, moreDiags := findHookShell(c.Shell)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Cannot find envy executable",
+			fmt.Sprintf("Could not determine the location of the envy executable for the hook to run: %s.", err),
+		))
+		return 1, diags
+	}
+
+	fmt.Printf(shell.Script, shellQuote(exe))
+	return 0, diags
+}
+
+func (c *hookEvalCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	shell, moreDiags := findHookShell(c.Shell)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	now := time.Now()
+	layers := c.Context.configLayers()
+	fingerprint := configs.LayersFingerprint(layers...)
+	if info, err := os.Stat(c.Context.TrustStorePath()); err == nil {
+		// Trusting a directory must also make the hook evaluate the
+		// environment again.
+		fingerprint += fmt.Sprintf(":%d", info.ModTime().UnixNano())
+	}
+
+	var prev autoEnvState
+	if raw := os.Getenv(autoEnvStateVar); raw != "" {
+		// If the state is invalid then we'll just treat it as empty,
+		// which means we'll evaluate the environment again.
+		json.Unmarshal([]byte(raw), &prev)
+	}
+	if prev.Fingerprint == fingerprint && (prev.Expires == 0 || now.Unix() < prev.Expires) {
+		return 0, diags // nothing has changed since the last prompt
+	}
+
+	next := autoEnvState{
+		Fingerprint: fingerprint,
+	}
+	env, expires, moreDiags := c.autoEnvironment(layers)
+	diags = diags.Append(moreDiags)
+	switch {
+	case moreDiags.HasErrors():
+		next.Expires = now.Add(autoEnvRetryDelay).Unix()
+	case env != nil:
+		next.Expires = expires.Unix()
+	}
+	exportKeys, exports, unset := next.update(&prev, env, os.LookupEnv)
+	stateJSON, err := json.Marshal(next)
+	if err != nil {
+		// Should never happen, because the state is always serializable.
+		panic(fmt.Sprintf("failed to serialize auto_env state: %s", err))
+	}
+	exports[autoEnvStateVar] = string(stateJSON)
+
+	if err := shell.Unset(os.Stdout, unset); err != nil {
+		diags = diags.Append(hookWriteError(err))
+		return 1, diags
+	}
+	if err := shell.Export(os.Stdout, append(exportKeys, autoEnvStateVar), exports); err != nil {
+		diags = diags.Append(hookWriteError(err))
+		return 1, diags
+	}
+
+	if prev.Fingerprint != fingerprint {
+		switch {
+		case len(next.Keys) != 0:
+			fmt.Fprintf(os.Stderr, "envy: exported %d environment variables from auto_env\n", len(next.Keys))
+		case len(prev.Keys) != 0:
+			fmt.Fprintf(os.Stderr, "envy: removed %d environment variables from auto_env\n", len(prev.Keys))
+		}
+	}
+	return 0, diags
+}
+
+// update records in the receiver that the hook exports the given
+// environment, having previously exported what prev describes, and returns
+// the changes to make to the shell's environment: the variables to export,
+// in order, with their values, and the variables to unset.
+//
+// Variables that the hook no longer sets are restored to the values they
+// had before it first exported them, as recorded in prev, or are unset if
+// they weren't set then. For a variable that the hook hasn't exported
+// before, lookup gives its current value, which the receiver remembers so
+// that it can be restored later.
+func (s *autoEnvState) update(prev *autoEnvState, env map[string]string, lookup func(string) (string, bool)) ([]string, map[string]string, []string) {
+	exported := make(map[string]bool, len(prev.Keys))
+	for _, k := range prev.Keys {
+		exported[k] = true
+	}
+
+	s.Keys = nil
+	s.Saved = make(map[string]*string, len(env))
+	exports := make(map[string]string, len(env)+len(prev.Keys)+1)
+	for k, v := range env {
+		s.Keys = append(s.Keys, k)
+		exports[k] = v
+		if exported[k] {
+			// A missing value means the variable was unset, which is also
+			// how we treat the state from older versions of the hook that
+			// didn't record previous values.
+			s.Saved[k] = prev.Saved[k]
+		} else if old, ok := lookup(k); ok {
+			s.Saved[k] = &old
+		} else {
+			s.Saved[k] = nil
+		}
+	}
+	sort.Strings(s.Keys)
+
+	keys := append([]string(nil), s.Keys...)
+	var unset []string
+	for _, k := range prev.Keys {
+		if _, exists := env[k]; exists {
+			continue
+		}
+		if saved := prev.Saved[k]; saved != nil {
+			keys = append(keys, k)
+			exports[k] = *saved
+		} else {
+			unset = append(unset, k)
+		}
+	}
+	return keys, exports, unset
+}
+
+// autoEnvironment loads the configuration from the given layers and then
+// evaluates its auto_env block, if it has one from a project layer.
+//
+// The result is nil if there is no auto_env block to evaluate.
+//
+// The hook runs as soon as the user changes into a directory, so unlike
+// other commands it always requires project configuration to be trusted,
+// regardless of RequireTrust. For an untrusted directory it only prints a
+// hint to trust it, rather than an error at every prompt.
+func (c *hookEvalCommand) autoEnvironment(layers []*configs.Layer) (map[string]string, time.Time, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	for _, layer := range layers {
+		if !layer.IsProject() {
+			continue
+		}
+		if err, ok := c.Context.checkTrusted(layer.Dir).(*trust.UntrustedError); ok {
+			fmt.Fprintf(os.Stderr, "envy: %s, so its auto_env block was not evaluated; review it and then run \"envy trust\" to allow it\n", err)
+			return nil, time.Time{}, diags
+		}
+	}
+
+	confineProjectModules(layers)
+	cfg, hclDiags := configs.LoadCheckedLayeredConfig(c.Context.checkLayerTrusted, layers...)
+	diags = diags.Append(hclDiags)
+	if hclDiags.HasErrors() {
+		return nil, time.Time{}, diags
+	}
+
+	// We only honor auto_env blocks from project configuration, because
+	// the user's own configuration applies in every directory.
+	if cfg.AutoEnv == nil || !cfg.AutoEnv.Layer.IsProject() {
+		return nil, time.Time{}, diags
+	}
+
+	cfg, hclDiags = cfg.WithProfile(configs.DefaultProfileName)
+	diags = diags.Append(hclDiags)
+	if hclDiags.HasErrors() {
+		return nil, time.Time{}, diags
+	}
+
+	runner, moreDiags := c.Context.NewRunner()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, time.Time{}, diags
+	}
+
+	result, moreDiags := runner.AutoEnvironment(context.Background(), &runs.AutoEnvCall{
+		WorkingDir: c.Context.WorkingDir,
+		Environ:    os.Environ(),
+	}, cfg)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, time.Time{}, diags
+	}
+	return result.Env, result.Expires, diags
+}
+
+func findHookShell(name string) (hookShell, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	shell, ok := hookShells[name]
+	if !ok {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Unsupported shell",
+			fmt.Sprintf("There is no hook for the shell %q. The supported shells are \"bash\", \"zsh\", and \"fish\".", name),
+		))
+	}
+	return shell, diags
+}
+
+func hookWriteError(err error) nvdiags.Diagnostic {
+	return nvdiags.Sourceless(
+		nvdiags.Error,
+		"Failed to write environment",
+		fmt.Sprintf("Could not write the shell commands to update the environment: %s.", err),
+	)
+}
+
+// writePOSIXUnsets writes "unset" commands for the given environment
+// variables for a POSIX shell, suitable for use with "eval".
+func writePOSIXUnsets(w io.Writer, keys []string) error {
+	if err := checkEnvNames(keys); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "unset %s\n", k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFishUnsets writes "set -e" commands for the given environment
+// variables for the fish shell, suitable for use with "eval".
+func writeFishUnsets(w io.Writer, keys []string) error {
+	if err := checkEnvNames(keys); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, err := fmt.Fprintf(w, "set -e %s;\n", k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
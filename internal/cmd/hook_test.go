@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAutoEnvStateUpdate(t *testing.T) {
+	// The shell starts with AWS_PROFILE set by the user and REGION unset.
+	shell := map[string]string{"AWS_PROFILE": "dev"}
+	lookup := func(k string) (string, bool) {
+		v, ok := shell[k]
+		return v, ok
+	}
+	apply := func(keys []string, exports map[string]string, unset []string) {
+		for _, k := range keys {
+			shell[k] = exports[k]
+		}
+		for _, k := range unset {
+			delete(shell, k)
+		}
+	}
+
+	// Entering the project exports both variables.
+	var outside, inside autoEnvState
+	keys, exports, unset := inside.update(&outside, map[string]string{
+		"AWS_PROFILE": "project",
+		"REGION":      "eu-west-1",
+	}, lookup)
+	if want := []string{"AWS_PROFILE", "REGION"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("wrong exported keys on entering %#v; want %#v", keys, want)
+	}
+	if len(unset) != 0 {
+		t.Errorf("unexpected unset on entering %#v", unset)
+	}
+	apply(keys, exports, unset)
+
+	// A refresh that still sets AWS_PROFILE must keep the user's value
+	// rather than saving the hook's own.
+	var refreshed autoEnvState
+	keys, exports, unset = refreshed.update(&inside, map[string]string{
+		"AWS_PROFILE": "project",
+	}, lookup)
+	if want := []string{"AWS_PROFILE"}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("wrong exported keys on refresh %#v; want %#v", keys, want)
+	}
+	if want := []string{"REGION"}; !reflect.DeepEqual(unset, want) {
+		t.Errorf("wrong unset on refresh %#v; want %#v", unset, want)
+	}
+	apply(keys, exports, unset)
+
+	// Leaving the project restores the user's value.
+	var left autoEnvState
+	keys, exports, unset = left.update(&refreshed, nil, lookup)
+	apply(keys, exports, unset)
+	if want := map[string]string{"AWS_PROFILE": "dev"}; !reflect.DeepEqual(shell, want) {
+		t.Errorf("wrong environment after leaving %#v; want %#v", shell, want)
+	}
+	if len(left.Keys) != 0 || len(left.Saved) != 0 {
+		t.Errorf("state after leaving still records variables: %#v", left)
+	}
+}
+
+func TestAutoEnvStateUpdateOldState(t *testing.T) {
+	// Older versions of the hook didn't record previous values, so their
+	// variables are unset on leaving, as they were then.
+	prev := autoEnvState{Keys: []string{"TOKEN"}}
+	var next autoEnvState
+	keys, _, unset := next.update(&prev, nil, func(string) (string, bool) {
+		return "from-hook", true
+	})
+	if len(keys) != 0 {
+		t.Errorf("unexpected exports %#v", keys)
+	}
+	if want := []string{"TOKEN"}; !reflect.DeepEqual(unset, want) {
+		t.Errorf("wrong unset %#v; want %#v", unset, want)
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"envy.pw/cli/internal/logging"
+)
+
+// configureLogging enables logging at the level given in the --log-level
+// option, or in the ENVY_LOG environment variable if the option isn't set.
+//
+// Log messages are written to the file named in ENVY_LOG_PATH, if set, or to
+// stderr otherwise.
+func configureLogging(levelOpt string) error {
+	levelStr := levelOpt
+	if levelStr == "" {
+		levelStr = os.Getenv("ENVY_LOG")
+	}
+	level, err := logging.ParseLevel(levelStr)
+	if err != nil {
+		return err
+	}
+	if level == logging.LevelOff {
+		return nil
+	}
+
+	var w io.Writer = os.Stderr
+	if path := os.Getenv("ENVY_LOG_PATH"); path != "" {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return fmt.Errorf("cannot open log file: %s", err)
+		}
+		// The file remains open until envy exits.
+		w = f
+	}
+	logging.Configure(level, w)
+	return nil
+}
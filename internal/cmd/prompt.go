@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// promptTerminal shows the given message on the controlling terminal and
+// returns the line the user enters in response.
+//
+// If echo is false then the user's input is not displayed as they type,
+// which is appropriate for passphrases and other secrets.
+func promptTerminal(message string, echo bool) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("cannot prompt for input because no terminal is available")
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, message)
+	if echo {
+		line, err := bufio.NewReader(tty).ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	raw, err := terminal.ReadPassword(int(tty.Fd()))
+	fmt.Fprint(tty, "\n")
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
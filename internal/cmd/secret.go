@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/secrets"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// SecretStorePath returns the path of the file containing the user's
+// encrypted secret store.
+func (c *RunContext) SecretStorePath() string {
+	return filepath.Join(c.DataDir, "secrets.json")
+}
+
+// OpenSecretStore opens the user's secret store, using the context's key
+// file if set or otherwise prompting for a passphrase.
+//
+// If create is true and the store does not yet exist, a passphrase prompt
+// asks for confirmation so that a typo can't lock the user out of their
+// new store.
+func (c *RunContext) OpenSecretStore(create bool) (*secrets.Store, error) {
+	path := c.SecretStorePath()
+	exists := secrets.Exists(path)
+	if !exists && !create {
+		return nil, fmt.Errorf("the secret store %s does not exist yet; use \"envy secret set\" to create it", path)
+	}
+
+	var key secrets.Key
+	if c.SecretKeyFile != "" {
+		var err error
+		key, err = secrets.ReadKeyFile(c.SecretKeyFile)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		passphrase, err := promptTerminal("Secret store passphrase: ", false)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			again, err := promptTerminal("Confirm new passphrase: ", false)
+			if err != nil {
+				return nil, err
+			}
+			if again != passphrase {
+				return nil, fmt.Errorf("passphrases do not match")
+			}
+		}
+		key = secrets.PassphraseKey(passphrase)
+	}
+
+	return secrets.Open(path, key)
+}
+
+// secretSetCommand is a command for creating or replacing a secret in the
+// secret store.
+type secretSetCommand struct {
+	Context *RunContext
+	Name    string
+}
+
+func (c *secretSetCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	// We read the value before opening the store so that a value piped in
+	// on stdin doesn't get confused with the passphrase prompt.
+	var value string
+	if terminal.IsTerminal(int(os.Stdin.Fd())) {
+		var err error
+		value, err = promptTerminal(fmt.Sprintf("Value for %s: ", c.Name), false)
+		if err != nil {
+			diags = diags.Append(secretStoreError(err))
+			return 1, diags
+		}
+	} else {
+		raw, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			diags = diags.Append(secretStoreError(err))
+			return 1, diags
+		}
+		value = strings.TrimSuffix(strings.TrimSuffix(string(raw), "\n"), "\r")
+	}
+
+	store, err := c.Context.OpenSecretStore(true)
+	if err != nil {
+		diags = diags.Append(secretStoreError(err))
+		return 1, diags
+	}
+	store.Set(c.Name, value)
+	if err := store.Save(); err != nil {
+		diags = diags.Append(secretStoreError(err))
+		return 1, diags
+	}
+	return 0, diags
+}
+
+// secretGetCommand is a command for printing the value of a secret from
+// the secret store.
+type secretGetCommand struct {
+	Context *RunContext
+	Name    string
+}
+
+func (c *secretGetCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	store, err := c.Context.OpenSecretStore(false)
+	if err != nil {
+		diags = diags.Append(secretStoreError(err))
+		return 1, diags
+	}
+	value, ok := store.Get(c.Name)
+	if !ok {
+		diags = diags.Append(secretNotFoundError(c.Name))
+		return 1, diags
+	}
+	fmt.Println(value)
+	return 0, diags
+}
+
+// secretListCommand is a command for listing the names of the secrets in
+// the secret store.
+type secretListCommand struct {
+	Context *RunContext
+}
+
+func (c *secretListCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	if !secrets.Exists(c.Context.SecretStorePath()) {
+		return 0, diags // an empty list, then
+	}
+	store, err := c.Context.OpenSecretStore(false)
+	if err != nil {
+		diags = diags.Append(secretStoreError(err))
+		return 1, diags
+	}
+	for _, name := range store.Names() {
+		fmt.Println(name)
+	}
+	return 0, diags
+}
+
+// secretRemoveCommand is a command for deleting a secret from the secret
+// store.
+type secretRemoveCommand struct {
+	Context *RunContext
+	Name    string
+}
+
+func (c *secretRemoveCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	store, err := c.Context.OpenSecretStore(false)
+	if err != nil {
+		diags = diags.Append(secretStoreError(err))
+		return 1, diags
+	}
+	if !store.Remove(c.Name) {
+		diags = diags.Append(secretNotFoundError(c.Name))
+		return 1, diags
+	}
+	if err := store.Save(); err != nil {
+		diags = diags.Append(secretStoreError(err))
+		return 1, diags
+	}
+	return 0, diags
+}
+
+func secretStoreError(err error) nvdiags.Diagnostic {
+	return nvdiags.Sourceless(
+		nvdiags.Error,
+		"Secret store error",
+		fmt.Sprintf("Failed to access the secret store: %s.", err),
+	)
+}
+
+func secretNotFoundError(name string) nvdiags.Diagnostic {
+	return nvdiags.Sourceless(
+		nvdiags.Error,
+		"Secret not found",
+		fmt.Sprintf("There is no secret named %q in the secret store.", name),
+	)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// shellCommand is a command for launching an interactive shell with the
+// environment of a configured command.
+type shellCommand struct {
+	Context *RunContext
+	Args    []string
+	Profile string
+}
+
+// shellEnvVar is the environment variable that records the name of the
+// command whose environment an envy shell has, which both marks the shell's
+// prompt and allows scripts to detect that they are running in one.
+const shellEnvVar = "ENVY_SHELL"
+
+func (c *shellCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	runner, call, cfg, moreDiags := c.Context.prepareCommandCall(c.Args, c.Profile)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 126, diags
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	tempDir, err := ioutil.TempDir("", "envy-shell-")
+	if err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to create temporary directory",
+			fmt.Sprintf("Could not create a temporary directory for the shell's startup files: %s.", err),
+		))
+		return 126, diags
+	}
+	defer os.RemoveAll(tempDir)
+
+	marker := fmt.Sprintf("(envy:%s) ", call.Addr.Name)
+	program, environ, err := shellInvocation(shell, marker, tempDir)
+	if err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to prepare shell",
+			fmt.Sprintf("Could not prepare the startup files for %s: %s.", shell, err),
+		))
+		return 126, diags
+	}
+	call.Program = program
+	call.ProgramEnv = append([]string{shellEnvVar + "=" + call.Addr.Name}, environ...)
+
+	status, moreDiags := runner.RunCommand(context.Background(), call, cfg)
+	diags = diags.Append(moreDiags)
+	return status, diags
+}
+
+// shellInvocation returns the command line for launching the given shell
+// interactively, along with any additional environment variables it needs,
+// such that its prompt starts with the given marker.
+//
+// Each shell's usual startup files still run, so the marker is added by
+// startup files of our own, written into the given directory, that run the
+// usual ones first. Shells that we don't know how to customize get the
+// marker only if they respect the PS1 environment variable.
+func shellInvocation(shell, marker, dir string) ([]string, []string, error) {
+	switch filepath.Base(shell) {
+	case "bash":
+		rcFile := filepath.Join(dir, "bashrc")
+		rc := "[ -f ~/.bashrc ] && . ~/.bashrc\n" +
+			"PS1=" + shellQuote(marker) + "\"$PS1\"\n"
+		if err := ioutil.WriteFile(rcFile, []byte(rc), 0600); err != nil {
+			return nil, nil, err
+		}
+		return []string{shell, "--rcfile", rcFile, "-i"}, nil, nil
+
+	case "zsh":
+		// zsh reads its startup files from ZDOTDIR, so we point that at our
+		// own directory and then restore it before running the user's.
+		origDir := os.Getenv("ZDOTDIR")
+		if origDir == "" {
+			origDir = os.Getenv("HOME")
+		}
+		rc := "ZDOTDIR=" + shellQuote(origDir) + "\n" +
+			"[ -f \"$ZDOTDIR/.zshrc\" ] && . \"$ZDOTDIR/.zshrc\"\n" +
+			"PROMPT=" + shellQuote(marker) + "\"$PROMPT\"\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, ".zshrc"), []byte(rc), 0600); err != nil {
+			return nil, nil, err
+		}
+		return []string{shell, "-i"}, []string{"ZDOTDIR=" + dir}, nil
+
+	case "fish":
+		init := "functions -c fish_prompt __envy_fish_prompt; " +
+			"function fish_prompt; printf '%s' " + shellQuote(marker) + "; __envy_fish_prompt; end"
+		return []string{shell, "-i", "-C", init}, nil, nil
+
+	default:
+		return []string{shell, "-i"}, []string{"PS1=" + marker + "$ "}, nil
+	}
+}
+
+// shellQuote quotes the given string for use as a single word in a POSIX
+// shell or in fish.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellInvocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-shell-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const marker = "(envy it's) "
+	tests := []struct {
+		shell    string
+		wantArgs []string
+		wantEnv  []string
+
+		// rcFile, if set, is a file that the shell must find in dir, which
+		// must contain rcWant.
+		rcFile string
+		rcWant string
+	}{
+		{
+			"/bin/bash",
+			[]string{"/bin/bash", "--rcfile", filepath.Join(dir, "bashrc"), "-i"},
+			nil,
+			"bashrc",
+			`PS1='(envy it'\''s) '"$PS1"`,
+		},
+		{
+			"/usr/bin/zsh",
+			[]string{"/usr/bin/zsh", "-i"},
+			[]string{"ZDOTDIR=" + dir},
+			".zshrc",
+			`PROMPT='(envy it'\''s) '"$PROMPT"`,
+		},
+		{
+			"fish",
+			[]string{"fish", "-i", "-C", `functions -c fish_prompt __envy_fish_prompt; function fish_prompt; printf '%s' '(envy it'\''s) '; __envy_fish_prompt; end`},
+			nil,
+			"",
+			"",
+		},
+		{
+			"/bin/dash",
+			[]string{"/bin/dash", "-i"},
+			[]string{"PS1=" + marker + "$ "},
+			"",
+			"",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(filepath.Base(test.shell), func(t *testing.T) {
+			args, env, err := shellInvocation(test.shell, marker, dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("wrong arguments\ngot:  %q\nwant: %q", args, test.wantArgs)
+			}
+			if !reflect.DeepEqual(env, test.wantEnv) {
+				t.Errorf("wrong environment\ngot:  %q\nwant: %q", env, test.wantEnv)
+			}
+			if test.rcFile == "" {
+				return
+			}
+			rc, err := ioutil.ReadFile(filepath.Join(dir, test.rcFile))
+			if err != nil {
+				t.Fatalf("failed to read startup file: %s", err)
+			}
+			if !strings.Contains(string(rc), test.rcWant) {
+				t.Errorf("startup file doesn't set the prompt\ngot:\n%s\nwant it to contain:\n%s", rc, test.rcWant)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// showCommand is a command for showing the child process that a command
+// would launch, with any sensitive values hidden.
+type showCommand struct {
+	Context *RunContext
+	Args    []string
+	Profile string
+}
+
+func (c *showCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	runner, call, cfg, moreDiags := c.Context.prepareCommandCall(c.Args, c.Profile)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	desc, moreDiags := runner.DescribeCommand(context.Background(), call, cfg)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+
+	fmt.Printf("from:    %s\n", desc.Layer)
+	fmt.Printf("program: %s\n", desc.Path)
+	fmt.Printf("args:\n")
+	for _, arg := range desc.Args {
+		fmt.Printf("  %q\n", arg)
+	}
+	fmt.Printf("dir:     %s\n", desc.Dir)
+	fmt.Printf("env:\n")
+	keys := make([]string, 0, len(desc.Env))
+	for k := range desc.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf("  %s=%s\n", k, desc.Env[k])
+	}
+	return 0, diags
+}
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/trust"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"golang.org/x/crypto/ed25519"
+)
+
+// TrustStorePath returns the path of the file recording the directories and
+// signing keys that the user trusts.
+func (c *RunContext) TrustStorePath() string {
+	return filepath.Join(c.DataDir, "trust.json")
+}
+
+// SigningKeyPath returns the path of the key file that "envy config sign"
+// uses by default.
+func (c *RunContext) SigningKeyPath() string {
+	return filepath.Join(c.DataDir, "signing.key")
+}
+
+// checkLayerTrusted is a configs.LayerCheck that rejects project layers
+// that the user hasn't trusted.
+func (c *RunContext) checkLayerTrusted(layer *configs.Layer) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	if !layer.IsProject() {
+		// The user's own configuration is always trusted.
+		return diags
+	}
+
+	switch err := c.checkTrusted(layer.Dir).(type) {
+	case nil:
+	case *trust.UntrustedError:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Untrusted project configuration",
+			Detail: fmt.Sprintf(
+				"The project configuration directory %s, so it was not loaded. After reviewing its files, run \"envy trust %s\" to trust it, or \"envy trust --key KEY\" to trust the key that it is signed with.",
+				err, err.Dir,
+			),
+		})
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Failed to check project configuration",
+			Detail:   fmt.Sprintf("Could not check whether the %s is trusted: %s.", layer, err),
+		})
+	}
+	return diags
+}
+
+// confineProjectModules requires the modules of the given project layers to
+// come from within their directories, because checkLayerTrusted can only
+// vouch for the files in a layer's own directory.
+func confineProjectModules(layers []*configs.Layer) {
+	for _, layer := range layers {
+		if layer.IsProject() {
+			layer.ConfineModules = true
+		}
+	}
+}
+
+// checkTrusted returns nil if the given project configuration directory is
+// trusted, or otherwise an error that is usually a *trust.UntrustedError.
+func (c *RunContext) checkTrusted(dir string) error {
+	store, err := trust.OpenStore(c.TrustStorePath())
+	if err != nil {
+		return fmt.Errorf("cannot read the trust store: %s", err)
+	}
+	return store.Check(dir)
+}
+
+// resolveProjectDirs returns the absolute paths of the configuration
+// directories that the given command line arguments refer to, which are
+// relative to the working directory. An argument that names a directory
+// containing a project configuration directory refers to that instead.
+//
+// With no arguments, the result is the project configuration directories
+// found by searching upwards from the working directory.
+func (c *RunContext) resolveProjectDirs(args []string) ([]string, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	var dirs []string
+	if len(args) == 0 {
+		for _, layer := range configs.FindProjectLayers(c.WorkingDir) {
+			dirs = append(dirs, layer.Dir)
+		}
+		if len(dirs) == 0 {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"No project configuration",
+				fmt.Sprintf("There is no %s directory in %s or any of its parent directories.", configs.ProjectConfigDirName, c.WorkingDir),
+			))
+		}
+		return dirs, diags
+	}
+
+	for _, arg := range args {
+		dir := arg
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(c.WorkingDir, dir)
+		}
+		if filepath.Base(dir) != configs.ProjectConfigDirName {
+			nested := filepath.Join(dir, configs.ProjectConfigDirName)
+			if info, err := os.Stat(nested); err == nil && info.IsDir() {
+				dir = nested
+			}
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Invalid configuration directory",
+				fmt.Sprintf("%s is not a directory.", arg),
+			))
+			continue
+		}
+		dirs = append(dirs, filepath.Clean(dir))
+	}
+	return dirs, diags
+}
+
+// configSignCommand is a command for signing a project configuration
+// directory, so that users who trust the signing key can load it.
+type configSignCommand struct {
+	Context *RunContext
+	Dir     string
+	KeyFile string
+}
+
+func (c *configSignCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	var args []string
+	if c.Dir != "" {
+		args = append(args, c.Dir)
+	}
+	dirs, moreDiags := c.Context.resolveProjectDirs(args)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 1, diags
+	}
+	// Without an argument we sign the innermost project configuration,
+	// which is the one nearest the working directory.
+	dir := dirs[len(dirs)-1]
+
+	keyFile := c.KeyFile
+	if keyFile == "" {
+		keyFile = c.Context.SigningKeyPath()
+	}
+	key, err := trust.ReadKeyFile(keyFile)
+	if os.IsNotExist(err) && c.KeyFile == "" {
+		key, err = trust.GenerateKeyFile(keyFile)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "Created new signing key %s\n", keyFile)
+		}
+	}
+	if err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to read signing key",
+			fmt.Sprintf("Could not read the signing key: %s.", err),
+		))
+		return 1, diags
+	}
+
+	if err := trust.Sign(dir, key); err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to sign configuration",
+			fmt.Sprintf("Could not sign %s: %s.", dir, err),
+		))
+		return 1, diags
+	}
+	pub := trust.EncodePublicKey(key.Public().(ed25519.PublicKey))
+	fmt.Printf("Signed %s with key %s\n", dir, pub)
+	fmt.Printf("\nUsers can trust this key by running:\n    envy trust --key %s\n", pub)
+	return 0, diags
+}
+
+// trustCommand is a command for recording that the user trusts project
+// configuration directories, or keys that such directories are signed with.
+type trustCommand struct {
+	Context *RunContext
+	Dirs    []string
+	Key     string
+	Remove  bool
+}
+
+func (c *trustCommand) Run() (int, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	store, err := trust.OpenStore(c.Context.TrustStorePath())
+	if err != nil {
+		diags = diags.Append(trustStoreError(err))
+		return 1, diags
+	}
+
+	if c.Key != "" {
+		if len(c.Dirs) != 0 {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Invalid arguments",
+				"The --key option cannot be used with directory arguments.",
+			))
+			return 1, diags
+		}
+		pub, err := trust.ParsePublicKey(c.Key)
+		if err != nil {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Invalid public key",
+				fmt.Sprintf("The --key option must be a public key as printed by \"envy config sign\": %s.", err),
+			))
+			return 1, diags
+		}
+		switch {
+		case !c.Remove:
+			store.TrustKey(pub)
+			fmt.Printf("Trusted key %s\n", trust.EncodePublicKey(pub))
+		case store.RemoveKey(pub):
+			fmt.Printf("Removed trust for key %s\n", trust.EncodePublicKey(pub))
+		default:
+			fmt.Printf("Key %s was not trusted\n", trust.EncodePublicKey(pub))
+		}
+	} else {
+		dirs, moreDiags := c.Context.resolveProjectDirs(c.Dirs)
+		diags = diags.Append(moreDiags)
+		if moreDiags.HasErrors() {
+			return 1, diags
+		}
+		for _, dir := range dirs {
+			switch {
+			case !c.Remove:
+				if err := store.TrustDir(dir); err != nil {
+					diags = diags.Append(trustStoreError(err))
+					return 1, diags
+				}
+				fmt.Printf("Trusted %s\n", dir)
+			case store.RemoveDir(dir):
+				fmt.Printf("Removed trust for %s\n", dir)
+			default:
+				fmt.Printf("%s was not trusted\n", dir)
+			}
+		}
+	}
+
+	if err := store.Save(); err != nil {
+		diags = diags.Append(trustStoreError(err))
+		return 1, diags
+	}
+	return 0, diags
+}
+
+func trustStoreError(err error) nvdiags.Diagnostic {
+	return nvdiags.Sourceless(
+		nvdiags.Error,
+		"Trust store error",
+		fmt.Sprintf("Failed to update the trust store: %s.", err),
+	)
+}
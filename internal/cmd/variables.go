@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// variableOptionPrefix is the prefix of the command line options that set
+// values for input variables, like --var-region=eu-west-1.
+const variableOptionPrefix = "--var-"
+
+// splitVariableArgs separates any options setting input variables from the
+// start of the given arguments, which are those following a command name,
+// returning the variable values and the remaining arguments for the command.
+//
+// Options are recognized only at the start of the arguments, so that the
+// command's own arguments are passed through verbatim. A value can be given
+// either in the same argument, after an equals sign, or as the following
+// argument.
+func splitVariableArgs(args []string) (map[string]string, []string, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	vals := make(map[string]string)
+
+	for len(args) > 0 && strings.HasPrefix(args[0], variableOptionPrefix) {
+		opt := args[0][len(variableOptionPrefix):]
+		args = args[1:]
+
+		name, val := opt, ""
+		if eq := strings.Index(opt, "="); eq >= 0 {
+			name, val = opt[:eq], opt[eq+1:]
+		} else if len(args) > 0 {
+			val = args[0]
+			args = args[1:]
+		} else {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Missing variable value",
+				fmt.Sprintf("The option %s%s must be followed by a value for the variable.", variableOptionPrefix, name),
+			))
+			continue
+		}
+
+		if !addrs.ValidName(name) {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Invalid variable name",
+				fmt.Sprintf("The name %q is not a valid name for a variable.", name),
+			))
+			continue
+		}
+		vals[name] = val
+	}
+
+	return vals, args, diags
+}
@@ -0,0 +1,84 @@
+package configs
+
+import (
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// DefaultAutoEnvCacheFor is how long the shell hook reuses the environment
+// from an auto_env block, if the block doesn't specify otherwise.
+const DefaultAutoEnvCacheFor = 15 * time.Minute
+
+// AutoEnv represents the "auto_env" block of a configuration, which decides
+// the environment variables that the shell hook sets automatically in
+// directories that use the configuration.
+//
+// The shell hook only honors an auto_env block from a project configuration
+// layer, so that entering a directory can't cause helpers from the user's
+// own configuration to run unexpectedly.
+type AutoEnv struct {
+	DeclRange hcl.Range
+
+	// Layer is the configuration layer that the block was loaded from, or
+	// nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
+	// Environment is a mapping of environment variables to set, like the
+	// attribute of the same name in Command.
+	Environment hcl.Expression
+
+	// Dependencies is a collection of references to other objects that
+	// must be active for the environment to be valid, even though they
+	// are not referenced in Environment.
+	Dependencies []Reference
+
+	// CacheFor is how long the shell hook may reuse the environment before
+	// evaluating the block again. The hook also evaluates the block again
+	// sooner if any of the helpers it uses need refreshing.
+	CacheFor time.Duration
+}
+
+// Addr returns the address of the auto_env block.
+func (ae *AutoEnv) Addr() addrs.AutoEnv {
+	return addrs.AutoEnv{}
+}
+
+// AllReferences returns all of the references made from the block.
+func (ae *AutoEnv) AllReferences() []Reference {
+	var refs []Reference
+	refs = append(refs, exprReferences(ae.Environment)...)
+	refs = append(refs, ae.Dependencies...)
+	return refs
+}
+
+func decodeAutoEnvBlock(block *hcl.Block) (*AutoEnv, hcl.Diagnostics) {
+	ae := &AutoEnv{
+		DeclRange: block.DefRange,
+		CacheFor:  DefaultAutoEnvCacheFor,
+	}
+
+	type DecodeAutoEnv struct {
+		Environment  hcl.Expression `hcl:"env"`
+		Dependencies hcl.Expression `hcl:"depends_on"`
+		CacheFor     hcl.Expression `hcl:"cache_for"`
+	}
+	var decAE DecodeAutoEnv
+	diags := gohcl.DecodeBody(block.Body, nil, &decAE)
+	ae.Environment = decAE.Environment
+
+	deps, moreDiags := decodeDependsOn(decAE.Dependencies)
+	diags = append(diags, moreDiags...)
+	ae.Dependencies = deps
+
+	d, ok, moreDiags := decodeDuration(decAE.CacheFor, "cache_for")
+	diags = append(diags, moreDiags...)
+	if ok {
+		ae.CacheFor = d
+	}
+
+	return ae, diags
+}
@@ -0,0 +1,91 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadLayeredConfigAutoEnv(t *testing.T) {
+	root, err := ioutil.TempDir("", "envy-auto-env-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"user/main.nv.hcl":    `auto_env { env = { FROM = "user" } }`,
+		"project/main.nv.hcl": "auto_env {\n  env = { FROM = \"project\" }\n  cache_for = \"5m\"\n}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	userLayer := UserLayer(filepath.Join(root, "user"))
+	projectLayer := ProjectLayer(filepath.Join(root, "project"))
+
+	cfg, diags := LoadLayeredConfig(userLayer)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if got, want := cfg.AutoEnv.Layer, userLayer; got != want {
+		t.Errorf("wrong layer %s; want %s", got, want)
+	}
+	if cfg.AutoEnv.Layer.IsProject() {
+		t.Errorf("user layer is a project layer")
+	}
+	if got, want := cfg.AutoEnv.CacheFor, DefaultAutoEnvCacheFor; got != want {
+		t.Errorf("wrong default cache duration %s; want %s", got, want)
+	}
+
+	cfg, diags = LoadLayeredConfig(userLayer, projectLayer)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if got, want := cfg.AutoEnv.Layer, projectLayer; got != want {
+		t.Errorf("wrong layer %s; want %s", got, want)
+	}
+	if !cfg.AutoEnv.Layer.IsProject() {
+		t.Errorf("project layer is not a project layer")
+	}
+	if got, want := cfg.AutoEnv.CacheFor, 5*time.Minute; got != want {
+		t.Errorf("wrong cache duration %s; want %s", got, want)
+	}
+
+	before := LayersFingerprint(userLayer, projectLayer)
+	if got := LayersFingerprint(userLayer, projectLayer); got != before {
+		t.Errorf("fingerprint changed without any changes to the files")
+	}
+	extra := filepath.Join(root, "project", "extra.nv.hcl")
+	if err := ioutil.WriteFile(extra, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := LayersFingerprint(userLayer, projectLayer); got == before {
+		t.Errorf("fingerprint didn't change after adding a file")
+	}
+}
+
+func TestLoadConfigAutoEnvDuplicate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-auto-env-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := "auto_env {}\nauto_env {}\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "main.nv.hcl"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, diags := LoadConfig(dir)
+	if !diags.HasErrors() {
+		t.Fatalf("no errors; want an error about the duplicate block")
+	}
+}
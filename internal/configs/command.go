@@ -1,6 +1,8 @@
 package configs
 
 import (
+	"fmt"
+
 	"envy.pw/cli/internal/addrs"
 
 	"github.com/hashicorp/hcl2/gohcl"
@@ -12,6 +14,10 @@ type Command struct {
 	Name      string
 	DeclRange hcl.Range
 
+	// Layer is the configuration layer that the command was loaded from, or
+	// nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
 	// Executable and CommandLine are mutually-exclusive.
 	//
 	// Executable sets only the prefix of the command to run, taking all of the
@@ -20,10 +26,16 @@ type Command struct {
 	// CommandLine, on the other hand, sets the full sequence of command line
 	// arguments, overriding whatever was present on the command line. However,
 	// the expression may still refer to what was given on the command line,
-	// allowing the resulting command to be constructed dynamically.
+	// via the "args" object, allowing the resulting command to be constructed
+	// dynamically.
 	Executable  hcl.Expression
 	CommandLine hcl.Expression
 
+	// Args are the options that envy parses from the command line arguments
+	// before running the command. If any are declared then only the
+	// remaining positional arguments are appended to Executable.
+	Args []*CommandArg
+
 	// Environment is a mapping of environment variables to set when launching
 	// the command.
 	//
@@ -46,15 +58,51 @@ type Command struct {
 	// be signalled some other way.
 	OnUpdate ProcessAction
 
+	// UpdateSignal is the name of the signal to send when OnUpdate is
+	// ProcessSignal, which is one of SignalNames.
+	//
+	// Because a running program's arguments and environment can't change,
+	// the signal action is for programs that reload files produced by
+	// helpers, such as templates. Envy therefore sends the signal whenever
+	// any of the command's helpers produce new results, even if the
+	// command's own settings are unaffected, but restarts the program
+	// instead if its arguments or environment did change.
+	UpdateSignal string
+
 	// OnError selects a behavior for when a dependency of Executable,
 	// CommandLine, Environment, or InheritEnvironment enters error state,
 	// which effectively puts this command also in an error state.
 	OnError ProcessAction
 
+	// Termination decides how envy stops the command's child process. It
+	// is never nil: commands without a termination block get the result
+	// of DefaultTermination.
+	Termination *Termination
+
+	// RestartPolicy decides whether envy starts the command's child process
+	// again after it exits by itself. It is never nil: commands without a
+	// restart_policy block get the result of DefaultRestartPolicy.
+	RestartPolicy *RestartPolicy
+
+	// Sandbox decides the restrictions placed on the command's child
+	// process. It is never nil: commands without a sandbox block get the
+	// result of DefaultSandbox.
+	Sandbox *Sandbox
+
+	// PTY, if true, runs the command's child process under a pseudo-terminal
+	// owned by envy, which relays between it and envy's own terminal. This
+	// keeps interactive programs working normally across restarts, and
+	// allows envy to show messages such as when credentials are refreshed.
+	PTY bool
+
 	// Dependencies is a collection of references to other objects that
 	// must exist and be active for the command to function, even though
 	// they are not referenced in any of the other configuration expressions.
 	Dependencies []Reference
+
+	// body is the body that the command was decoded from, retained so that
+	// override files can be merged into it.
+	body hcl.Body
 }
 
 // Addr returns the address for the command that was declared.
@@ -69,6 +117,25 @@ func decodeCommandBlock(block *hcl.Block) (*Command, hcl.Diagnostics) {
 		DeclRange: block.DefRange,
 	}
 
+	diags = append(diags, cmd.decodeBody(block.Body)...)
+
+	if !validName(cmd.Name) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid command name",
+			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+
+	return cmd, diags
+}
+
+// decodeBody populates the receiver's settings from the given block body.
+func (cmd *Command) decodeBody(body hcl.Body) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	cmd.body = body
+
 	type DecodeCommand struct {
 		Executable         hcl.Expression `hcl:"exec"`
 		CommandLine        hcl.Expression `hcl:"cmdline"`
@@ -77,10 +144,14 @@ func decodeCommandBlock(block *hcl.Block) (*Command, hcl.Diagnostics) {
 		WorkDir            hcl.Expression `hcl:"work_dir"`
 		OnUpdate           hcl.Expression `hcl:"on_update"`
 		OnError            hcl.Expression `hcl:"on_error"`
+		PTY                *bool          `hcl:"pty"`
 		Dependencies       hcl.Expression `hcl:"depends_on"`
 	}
+	content, remain, moreDiags := body.PartialContent(commandBlockSchema)
+	diags = append(diags, moreDiags...)
+
 	var decCmd DecodeCommand
-	moreDiags := gohcl.DecodeBody(block.Body, nil, &decCmd)
+	moreDiags = gohcl.DecodeBody(remain, nil, &decCmd)
 	diags = append(diags, moreDiags...)
 
 	cmd.Executable = decCmd.Executable
@@ -89,25 +160,112 @@ func decodeCommandBlock(block *hcl.Block) (*Command, hcl.Diagnostics) {
 	cmd.InheritEnvironment = decCmd.InheritEnvironment
 	cmd.WorkDir = decCmd.WorkDir
 
-	cmd.OnUpdate, moreDiags = decodeProcessAction(decCmd.OnUpdate)
+	cmd.OnUpdate, cmd.UpdateSignal, moreDiags = decodeProcessAction(decCmd.OnUpdate, true)
 	diags = append(diags, moreDiags...)
 
-	cmd.OnError, moreDiags = decodeProcessAction(decCmd.OnError)
+	// Signalling the process can't resolve an error, so only on_update
+	// supports the signal action.
+	cmd.OnError, _, moreDiags = decodeProcessAction(decCmd.OnError, false)
 	diags = append(diags, moreDiags...)
 
+	cmd.PTY = decCmd.PTY != nil && *decCmd.PTY
+
 	cmd.Dependencies, moreDiags = decodeDependsOn(decCmd.Dependencies)
 	diags = append(diags, moreDiags...)
 
-	if !validName(cmd.Name) {
-		diags = diags.Append(&hcl.Diagnostic{
-			Severity: hcl.DiagError,
-			Summary:  "Invalid command name",
-			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
-			Subject:  block.LabelRanges[0].Ptr(),
+	cmd.Args = nil
+	cmd.Termination = DefaultTermination()
+	cmd.RestartPolicy = DefaultRestartPolicy()
+	cmd.Sandbox = DefaultSandbox()
+	seen := make(map[string]*CommandArg)
+	singletons := make(map[string]*hcl.Block)
+	for _, block := range content.Blocks {
+		if block.Type != "arg" {
+			// All other nested block types may appear at most once.
+			if existing, exists := singletons[block.Type]; exists {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  fmt.Sprintf("Duplicate %s block", block.Type),
+					Detail:   fmt.Sprintf("A %s block was already declared at %s.", block.Type, existing.DefRange),
+					Subject:  block.DefRange.Ptr(),
+				})
+				continue
+			}
+			singletons[block.Type] = block
+		}
+
+		switch block.Type {
+		case "arg":
+			arg, moreDiags := decodeCommandArgBlock(block)
+			diags = append(diags, moreDiags...)
+			for _, flag := range []string{"--" + arg.Name, "-" + arg.Short} {
+				if flag == "-" {
+					continue
+				}
+				if existing, exists := seen[flag]; exists {
+					diags = diags.Append(&hcl.Diagnostic{
+						Severity: hcl.DiagError,
+						Summary:  "Argument name conflict",
+						Detail:   fmt.Sprintf("The option %s was already declared at %s.", flag, existing.DeclRange),
+						Subject:  arg.DeclRange.Ptr(),
+					})
+				}
+				seen[flag] = arg
+			}
+			cmd.Args = append(cmd.Args, arg)
+		case "termination":
+			cmd.Termination, moreDiags = decodeTerminationBlock(block)
+			diags = append(diags, moreDiags...)
+		case "restart_policy":
+			cmd.RestartPolicy, moreDiags = decodeRestartPolicyBlock(block)
+			diags = append(diags, moreDiags...)
+		case "sandbox":
+			cmd.Sandbox, moreDiags = decodeSandboxBlock(block)
+			diags = append(diags, moreDiags...)
+		}
+	}
+
+	return diags
+}
+
+var commandBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "arg", LabelNames: []string{"name"}},
+		{Type: "restart_policy"},
+		{Type: "sandbox"},
+		{Type: "termination"},
+	},
+}
+
+// merge returns a new command that has the settings of the receiver
+// overridden by any that are set in the given command from an override file.
+//
+// Because "exec" and "cmdline" are mutually exclusive, overriding either one
+// of them also removes the other from the base command.
+func (cmd *Command) merge(override *Command) (*Command, hcl.Diagnostics) {
+	base := cmd.body
+	overrideAttrs, _, _ := override.body.PartialContent(&hcl.BodySchema{
+		Attributes: []hcl.AttributeSchema{
+			{Name: "exec"},
+			{Name: "cmdline"},
+		},
+	})
+	if overrideAttrs != nil && len(overrideAttrs.Attributes) > 0 {
+		_, base, _ = base.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{
+				{Name: "exec"},
+				{Name: "cmdline"},
+			},
 		})
 	}
 
-	return cmd, diags
+	merged := &Command{
+		Name:      cmd.Name,
+		DeclRange: cmd.DeclRange,
+		Layer:     cmd.Layer,
+	}
+	diags := merged.decodeBody(mergeBodies(base, override.body))
+	return merged, diags
 }
 
 func (c *Command) AllReferences() []Reference {
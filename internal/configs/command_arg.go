@@ -0,0 +1,132 @@
+package configs
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// CommandArg represents a single "arg" block inside a "command" block, which
+// declares an option that envy parses from the arguments given when running
+// the command, making its value available as args.NAME.
+type CommandArg struct {
+	Name      string
+	DeclRange hcl.Range
+
+	// Short is an optional single-character alias for the option, used as
+	// "-p" rather than "--profile".
+	Short string
+
+	// Type is the type of the option's value, which must be a primitive
+	// type. Options of type bool are set without a value.
+	Type cty.Type
+
+	// Default is the value of the option when it is not given, already
+	// converted to Type. It is a null value if no default is configured.
+	Default cty.Value
+
+	// Description is an optional human-readable description of the option.
+	Description string
+}
+
+// reservedArgNames are names that cannot be used for command arguments
+// because they conflict with other attributes of the "args" object.
+var reservedArgNames = map[string]struct{}{
+	"all":        struct{}{},
+	"positional": struct{}{},
+}
+
+func decodeCommandArgBlock(block *hcl.Block) (*CommandArg, hcl.Diagnostics) {
+	arg := &CommandArg{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+		Type:      cty.String,
+	}
+
+	type DecodeArg struct {
+		Short       hcl.Expression `hcl:"short"`
+		Type        hcl.Expression `hcl:"type"`
+		Default     hcl.Expression `hcl:"default"`
+		Description *string        `hcl:"description"`
+	}
+	var decArg DecodeArg
+	diags := gohcl.DecodeBody(block.Body, nil, &decArg)
+	if decArg.Description != nil {
+		arg.Description = *decArg.Description
+	}
+
+	if decArg.Type != nil {
+		if v, moreDiags := decArg.Type.Value(nil); moreDiags.HasErrors() || !v.IsNull() {
+			ty, moreDiags := typeexpr.Type(decArg.Type)
+			diags = append(diags, moreDiags...)
+			switch {
+			case moreDiags.HasErrors():
+			case !ty.IsPrimitiveType():
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid argument type",
+					Detail:   "The type of a command argument must be string, number, or bool.",
+					Subject:  decArg.Type.Range().Ptr(),
+				})
+			default:
+				arg.Type = ty
+			}
+		}
+	}
+
+	arg.Default = cty.NullVal(arg.Type)
+	if decArg.Default != nil {
+		v, moreDiags := decArg.Default.Value(nil)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() {
+			v, err := convert.Convert(v, arg.Type)
+			if err != nil {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid default value for argument",
+					Detail:   fmt.Sprintf("This default value is not compatible with the argument's type: %s.", err),
+					Subject:  decArg.Default.Range().Ptr(),
+				})
+			} else {
+				arg.Default = v
+			}
+		}
+	}
+
+	if !validName(arg.Name) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid argument name",
+			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+	if _, reserved := reservedArgNames[arg.Name]; reserved {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid argument name",
+			Detail:   fmt.Sprintf("The argument name %q is reserved for accessing the command line arguments.", arg.Name),
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+	if decArg.Short != nil {
+		if v, moreDiags := decArg.Short.Value(nil); moreDiags.HasErrors() || !v.IsNull() {
+			moreDiags := gohcl.DecodeExpression(decArg.Short, nil, &arg.Short)
+			diags = append(diags, moreDiags...)
+			if !moreDiags.HasErrors() && len([]rune(arg.Short)) != 1 {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid short argument name",
+					Detail:   "The short name for an argument must be a single character.",
+					Subject:  decArg.Short.Range().Ptr(),
+				})
+			}
+		}
+	}
+
+	return arg, diags
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/logging"
 
 	"github.com/hashicorp/hcl2/hcl"
 )
@@ -25,9 +27,31 @@ type Config struct {
 	// be resolved relative to.
 	BaseDir string
 
+	// Layers are the layers that the configuration was loaded from, in
+	// order of increasing precedence, or nil if it wasn't loaded from
+	// directories at all.
+	Layers []*Layer
+
 	Commands      map[addrs.Command]*Command
 	Helpers       map[addrs.Helper]*Helper
 	SharedObjects map[addrs.SharedObject]*SharedObject
+
+	// Modules are the modules declared in the configuration, whose helpers
+	// are included in Helpers with addresses in the module's namespace.
+	Modules map[string]*Module
+
+	Variables map[addrs.Variable]*Variable
+
+	// Profiles are the profiles declared in the configuration, which are
+	// applied using WithProfile.
+	Profiles map[string]*Profile
+
+	// AutoEnv is the configuration's auto_env block, or nil if it has none.
+	AutoEnv *AutoEnv
+
+	// Policies are the policies restricting which objects may refer to
+	// which others, keyed by policy name. See CheckReference.
+	Policies map[string]*Policy
 }
 
 func newConfig(baseDir string) *Config {
@@ -37,6 +61,10 @@ func newConfig(baseDir string) *Config {
 		Commands:      map[addrs.Command]*Command{},
 		Helpers:       map[addrs.Helper]*Helper{},
 		SharedObjects: map[addrs.SharedObject]*SharedObject{},
+		Modules:       map[string]*Module{},
+		Variables:     map[addrs.Variable]*Variable{},
+		Profiles:      map[string]*Profile{},
+		Policies:      map[string]*Policy{},
 	}
 }
 
@@ -44,12 +72,37 @@ func newConfig(baseDir string) *Config {
 // extensions) in the given directory, merges them into a single Config,
 // and returns it.
 //
+// Files named "override" or with names ending in "_override" are override
+// files, which are applied after all of the other files. Blocks in an
+// override file are merged attribute-by-attribute into the object of the
+// same address declared in the other files, rather than declaring new
+// objects.
+//
 // If the returned diagnostics contains errors then the returned Config may
 // be incomplete, but will include whatever subset of the configuration could
 // be understood in spite of those errors.
 func LoadConfig(dir string) (*Config, hcl.Diagnostics) {
-	var diags hcl.Diagnostics
 	cfg := newConfig(dir)
+	overrides, diags := cfg.loadDir(&Layer{
+		Name: "configuration",
+		Dir:  dir,
+	})
+	for _, file := range overrides {
+		diags = append(diags, cfg.applyOverrides(file)...)
+	}
+	return cfg, diags
+}
+
+// loadDir loads all of the configuration files in the directory of the
+// given layer into the receiver, except for override files, which it
+// returns for the caller to apply once any other layers that the overrides
+// might apply to are also loaded.
+func (c *Config) loadDir(layer *Layer) ([]*File, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	var overrides []*File
+	dir := layer.Dir
+	c.Layers = append(c.Layers, layer)
+	logging.Debug("loading configuration", "dir", dir, "layer", layer.Name)
 
 	items, err := ioutil.ReadDir(dir)
 	if err != nil {
@@ -67,7 +120,7 @@ func LoadConfig(dir string) (*Config, hcl.Diagnostics) {
 				Detail:   fmt.Sprintf("Cannot open %q: %s.", dir, err),
 			})
 		}
-		return cfg, diags
+		return nil, diags
 	}
 
 	for _, info := range items {
@@ -78,15 +131,24 @@ func LoadConfig(dir string) (*Config, hcl.Diagnostics) {
 		if !IsConfigFile(info.Name()) {
 			continue
 		}
+		name = filepath.Join(dir, name)
 
+		logging.Debug("loading configuration file", "file", name)
 		file, moreDiags := LoadConfigFile(name)
 		diags = append(diags, moreDiags...)
+		logging.Trace("loaded configuration file", "file", name, "commands", len(file.Commands), "helpers", len(file.Helpers), "diagnostics", len(moreDiags))
 
-		moreDiags = cfg.mergeFile(file)
+		file.setLayer(layer)
+		if IsOverrideFile(name) {
+			overrides = append(overrides, file)
+			continue
+		}
+		moreDiags = c.mergeFile(file)
 		diags = append(diags, moreDiags...)
 	}
+	diags = append(diags, c.loadModules()...)
 
-	return cfg, diags
+	return overrides, diags
 }
 
 // BuildConfig is similar to LoadConfig but it works with some files already
@@ -100,6 +162,7 @@ func BuildConfig(baseDir string, files []*File) (*Config, hcl.Diagnostics) {
 		moreDiags := cfg.mergeFile(file)
 		diags = append(diags, moreDiags...)
 	}
+	diags = append(diags, cfg.loadModules()...)
 
 	return cfg, diags
 }
@@ -149,7 +212,73 @@ func (c *Config) mergeFile(f *File) hcl.Diagnostics {
 		c.SharedObjects[addr] = so
 	}
 
-	return nil
+	for _, m := range f.Modules {
+		if existing, exists := c.Modules[m.Name]; exists {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Module name conflict",
+				Detail:   fmt.Sprintf("A module named %q was already declared at %s.", m.Name, existing.DeclRange),
+				Subject:  m.DeclRange.Ptr(),
+			})
+			continue
+		}
+		c.Modules[m.Name] = m
+	}
+
+	for _, v := range f.Variables {
+		addr := v.Addr()
+		if existing, exists := c.Variables[addr]; exists {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Variable name conflict",
+				Detail:   fmt.Sprintf("A variable named %q was already declared at %s.", v.Name, existing.DeclRange),
+				Subject:  v.DeclRange.Ptr(),
+			})
+			continue
+		}
+		c.Variables[addr] = v
+	}
+
+	for _, p := range f.Profiles {
+		if existing, exists := c.Profiles[p.Name]; exists {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Profile name conflict",
+				Detail:   fmt.Sprintf("A profile named %q was already declared at %s.", p.Name, existing.DeclRange),
+				Subject:  p.DeclRange.Ptr(),
+			})
+			continue
+		}
+		c.Profiles[p.Name] = p
+	}
+
+	for _, p := range f.Policies {
+		if existing, exists := c.Policies[p.Name]; exists {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Policy name conflict",
+				Detail:   fmt.Sprintf("A policy named %q was already declared at %s.", p.Name, existing.DeclRange),
+				Subject:  p.DeclRange.Ptr(),
+			})
+			continue
+		}
+		c.Policies[p.Name] = p
+	}
+
+	for _, ae := range f.AutoEnvs {
+		if c.AutoEnv != nil {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Duplicate auto_env block",
+				Detail:   fmt.Sprintf("An auto_env block was already declared at %s.", c.AutoEnv.DeclRange),
+				Subject:  ae.DeclRange.Ptr(),
+			})
+			continue
+		}
+		c.AutoEnv = ae
+	}
+
+	return diags
 }
 
 // IsConfigFile returns true if the given filename should be recognized as
@@ -0,0 +1,38 @@
+package configs
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// decodeDuration decodes a duration given as a string like "30s" or "10m"
+// for the argument with the given name. The result is false if the
+// expression is null, in which case the caller should use its default.
+func decodeDuration(expr hcl.Expression, argName string) (time.Duration, bool, hcl.Diagnostics) {
+	if expr == nil {
+		return 0, false, nil
+	}
+	if v, diags := expr.Value(nil); !diags.HasErrors() && v.IsNull() {
+		return 0, false, nil
+	}
+
+	var raw string
+	diags := gohcl.DecodeExpression(expr, nil, &raw)
+	if diags.HasErrors() {
+		return 0, false, diags
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid duration",
+			Detail:   fmt.Sprintf("The %s argument must be a duration like \"30s\" or \"10m\", not %q.", argName, raw),
+			Subject:  expr.Range().Ptr(),
+		})
+		return 0, false, diags
+	}
+	return d, true, diags
+}
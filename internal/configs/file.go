@@ -2,6 +2,7 @@ package configs
 
 import (
 	"fmt"
+	"io/ioutil"
 	"strings"
 
 	"github.com/hashicorp/hcl2/hcl"
@@ -15,12 +16,52 @@ type File struct {
 	Commands      []*Command
 	Helpers       []*Helper
 	SharedObjects []*SharedObject
+	Modules       []*Module
+	Variables     []*Variable
+	Profiles      []*Profile
+	AutoEnvs      []*AutoEnv
+	Policies      []*Policy
 }
 
 func newFile() *File {
 	return &File{}
 }
 
+// setLayer records the given layer as the origin of all of the objects
+// declared in the file.
+func (f *File) setLayer(layer *Layer) {
+	for _, obj := range f.Commands {
+		obj.Layer = layer
+	}
+	for _, obj := range f.Helpers {
+		obj.Layer = layer
+	}
+	for _, obj := range f.SharedObjects {
+		obj.Layer = layer
+	}
+	for _, obj := range f.Modules {
+		obj.Layer = layer
+	}
+	for _, obj := range f.Variables {
+		obj.Layer = layer
+	}
+	for _, obj := range f.AutoEnvs {
+		obj.Layer = layer
+	}
+	for _, obj := range f.Policies {
+		obj.Layer = layer
+	}
+	for _, obj := range f.Profiles {
+		obj.Layer = layer
+		for _, h := range obj.Helpers {
+			h.Layer = layer
+		}
+		for _, so := range obj.SharedObjects {
+			so.Layer = layer
+		}
+	}
+}
+
 // LoadConfigFile loads a single configuration file.
 //
 // The suffix of the given filename must be either ".nv.hcl" or ".nv.json",
@@ -32,6 +73,14 @@ func newFile() *File {
 // It's rare to need to call this function directly. Instead, prefer to call
 // LoadConfig to load a full configuration from a directory.
 func LoadConfigFile(name string) (*File, hcl.Diagnostics) {
+	return loadConfigFile(name, name)
+}
+
+// loadConfigFile is like LoadConfigFile except that it can use a different
+// name for the file in source locations than the path it is read from, so
+// that files extracted from archives can be described in terms of the
+// archive they came from.
+func loadConfigFile(path, name string) (*File, hcl.Diagnostics) {
 	var diags hcl.Diagnostics
 	file := newFile()
 
@@ -39,13 +88,22 @@ func LoadConfigFile(name string) (*File, hcl.Diagnostics) {
 
 	var rawFile *hcl.File
 	switch {
-	case strings.HasSuffix(name, ".nv.hcl"):
-		var moreDiags hcl.Diagnostics
-		rawFile, moreDiags = parser.ParseHCLFile(name)
-		diags = append(diags, moreDiags...)
-	case strings.HasSuffix(name, ".nv.json"):
+	case strings.HasSuffix(name, ".nv.hcl"), strings.HasSuffix(name, ".nv.json"):
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The configuration file %q could not be read: %s.", name, err),
+			})
+			return file, diags
+		}
 		var moreDiags hcl.Diagnostics
-		rawFile, moreDiags = parser.ParseJSONFile(name)
+		if strings.HasSuffix(name, ".nv.hcl") {
+			rawFile, moreDiags = parser.ParseHCL(src, name)
+		} else {
+			rawFile, moreDiags = parser.ParseJSON(src, name)
+		}
 		diags = append(diags, moreDiags...)
 	default:
 		diags = diags.Append(&hcl.Diagnostic{
@@ -81,6 +139,31 @@ func LoadConfigFile(name string) (*File, hcl.Diagnostics) {
 			file.SharedObjects = append(file.SharedObjects, so)
 			diags = append(diags, moreDiags...)
 
+		case "module":
+			mod, moreDiags := decodeModuleBlock(block)
+			file.Modules = append(file.Modules, mod)
+			diags = append(diags, moreDiags...)
+
+		case "variable":
+			v, moreDiags := decodeVariableBlock(block)
+			file.Variables = append(file.Variables, v)
+			diags = append(diags, moreDiags...)
+
+		case "auto_env":
+			ae, moreDiags := decodeAutoEnvBlock(block)
+			file.AutoEnvs = append(file.AutoEnvs, ae)
+			diags = append(diags, moreDiags...)
+
+		case "policy":
+			p, moreDiags := decodePolicyBlock(block)
+			file.Policies = append(file.Policies, p)
+			diags = append(diags, moreDiags...)
+
+		case "profile":
+			p, moreDiags := decodeProfileBlock(block)
+			file.Profiles = append(file.Profiles, p)
+			diags = append(diags, moreDiags...)
+
 		default:
 			// Should never get here because Body.Content should ensure
 			// everything fits our schema and the above cases should cover
@@ -94,9 +177,14 @@ func LoadConfigFile(name string) (*File, hcl.Diagnostics) {
 
 var configFileSchema = &hcl.BodySchema{
 	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "auto_env"},
 		{Type: "command", LabelNames: []string{"name"}},
 		{Type: "helper", LabelNames: []string{"type", "name"}},
+		{Type: "module", LabelNames: []string{"name"}},
+		{Type: "policy", LabelNames: []string{"name"}},
+		{Type: "profile", LabelNames: []string{"name"}},
 		{Type: "service", LabelNames: []string{"name"}},
 		{Type: "shared", LabelNames: []string{"name"}},
+		{Type: "variable", LabelNames: []string{"name"}},
 	},
 }
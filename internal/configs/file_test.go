@@ -1,9 +1,12 @@
 package configs
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/zclconf/go-cty/cty"
 )
 
 func TestLoadConfigFile(t *testing.T) {
@@ -27,6 +30,66 @@ func TestLoadConfigFile(t *testing.T) {
 		if got, want := cmd.Name, "terraform"; got != want {
 			t.Errorf("wrong name %q; want %q", got, want)
 		}
+		if !cmd.PTY {
+			t.Errorf("pty is false; want true")
+		}
+		if got, want := len(cmd.Args), 2; got != want {
+			t.Fatalf("wrong number of args %d; want %d", got, want)
+		}
+		if got, want := cmd.Args[0].Short, "w"; got != want {
+			t.Errorf("wrong short name %q; want %q", got, want)
+		}
+		if got, want := cmd.Args[0].Default, cty.StringVal("default"); !got.RawEquals(want) {
+			t.Errorf("wrong default %#v; want %#v", got, want)
+		}
+		if got, want := cmd.Args[1].Type, cty.Bool; !got.Equals(want) {
+			t.Errorf("wrong type %#v; want %#v", got, want)
+		}
+
+		term := cmd.Termination
+		if got, want := term.Signal, "SIGINT"; got != want {
+			t.Errorf("wrong termination signal %q; want %q", got, want)
+		}
+		if got, want := term.GracePeriod, 3*time.Second; got != want {
+			t.Errorf("wrong grace period %s; want %s", got, want)
+		}
+		if got, want := term.ForwardSignals, []string{"SIGHUP", "SIGWINCH"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong forwarded signals %#v; want %#v", got, want)
+		}
+		if !term.ProcessGroup {
+			t.Errorf("process_group is false; want true")
+		}
+
+		policy := cmd.RestartPolicy
+		if got, want := policy.Mode, RestartOnFailure; got != want {
+			t.Errorf("wrong restart mode %s; want %s", got, want)
+		}
+		if got, want := policy.MaxRetries, 3; got != want {
+			t.Errorf("wrong max retries %d; want %d", got, want)
+		}
+		if got, want := policy.Backoff, 500*time.Millisecond; got != want {
+			t.Errorf("wrong backoff %s; want %s", got, want)
+		}
+		if got, want := policy.MaxBackoff, DefaultRestartMaxBackoff; got != want {
+			t.Errorf("wrong max backoff %s; want %s", got, want)
+		}
+
+		sandbox := cmd.Sandbox
+		if got, want := sandbox.Limits["open_files"], uint64(1024); got != want {
+			t.Errorf("wrong open_files limit %d; want %d", got, want)
+		}
+		if got, want := sandbox.Limits["cpu_time"], LimitUnlimited; got != want {
+			t.Errorf("wrong cpu_time limit %d; want %d", got, want)
+		}
+		if sandbox.Umask == nil || *sandbox.Umask != 0027 {
+			t.Errorf("wrong umask %v; want 0027", sandbox.Umask)
+		}
+		if !sandbox.NoNewPrivs {
+			t.Errorf("no_new_privs is false; want true")
+		}
+		if got, want := sandbox.User, "nobody"; got != want {
+			t.Errorf("wrong user %q; want %q", got, want)
+		}
 	})
 	t.Run("helper", func(t *testing.T) {
 		f, diags := LoadConfigFile("testdata/helper.nv.hcl")
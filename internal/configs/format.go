@@ -0,0 +1,30 @@
+package configs
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hclwrite"
+)
+
+// FormatFile returns the source of the configuration file with the given
+// name rewritten in the canonical layout for HCL native syntax, with
+// consistent indentation and aligned equals signs.
+//
+// Files in the JSON syntax, with the suffix ".nv.json", are returned
+// unchanged. Native syntax files must be syntactically valid in order to
+// be formatted, because the formatter works on tokens and could otherwise
+// make an invalid file even harder to read; if there are errors, the
+// result is the unchanged source along with the error diagnostics. The
+// file's contents are not otherwise validated.
+func FormatFile(name string, src []byte) ([]byte, hcl.Diagnostics) {
+	if !strings.HasSuffix(name, ".nv.hcl") {
+		return src, nil
+	}
+	_, diags := hclsyntax.ParseConfig(src, name, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return src, diags
+	}
+	return hclwrite.Format(src), diags
+}
@@ -0,0 +1,51 @@
+package configs
+
+import (
+	"testing"
+)
+
+func TestFormatFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr bool
+	}{
+		{
+			"main.nv.hcl",
+			"command \"deploy\" {\nexec = [\"deploy\"]\n  environment = {\n  A=\"a\"\n  LONGER = \"b\"\n  }\n}\n",
+			"command \"deploy\" {\n  exec = [\"deploy\"]\n  environment = {\n    A      = \"a\"\n    LONGER = \"b\"\n  }\n}\n",
+			false,
+		},
+		{
+			"formatted.nv.hcl",
+			"command \"deploy\" {\n  exec = [\"deploy\"]\n}\n",
+			"command \"deploy\" {\n  exec = [\"deploy\"]\n}\n",
+			false,
+		},
+		{
+			"invalid.nv.hcl",
+			"command \"deploy\" {\nexec = [\n",
+			"command \"deploy\" {\nexec = [\n",
+			true,
+		},
+		{
+			"main.nv.json",
+			"{\"command\":{\"deploy\":{\"exec\":[\"deploy\"]}}}",
+			"{\"command\":{\"deploy\":{\"exec\":[\"deploy\"]}}}",
+			false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, diags := FormatFile(test.name, []byte(test.src))
+			if gotErr := diags.HasErrors(); gotErr != test.wantErr {
+				t.Fatalf("wrong error result %t; want %t (%s)", gotErr, test.wantErr, diags.Error())
+			}
+			if string(got) != test.want {
+				t.Errorf("wrong result\ngot:\n%s\nwant:\n%s", got, test.want)
+			}
+		})
+	}
+}
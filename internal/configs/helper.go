@@ -6,6 +6,7 @@ import (
 	"envy.pw/cli/internal/addrs"
 
 	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcldec"
 )
 
 // Helper represents a single "helper" block in a configuration.
@@ -14,15 +15,56 @@ type Helper struct {
 	Name      string
 	DeclRange hcl.Range
 
+	// Layer is the configuration layer that the helper was loaded from, or
+	// nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
+	// Module is the module that declared the helper, or nil if the helper
+	// was declared directly in the configuration.
+	Module *Module
+
 	Body hcl.Body
 }
 
 // Addr returns the address for the helper that was declared.
 func (h *Helper) Addr() addrs.Helper {
-	return addrs.Helper{
+	addr := addrs.Helper{
 		Type: h.Type,
 		Name: h.Name,
 	}
+	if h.Module != nil {
+		addr.Module = h.Module.Name
+	}
+	return addr
+}
+
+// Origin describes where the helper was declared, for use in messages.
+func (h *Helper) Origin() string {
+	if h.Module != nil {
+		return fmt.Sprintf("%s in the %s", h.Module, h.Layer)
+	}
+	return "the " + h.Layer.String()
+}
+
+// AllReferences returns all of the references made from the body of the
+// helper, when interpreted using the given specification.
+//
+// The specification is a parameter because the valid content of a helper
+// block is decided by the helper type, rather than by this package.
+//
+// References made from within a module are returned as absolute addresses,
+// so a reference to another helper in the same module includes the module
+// name.
+func (h *Helper) AllReferences(spec hcldec.Spec) []Reference {
+	refs := traversalsReferences(hcldec.Variables(h.Body, spec))
+	if h.Module != nil {
+		for i, ref := range refs {
+			if addr, ok := ref.Addr.(addrs.Helper); ok && addr.Module == "" {
+				refs[i].Addr = addr.InModule(h.Module.Name)
+			}
+		}
+	}
+	return refs
 }
 
 func decodeHelperBlock(block *hcl.Block) (*Helper, hcl.Diagnostics) {
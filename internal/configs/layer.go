@@ -0,0 +1,311 @@
+package configs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/logging"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// ProjectConfigDirName is the name of the directories that contain
+// project-specific configuration, which are discovered by searching upwards
+// from the working directory.
+const ProjectConfigDirName = ".envy"
+
+// Layer represents one of the directories that a layered configuration was
+// loaded from.
+type Layer struct {
+	// Name is a short description of the role of the layer, like
+	// "user configuration", for use in messages.
+	Name string
+
+	// Dir is the directory the layer was loaded from, which is also the
+	// directory that any paths in the layer are relative to.
+	Dir string
+
+	// ConfineModules, if set, requires the sources of any modules declared
+	// in the layer to be within Dir, so that a check of the contents of
+	// the directory, such as whether the user trusts it, covers them too.
+	ConfineModules bool
+}
+
+// UserLayer returns a layer representing the user's own configuration
+// directory, which has the lowest precedence.
+func UserLayer(dir string) *Layer {
+	return &Layer{
+		Name: "user configuration",
+		Dir:  dir,
+	}
+}
+
+// projectLayerName is the name of all project configuration layers.
+const projectLayerName = "project configuration"
+
+// ProjectLayer returns a layer representing a project-specific configuration
+// directory.
+func ProjectLayer(dir string) *Layer {
+	return &Layer{
+		Name: projectLayerName,
+		Dir:  dir,
+	}
+}
+
+// IsProject returns true if the receiver is a project configuration layer,
+// as returned by ProjectLayer.
+func (l *Layer) IsProject() bool {
+	return l != nil && l.Name == projectLayerName
+}
+
+func (l *Layer) String() string {
+	if l == nil {
+		return "configuration"
+	}
+	return fmt.Sprintf("%s in %s", l.Name, l.Dir)
+}
+
+// FindProjectLayers searches the given directory and its ancestors for
+// project configuration directories, stopping at the root of the repository
+// containing the given directory, which is the nearest directory that has
+// a ".git" entry. If the directory is not within a repository then the
+// search continues up to the root of the filesystem.
+//
+// The result is in order of increasing precedence, so the directory
+// closest to the given directory appears last.
+func FindProjectLayers(dir string) []*Layer {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil
+	}
+
+	var found []*Layer
+	for {
+		candidate := filepath.Join(dir, ProjectConfigDirName)
+		if info, err := os.Stat(candidate); err == nil && info.IsDir() {
+			found = append(found, ProjectLayer(candidate))
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break // reached the root of the repository
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break // reached the root of the filesystem
+		}
+		dir = parent
+	}
+
+	// We found the layers from innermost to outermost, but precedence is
+	// the other way around.
+	for i, j := 0, len(found)-1; i < j; i, j = i+1, j-1 {
+		found[i], found[j] = found[j], found[i]
+	}
+	return found
+}
+
+// LayersFingerprint returns a string that changes whenever a configuration
+// file or input variable values file is added to, removed from or modified
+// in any of the given layers, so that callers can cheaply detect whether a
+// configuration they loaded earlier might have changed since.
+//
+// The fingerprint is based on file sizes and modification times rather than
+// content, and doesn't cover the files of any modules.
+func LayersFingerprint(layers ...*Layer) string {
+	h := sha256.New()
+	for _, layer := range layers {
+		fmt.Fprintf(h, "%s\x00", layer.Dir)
+		items, err := ioutil.ReadDir(layer.Dir)
+		if err != nil {
+			continue // the layer will be reported as missing when loaded
+		}
+		// ReadDir returns the items sorted by name, so the result is stable.
+		for _, item := range items {
+			name := item.Name()
+			if item.IsDir() || !(IsConfigFile(name) || name == VariableValuesFileName) {
+				continue
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00%d\x00", name, item.Size(), item.ModTime().UnixNano())
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LoadLayeredConfig loads the configuration files from each of the given
+// layers, which must be given in order of increasing precedence, and merges
+// them into a single Config.
+//
+// Within a single layer, declaring the same object more than once is an
+// error, just as for LoadConfig. An object declared in more than one layer
+// is instead taken entirely from the layer with the highest precedence,
+// though override files in a layer can modify objects from any layer with
+// lower precedence. Policies are the exception: declaring a policy with the
+// same name as one from a lower layer is an error, so that a layer can't
+// weaken the restrictions of those below it.
+//
+// The BaseDir of the result is the directory of the first layer, but each
+// object's paths are relative to the directory of the layer that it came
+// from, as returned by Config.ObjectBaseDir.
+func LoadLayeredConfig(layers ...*Layer) (*Config, hcl.Diagnostics) {
+	return LoadCheckedLayeredConfig(nil, layers...)
+}
+
+// LayerCheck decides whether the configuration files of a layer may be
+// loaded, returning error diagnostics explaining why not if they may not.
+type LayerCheck func(layer *Layer) hcl.Diagnostics
+
+// LoadCheckedLayeredConfig is like LoadLayeredConfig, except that it first
+// calls the given check for each layer and skips loading any layer for which
+// the check returns errors, such as a project configuration that the user
+// hasn't trusted. The check's diagnostics are included in the result.
+//
+// If check is nil then every layer is loaded.
+func LoadCheckedLayeredConfig(check LayerCheck, layers ...*Layer) (*Config, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	if len(layers) == 0 {
+		// Programming error: there must always be at least one layer
+		panic("LoadLayeredConfig with no layers")
+	}
+	cfg := newConfig(layers[0].Dir)
+
+	for _, layer := range layers {
+		if check != nil {
+			moreDiags := check(layer)
+			diags = append(diags, moreDiags...)
+			if moreDiags.HasErrors() {
+				continue
+			}
+		}
+		lc := newConfig(layer.Dir)
+		overrides, moreDiags := lc.loadDir(layer)
+		diags = append(diags, moreDiags...)
+		diags = append(diags, cfg.overlay(lc)...)
+
+		// Override files can modify objects from this layer or from any
+		// of the layers with lower precedence.
+		for _, file := range overrides {
+			diags = append(diags, cfg.applyOverrides(file)...)
+		}
+	}
+
+	return cfg, diags
+}
+
+// overlay adds all of the objects from the given other configuration to the
+// receiver, replacing any existing objects with the same addresses other
+// than policies, which are reported as conflicts instead.
+func (c *Config) overlay(other *Config) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	c.Layers = append(c.Layers, other.Layers...)
+	for addr, obj := range other.Commands {
+		if existing, exists := c.Commands[addr]; exists {
+			logging.Debug("configuration object overridden", "addr", addr, "layer", obj.Layer, "previous", existing.Layer)
+		}
+		c.Commands[addr] = obj
+	}
+	// Modules are overlaid before helpers so that the helpers of a replaced
+	// module can be removed before the new module's helpers are added.
+	for name, obj := range other.Modules {
+		if existing, exists := c.Modules[name]; exists {
+			logging.Debug("configuration object overridden", "addr", "module."+name, "layer", obj.Layer, "previous", existing.Layer)
+			// The helpers from the overridden module must not remain
+			// alongside those of the new module.
+			c.removeModuleHelpers(name)
+		}
+		c.Modules[name] = obj
+	}
+	for addr, obj := range other.Helpers {
+		if existing, exists := c.Helpers[addr]; exists {
+			logging.Debug("configuration object overridden", "addr", addr, "layer", obj.Layer, "previous", existing.Layer)
+		}
+		c.Helpers[addr] = obj
+	}
+	for addr, obj := range other.SharedObjects {
+		if existing, exists := c.SharedObjects[addr]; exists {
+			logging.Debug("configuration object overridden", "addr", addr, "layer", obj.Layer, "previous", existing.Layer)
+		}
+		c.SharedObjects[addr] = obj
+	}
+	for addr, obj := range other.Variables {
+		if existing, exists := c.Variables[addr]; exists {
+			logging.Debug("configuration object overridden", "addr", addr, "layer", obj.Layer, "previous", existing.Layer)
+		}
+		c.Variables[addr] = obj
+	}
+	for name, obj := range other.Profiles {
+		if existing, exists := c.Profiles[name]; exists {
+			logging.Debug("configuration object overridden", "addr", "profile."+name, "layer", obj.Layer, "previous", existing.Layer)
+		}
+		c.Profiles[name] = obj
+	}
+	for name, obj := range other.Policies {
+		if existing, exists := c.Policies[name]; exists {
+			// Replacing a policy could remove a restriction that a lower
+			// layer relies on, so the lower layer's policy always wins.
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Policy name conflict",
+				Detail:   fmt.Sprintf("A policy named %q was already declared in the %s at %s. Configuration of higher precedence cannot replace a policy, so that it can't weaken the restrictions of the configuration below it.", name, existing.Layer, existing.DeclRange),
+				Subject:  obj.DeclRange.Ptr(),
+			})
+			continue
+		}
+		c.Policies[name] = obj
+	}
+	if other.AutoEnv != nil {
+		if c.AutoEnv != nil {
+			logging.Debug("configuration object overridden", "addr", other.AutoEnv.Addr(), "layer", other.AutoEnv.Layer, "previous", c.AutoEnv.Layer)
+		}
+		c.AutoEnv = other.AutoEnv
+	}
+	return diags
+}
+
+// ObjectLayer returns the layer that the object with the given address was
+// loaded from, or nil if there is no such object or it didn't come from a
+// layer.
+func (c *Config) ObjectLayer(addr addrs.Referenceable) *Layer {
+	switch addr := addr.(type) {
+	case addrs.Command:
+		if obj, ok := c.Commands[addr]; ok {
+			return obj.Layer
+		}
+	case addrs.Helper:
+		if obj, ok := c.Helpers[addr]; ok {
+			return obj.Layer
+		}
+	case addrs.SharedObject:
+		if obj, ok := c.SharedObjects[addr]; ok {
+			return obj.Layer
+		}
+	case addrs.Variable:
+		if obj, ok := c.Variables[addr]; ok {
+			return obj.Layer
+		}
+	case addrs.AutoEnv:
+		if c.AutoEnv != nil {
+			return c.AutoEnv.Layer
+		}
+	}
+	return nil
+}
+
+// ObjectBaseDir returns the directory that any configuration-relative paths
+// for the object with the given address must be resolved relative to.
+//
+// For helpers that belong to a module, that is the module's directory.
+func (c *Config) ObjectBaseDir(addr addrs.Referenceable) string {
+	if addr, ok := addr.(addrs.Helper); ok && addr.Module != "" {
+		if m, ok := c.Modules[addr.Module]; ok && m.Dir != "" {
+			return m.Dir
+		}
+	}
+	if layer := c.ObjectLayer(addr); layer != nil {
+		return layer.Dir
+	}
+	return c.BaseDir
+}
@@ -0,0 +1,189 @@
+package configs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+func TestLoadLayeredConfig(t *testing.T) {
+	root, err := ioutil.TempDir("", "envy-layers-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	root, _ = filepath.EvalSymlinks(root)
+
+	// root/user is the user configuration, while root/repo is a repository
+	// with project configuration at its root and in its "sub" directory.
+	// root/.envy is outside of the repository and so must be ignored.
+	files := map[string]string{
+		"user/main.nv.hcl":          `command "a" { exec = ["user"] }` + "\n" + `command "b" { exec = ["user"] }`,
+		"repo/.git/HEAD":            "",
+		"repo/.envy/main.nv.hcl":    `command "b" { exec = ["repo"] }` + "\n" + `command "c" { exec = ["repo"] }`,
+		"repo/sub/.envy/sub.nv.hcl": `command "c" { exec = ["sub"] }`,
+		".envy/outside.nv.hcl":      `command "d" { exec = ["outside"] }`,
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	projectLayers := FindProjectLayers(filepath.Join(root, "repo", "sub"))
+	var gotDirs []string
+	for _, layer := range projectLayers {
+		gotDirs = append(gotDirs, layer.Dir)
+	}
+	wantDirs := []string{
+		filepath.Join(root, "repo", ".envy"),
+		filepath.Join(root, "repo", "sub", ".envy"),
+	}
+	if !reflect.DeepEqual(gotDirs, wantDirs) {
+		t.Fatalf("wrong project layers\ngot:  %#v\nwant: %#v", gotDirs, wantDirs)
+	}
+
+	userLayer := UserLayer(filepath.Join(root, "user"))
+	cfg, diags := LoadLayeredConfig(append([]*Layer{userLayer}, projectLayers...)...)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	wantLayers := map[string]*Layer{
+		"a": userLayer,
+		"b": projectLayers[0],
+		"c": projectLayers[1],
+	}
+	if got, want := len(cfg.Commands), len(wantLayers); got != want {
+		t.Errorf("wrong number of commands %d; want %d", got, want)
+	}
+	for name, want := range wantLayers {
+		addr := addrs.MakeCommand(name)
+		if got := cfg.ObjectLayer(addr); got != want {
+			t.Errorf("wrong layer for %s\ngot:  %s\nwant: %s", addr, got, want)
+		}
+		if got, want := cfg.ObjectBaseDir(addr), want.Dir; got != want {
+			t.Errorf("wrong base dir for %s %q; want %q", addr, got, want)
+		}
+	}
+
+	t.Run("checked", func(t *testing.T) {
+		rejected := projectLayers[1]
+		cfg, diags := LoadCheckedLayeredConfig(func(layer *Layer) hcl.Diagnostics {
+			if layer != rejected {
+				return nil
+			}
+			return hcl.Diagnostics{{
+				Severity: hcl.DiagError,
+				Summary:  "Untrusted",
+			}}
+		}, append([]*Layer{userLayer}, projectLayers...)...)
+		if got, want := len(diags), 1; got != want {
+			t.Fatalf("wrong number of diagnostics %d; want %d", got, want)
+		}
+		if got, want := diags[0].Summary, "Untrusted"; got != want {
+			t.Errorf("wrong diagnostic %q; want %q", got, want)
+		}
+		addr := addrs.MakeCommand("c")
+		if got, want := cfg.ObjectLayer(addr), projectLayers[0]; got != want {
+			t.Errorf("wrong layer for %s\ngot:  %s\nwant: %s", addr, got, want)
+		}
+	})
+}
+
+func TestLoadLayeredConfigPolicies(t *testing.T) {
+	root, err := ioutil.TempDir("", "envy-layers-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	write := func(name, content string) *Layer {
+		dir := filepath.Join(root, name)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "main.nv.hcl"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return ProjectLayer(dir)
+	}
+	lower := write("lower", `
+policy "prod_deploy_only" {
+  effect = allow
+  from   = ["command.deploy"]
+  to     = ["aws_assume_role.prod"]
+}
+`)
+
+	deploy := addrs.MakeCommand("deploy")
+	console := addrs.MakeCommand("console")
+	prod := addrs.MakeHelper("aws_assume_role", "prod")
+
+	t.Run("wider allow", func(t *testing.T) {
+		higher := write("wider", `
+policy "prod_any_command" {
+  effect = allow
+  from   = ["command.*"]
+  to     = ["aws_assume_role.prod"]
+}
+`)
+		cfg, diags := LoadLayeredConfig(lower, higher)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		if p := cfg.CheckReference(console, console, prod); p == nil || p.Name != "prod_deploy_only" {
+			t.Errorf("a higher layer's allow policy extended a lower layer's; got %v", p)
+		}
+		if p := cfg.CheckReference(deploy, deploy, prod); p != nil {
+			t.Errorf("reference allowed by both layers was rejected by %q", p.Name)
+		}
+	})
+
+	t.Run("narrower allow", func(t *testing.T) {
+		higher := write("narrower", `
+policy "prod_nothing" {
+  effect = allow
+  from   = ["command.none"]
+  to     = ["aws_assume_role.prod"]
+}
+`)
+		cfg, diags := LoadLayeredConfig(lower, higher)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		if p := cfg.CheckReference(deploy, deploy, prod); p == nil || p.Name != "prod_nothing" {
+			t.Errorf("a higher layer couldn't add a restriction; got %v", p)
+		}
+	})
+
+	t.Run("same name", func(t *testing.T) {
+		higher := write("same", `
+policy "prod_deploy_only" {
+  effect = allow
+  from   = ["command.*"]
+  to     = ["aws_assume_role.prod"]
+}
+`)
+		cfg, diags := LoadLayeredConfig(lower, higher)
+		if !diags.HasErrors() {
+			t.Fatalf("no errors; want a policy name conflict")
+		}
+		if got, want := diags[0].Summary, "Policy name conflict"; got != want {
+			t.Errorf("wrong error summary %q; want %q", got, want)
+		}
+		if got, want := cfg.Policies["prod_deploy_only"].Layer, lower; got != want {
+			t.Errorf("wrong layer for the policy %s; want %s", got, want)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// mergedBody is an hcl.Body that combines a base body with an override body
+// from an override file.
+//
+// Any attribute present in the override body replaces the attribute of the
+// same name in the base body. If the override body has any blocks of a
+// particular type, they replace all of the blocks of that type in the base
+// body.
+type mergedBody struct {
+	Base     hcl.Body
+	Override hcl.Body
+}
+
+var _ hcl.Body = mergedBody{}
+
+func mergeBodies(base, override hcl.Body) hcl.Body {
+	return mergedBody{
+		Base:     base,
+		Override: override,
+	}
+}
+
+func (b mergedBody) Content(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	baseContent, moreDiags := b.Base.Content(schema)
+	diags = append(diags, moreDiags...)
+	overrideContent, moreDiags := b.Override.Content(overrideSchema(schema))
+	diags = append(diags, moreDiags...)
+	return mergeContent(baseContent, overrideContent), diags
+}
+
+func (b mergedBody) PartialContent(schema *hcl.BodySchema) (*hcl.BodyContent, hcl.Body, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	baseContent, baseRemain, moreDiags := b.Base.PartialContent(schema)
+	diags = append(diags, moreDiags...)
+	overrideContent, overrideRemain, moreDiags := b.Override.PartialContent(overrideSchema(schema))
+	diags = append(diags, moreDiags...)
+	return mergeContent(baseContent, overrideContent), mergeBodies(baseRemain, overrideRemain), diags
+}
+
+func (b mergedBody) JustAttributes() (hcl.Attributes, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	baseAttrs, moreDiags := b.Base.JustAttributes()
+	diags = append(diags, moreDiags...)
+	overrideAttrs, moreDiags := b.Override.JustAttributes()
+	diags = append(diags, moreDiags...)
+	return mergeAttributes(baseAttrs, overrideAttrs), diags
+}
+
+func (b mergedBody) MissingItemRange() hcl.Range {
+	return b.Base.MissingItemRange()
+}
+
+// overrideSchema returns a copy of the given schema where all of the
+// attributes are optional, because an override body needs to include only
+// the attributes it is overriding.
+func overrideSchema(schema *hcl.BodySchema) *hcl.BodySchema {
+	ret := &hcl.BodySchema{
+		Attributes: make([]hcl.AttributeSchema, len(schema.Attributes)),
+		Blocks:     schema.Blocks,
+	}
+	for i, attrS := range schema.Attributes {
+		attrS.Required = false
+		ret.Attributes[i] = attrS
+	}
+	return ret
+}
+
+func mergeContent(base, override *hcl.BodyContent) *hcl.BodyContent {
+	ret := &hcl.BodyContent{
+		Attributes:       mergeAttributes(base.Attributes, override.Attributes),
+		MissingItemRange: base.MissingItemRange,
+	}
+
+	overriddenBlockTypes := make(map[string]struct{})
+	for _, block := range override.Blocks {
+		overriddenBlockTypes[block.Type] = struct{}{}
+	}
+	for _, block := range base.Blocks {
+		if _, overridden := overriddenBlockTypes[block.Type]; !overridden {
+			ret.Blocks = append(ret.Blocks, block)
+		}
+	}
+	ret.Blocks = append(ret.Blocks, override.Blocks...)
+
+	return ret
+}
+
+func mergeAttributes(base, override hcl.Attributes) hcl.Attributes {
+	ret := make(hcl.Attributes, len(base)+len(override))
+	for name, attr := range base {
+		ret[name] = attr
+	}
+	for name, attr := range override {
+		ret[name] = attr
+	}
+	return ret
+}
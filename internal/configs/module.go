@@ -0,0 +1,251 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"envy.pw/cli/internal/logging"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// Module represents a single "module" block in a configuration, which loads
+// the helpers declared in the configuration files of another directory into
+// a namespace named after the module.
+//
+// Helpers in a module are referenced from elsewhere in the configuration
+// as module.NAME.TYPE.NAME, while expressions within the module refer to
+// the module's own helpers as TYPE.NAME, as usual.
+type Module struct {
+	Name      string
+	DeclRange hcl.Range
+
+	// Source is the location of the module's files, as given in the
+	// configuration. It is either a directory or a ".tar.gz", ".tgz" or
+	// ".zip" archive, relative to the directory of the configuration
+	// declaring the module.
+	Source string
+
+	// Layer is the configuration layer that declared the module, or nil
+	// if it wasn't loaded as part of a directory.
+	Layer *Layer
+
+	// Dir is the directory that the module's files were loaded from, which
+	// for an archive is a cache directory that the archive was extracted
+	// into. It is empty until the module has been loaded.
+	Dir string
+}
+
+func (m *Module) String() string {
+	return fmt.Sprintf("module %q from %s", m.Name, m.Source)
+}
+
+func decodeModuleBlock(block *hcl.Block) (*Module, hcl.Diagnostics) {
+	m := &Module{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+	}
+
+	type DecodeModule struct {
+		Source string `hcl:"source"`
+	}
+	var decMod DecodeModule
+	diags := gohcl.DecodeBody(block.Body, nil, &decMod)
+	m.Source = decMod.Source
+
+	if !validName(m.Name) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid module name",
+			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+
+	return m, diags
+}
+
+// loadModules loads the helpers for each of the receiver's modules that
+// hasn't been loaded yet.
+func (c *Config) loadModules() hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	for _, m := range c.Modules {
+		if m.Dir == "" {
+			diags = append(diags, c.loadModule(m)...)
+		}
+	}
+	return diags
+}
+
+// loadModule finds the files for the given module and then adds the helpers
+// they declare to the receiver, in the module's namespace.
+func (c *Config) loadModule(m *Module) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	baseDir := c.BaseDir
+	if m.Layer != nil {
+		baseDir = m.Layer.Dir
+	}
+	source := m.Source
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(baseDir, source)
+	}
+	logging.Debug("loading module", "module", m.Name, "source", source)
+
+	info, err := os.Stat(source)
+	if err != nil {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Module not found",
+			Detail:   fmt.Sprintf("Cannot find the source for module %q: %s.", m.Name, err),
+			Subject:  m.DeclRange.Ptr(),
+		})
+		return diags
+	}
+
+	if m.Layer != nil && m.Layer.ConfineModules && !pathWithin(m.Layer.Dir, source) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Module source outside configuration directory",
+			Detail:   fmt.Sprintf("The source for module %q must be within %s, because only the files in that directory were checked before loading it.", m.Name, m.Layer.Dir),
+			Subject:  m.DeclRange.Ptr(),
+		})
+		return diags
+	}
+
+	// displayPrefix is used to describe the module's files in source
+	// locations, which for archives is in terms of the archive rather than
+	// the directory we extracted it into.
+	var displayPrefix string
+	switch {
+	case info.IsDir():
+		m.Dir = source
+		displayPrefix = source
+	case isModuleArchive(source):
+		var root string
+		root, m.Dir, err = extractModuleArchive(source)
+		if err != nil {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid module archive",
+				Detail:   fmt.Sprintf("Cannot extract the archive for module %q: %s.", m.Name, err),
+				Subject:  m.DeclRange.Ptr(),
+			})
+			return diags
+		}
+		rel, _ := filepath.Rel(root, m.Dir)
+		displayPrefix = filepath.Join(source, rel)
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid module source",
+			Detail:   fmt.Sprintf("The source for module %q must be either a directory or a .tar.gz, .tgz or .zip archive.", m.Name),
+			Subject:  m.DeclRange.Ptr(),
+		})
+		return diags
+	}
+
+	items, err := ioutil.ReadDir(m.Dir)
+	if err != nil {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Cannot open module directory",
+			Detail:   fmt.Sprintf("Cannot read the files for module %q: %s.", m.Name, err),
+			Subject:  m.DeclRange.Ptr(),
+		})
+		return diags
+	}
+
+	helpers := make(map[string]*Helper)
+	for _, item := range items {
+		if item.IsDir() || !IsConfigFile(item.Name()) {
+			continue
+		}
+		path := filepath.Join(m.Dir, item.Name())
+		name := filepath.Join(displayPrefix, item.Name())
+		logging.Debug("loading configuration file", "file", name, "module", m.Name)
+		file, moreDiags := loadConfigFile(path, name)
+		diags = append(diags, moreDiags...)
+
+		for _, cmd := range file.Commands {
+			diags = diags.Append(unsupportedInModule("command", cmd.DeclRange))
+		}
+		for _, so := range file.SharedObjects {
+			diags = diags.Append(unsupportedInModule("shared", so.DeclRange))
+		}
+		for _, nested := range file.Modules {
+			diags = diags.Append(unsupportedInModule("module", nested.DeclRange))
+		}
+		for _, v := range file.Variables {
+			diags = diags.Append(unsupportedInModule("variable", v.DeclRange))
+		}
+		for _, ae := range file.AutoEnvs {
+			diags = diags.Append(unsupportedInModule("auto_env", ae.DeclRange))
+		}
+		for _, p := range file.Profiles {
+			diags = diags.Append(unsupportedInModule("profile", p.DeclRange))
+		}
+		for _, p := range file.Policies {
+			diags = diags.Append(unsupportedInModule("policy", p.DeclRange))
+		}
+		for _, h := range file.Helpers {
+			h.Module = m
+			h.Layer = m.Layer
+			key := h.Type + "." + h.Name
+			if existing, exists := helpers[key]; exists {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Helper name conflict",
+					Detail:   fmt.Sprintf("A helper %q %q was already declared at %s.", h.Type, h.Name, existing.DeclRange),
+					Subject:  h.DeclRange.Ptr(),
+				})
+				continue
+			}
+			helpers[key] = h
+			c.Helpers[h.Addr()] = h
+		}
+	}
+
+	return diags
+}
+
+// pathWithin returns true if the given path is the given directory or is
+// within it, after resolving any symlinks in either.
+func pathWithin(dir, path string) bool {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// removeModuleHelpers removes from the receiver all of the helpers that
+// belong to the module with the given name.
+func (c *Config) removeModuleHelpers(name string) {
+	for addr := range c.Helpers {
+		if addr.Module == name {
+			delete(c.Helpers, addr)
+		}
+	}
+}
+
+func unsupportedInModule(blockType string, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Unsupported block in module",
+		Detail:   fmt.Sprintf("A module may only declare helpers, so %q blocks are not allowed.", blockType),
+		Subject:  rng.Ptr(),
+	}
+}
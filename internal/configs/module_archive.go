@@ -0,0 +1,176 @@
+package configs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// isModuleArchive returns true if the given filename has one of the
+// suffixes of the archive formats supported as module sources.
+func isModuleArchive(filename string) bool {
+	return strings.HasSuffix(filename, ".tar.gz") || strings.HasSuffix(filename, ".tgz") || strings.HasSuffix(filename, ".zip")
+}
+
+// extractModuleArchive extracts the given module archive into a cache
+// directory, unless it was already extracted, and returns both the
+// directory it was extracted into and the directory within it that
+// contains the module's files.
+//
+// The cache directory is named after a hash of the archive contents, so an
+// updated archive is always extracted again.
+//
+// If the archive contains nothing but a single directory then that
+// directory is assumed to contain the module, as is conventional for
+// archives.
+func extractModuleArchive(filename string) (string, string, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256(src)
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", "", fmt.Errorf("cannot find cache directory: %s", err)
+	}
+	modulesDir := filepath.Join(cacheDir, "envy", "modules")
+	root := filepath.Join(modulesDir, hex.EncodeToString(sum[:]))
+
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		if err := os.MkdirAll(modulesDir, 0700); err != nil {
+			return "", "", err
+		}
+		// We extract into a temporary directory first, and then rename it
+		// into place, so that a failed extraction can't leave a partial
+		// module in the cache.
+		tmpDir, err := ioutil.TempDir(modulesDir, ".extract-")
+		if err != nil {
+			return "", "", err
+		}
+		defer os.RemoveAll(tmpDir)
+
+		if strings.HasSuffix(filename, ".zip") {
+			err = extractZip(src, tmpDir)
+		} else {
+			err = extractTarGz(src, tmpDir)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if err := os.Rename(tmpDir, root); err != nil && !dirExists(root) {
+			return "", "", err
+		}
+	}
+
+	dir := root
+	if items, err := ioutil.ReadDir(root); err == nil && len(items) == 1 && items[0].IsDir() {
+		dir = filepath.Join(root, items[0].Name())
+	}
+	return root, dir, nil
+}
+
+func extractTarGz(src []byte, dir string) error {
+	gz, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if _, err := archiveMemberPath(dir, hdr.Name); err != nil {
+				return err
+			}
+		case tar.TypeReg, tar.TypeRegA:
+			if err := extractArchiveMember(dir, hdr.Name, tr); err != nil {
+				return err
+			}
+		default:
+			// We ignore links and other special files, since a module
+			// should only need regular files.
+		}
+	}
+}
+
+func extractZip(src []byte, dir string) error {
+	zr, err := zip.NewReader(bytes.NewReader(src), int64(len(src)))
+	if err != nil {
+		return err
+	}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() || !f.Mode().IsRegular() {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = extractArchiveMember(dir, f.Name, r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// archiveMemberPath returns the path that the archive member with the given
+// name should be extracted to, returning an error if the name would place
+// the member outside of the given directory.
+func archiveMemberPath(dir, name string) (string, error) {
+	// Archives made on Windows may use backslashes as separators, and
+	// Windows would honor them when extracting, so we treat them as
+	// separators on every platform.
+	slashed := strings.Replace(name, `\`, "/", -1)
+	native := filepath.FromSlash(slashed)
+	if strings.HasPrefix(slashed, "/") || filepath.IsAbs(native) || filepath.VolumeName(native) != "" {
+		return "", fmt.Errorf("archive member %q has an absolute path", name)
+	}
+	dest := filepath.Join(dir, native)
+	rel, err := filepath.Rel(dir, dest)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive member %q has a path outside of the archive", name)
+	}
+	return dest, nil
+}
+
+func extractArchiveMember(dir, name string, r io.Reader) error {
+	dest, err := archiveMemberPath(dir, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func dirExists(dir string) bool {
+	info, err := os.Stat(dir)
+	return err == nil && info.IsDir()
+}
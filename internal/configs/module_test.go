@@ -0,0 +1,204 @@
+package configs
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestLoadConfigModule(t *testing.T) {
+	cfg, diags := LoadConfig("testdata/module")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	modAddr := addrs.MakeHelper("vault", "db").InModule("aws")
+	h, exists := cfg.Helpers[modAddr]
+	if !exists {
+		t.Fatalf("no helper %s", modAddr)
+	}
+	if got, want := h.Module.Name, "aws"; got != want {
+		t.Errorf("wrong module name %q; want %q", got, want)
+	}
+	if got, want := cfg.ObjectBaseDir(modAddr), filepath.Join("testdata", "module", "aws"); got != want {
+		t.Errorf("wrong base dir %q; want %q", got, want)
+	}
+	if _, exists := cfg.Helpers[addrs.MakeHelper("vault", "base")]; exists {
+		t.Errorf("module helper was declared outside of the module")
+	}
+
+	spec := &hcldec.AttrSpec{Name: "role", Type: cty.String}
+	refs := h.AllReferences(spec)
+	if len(refs) != 1 {
+		t.Fatalf("wrong number of references %d; want 1", len(refs))
+	}
+	if got, want := refs[0].Addr, addrs.MakeHelper("vault", "base").InModule("aws"); got != want {
+		t.Errorf("wrong reference from module\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	refs = cfg.Helpers[addrs.MakeHelper("vault", "db")].AllReferences(spec)
+	if len(refs) != 1 {
+		t.Fatalf("wrong number of references %d; want 1", len(refs))
+	}
+	if got, want := refs[0].Addr, modAddr; got != want {
+		t.Errorf("wrong reference to module\ngot:  %#v\nwant: %#v", got, want)
+	}
+}
+
+func TestLoadConfigModuleArchive(t *testing.T) {
+	root, err := ioutil.TempDir("", "envy-module-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	oldCache := os.Getenv("XDG_CACHE_HOME")
+	os.Setenv("XDG_CACHE_HOME", filepath.Join(root, "cache"))
+	defer os.Setenv("XDG_CACHE_HOME", oldCache)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	content := []byte(`helper "vault" "db" {}`)
+	tw.WriteHeader(&tar.Header{Name: "aws/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "aws/main.nv.hcl", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))})
+	tw.Write(content)
+	tw.Close()
+	zw.Close()
+
+	files := map[string][]byte{
+		"config/main.nv.hcl": []byte(`module "aws" { source = "aws.tar.gz" }`),
+		"config/aws.tar.gz":  buf.Bytes(),
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, diags := LoadConfig(filepath.Join(root, "config"))
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	h, exists := cfg.Helpers[addrs.MakeHelper("vault", "db").InModule("aws")]
+	if !exists {
+		t.Fatalf("module helper was not loaded")
+	}
+	if got, want := h.DeclRange.Filename, filepath.Join(root, "config", "aws.tar.gz", "aws", "main.nv.hcl"); got != want {
+		t.Errorf("wrong source filename %q; want %q", got, want)
+	}
+}
+
+func TestLoadConfigModuleUnsupportedBlock(t *testing.T) {
+	root, err := ioutil.TempDir("", "envy-module-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	os.MkdirAll(filepath.Join(root, "mod"), 0755)
+	ioutil.WriteFile(filepath.Join(root, "main.nv.hcl"), []byte(`module "m" { source = "mod" }`), 0644)
+	ioutil.WriteFile(filepath.Join(root, "mod", "main.nv.hcl"), []byte(`command "a" { exec = ["a"] }`), 0644)
+
+	_, diags := LoadConfig(root)
+	if !diags.HasErrors() {
+		t.Fatalf("no errors; want an error about the command block")
+	}
+	if got, want := diags[0].Summary, "Unsupported block in module"; got != want {
+		t.Errorf("wrong error summary %q; want %q", got, want)
+	}
+}
+
+func TestLoadConfigModuleConfined(t *testing.T) {
+	root, err := ioutil.TempDir("", "envy-module-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	layerDir := filepath.Join(root, ".envy")
+	os.MkdirAll(filepath.Join(layerDir, "inside"), 0755)
+	os.MkdirAll(filepath.Join(root, "shared"), 0755)
+	os.Symlink(filepath.Join(root, "shared"), filepath.Join(layerDir, "link"))
+	ioutil.WriteFile(filepath.Join(layerDir, "inside", "main.nv.hcl"), []byte(`helper "template" "a" { content = "a" }`), 0644)
+	ioutil.WriteFile(filepath.Join(root, "shared", "main.nv.hcl"), []byte(`helper "template" "b" { content = "b" }`), 0644)
+
+	tests := []struct {
+		source  string
+		wantErr bool
+	}{
+		{"inside", false},
+		{"../shared", true},
+		{filepath.Join(root, "shared"), true},
+		{"link", true},
+	}
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			ioutil.WriteFile(filepath.Join(layerDir, "main.nv.hcl"), []byte(fmt.Sprintf("module \"m\" { source = %q }", test.source)), 0644)
+
+			layer := ProjectLayer(layerDir)
+			layer.ConfineModules = true
+			_, diags := LoadLayeredConfig(layer)
+			if got := diags.HasErrors(); got != test.wantErr {
+				t.Fatalf("wrong error result %t; want %t (%s)", got, test.wantErr, diags.Error())
+			}
+			if test.wantErr {
+				if got, want := diags[0].Summary, "Module source outside configuration directory"; got != want {
+					t.Errorf("wrong error summary %q; want %q", got, want)
+				}
+			}
+
+			// Without ConfineModules, the same source is allowed.
+			_, diags = LoadLayeredConfig(ProjectLayer(layerDir))
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors without ConfineModules: %s", diags.Error())
+			}
+		})
+	}
+}
+
+func TestArchiveMemberPath(t *testing.T) {
+	dir := filepath.Join("cache", "modules", "abc")
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"main.nv.hcl", filepath.Join(dir, "main.nv.hcl"), false},
+		{"aws/main.nv.hcl", filepath.Join(dir, "aws", "main.nv.hcl"), false},
+		{`aws\main.nv.hcl`, filepath.Join(dir, "aws", "main.nv.hcl"), false},
+		{"./aws/../main.nv.hcl", filepath.Join(dir, "main.nv.hcl"), false},
+		{"aws/", filepath.Join(dir, "aws"), false},
+		{"../main.nv.hcl", "", true},
+		{"aws/../../main.nv.hcl", "", true},
+		{`..\..\main.nv.hcl`, "", true},
+		{`aws\..\..\main.nv.hcl`, "", true},
+		{"..", "", true},
+		{"/etc/passwd", "", true},
+		{`\Windows\win.ini`, "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := archiveMemberPath(dir, test.name)
+			if gotErr := err != nil; gotErr != test.wantErr {
+				t.Fatalf("wrong error result %t; want %t (%v)", gotErr, test.wantErr, err)
+			}
+			if got != test.want {
+				t.Errorf("wrong path %q; want %q", got, test.want)
+			}
+		})
+	}
+}
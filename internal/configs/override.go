@@ -0,0 +1,134 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// IsOverrideFile returns true if the given configuration filename is for an
+// override file, whose blocks modify objects declared in other files rather
+// than declaring new objects.
+//
+// Override files are either named "override" or have names ending with
+// "_override", followed by one of the usual configuration file suffixes.
+func IsOverrideFile(name string) bool {
+	var base string
+	switch {
+	case strings.HasSuffix(name, ".nv.hcl"):
+		base = strings.TrimSuffix(name, ".nv.hcl")
+	case strings.HasSuffix(name, ".nv.json"):
+		base = strings.TrimSuffix(name, ".nv.json")
+	default:
+		return false
+	}
+	if i := strings.LastIndexAny(base, `/\`); i >= 0 {
+		base = base[i+1:]
+	}
+	return base == "override" || strings.HasSuffix(base, "_override")
+}
+
+// applyOverrides merges the blocks in the given override file into the
+// objects they override, replacing those objects in the receiver.
+//
+// Objects are never modified in-place, so that other configurations that
+// share them are unaffected.
+func (c *Config) applyOverrides(f *File) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+
+	for _, override := range f.Commands {
+		addr := override.Addr()
+		base, exists := c.Commands[addr]
+		if !exists {
+			diags = diags.Append(missingOverrideBase("command", fmt.Sprintf("command %q", override.Name), override.DeclRange))
+			continue
+		}
+		merged, moreDiags := base.merge(override)
+		diags = append(diags, moreDiags...)
+		c.Commands[addr] = merged
+	}
+
+	for _, override := range f.Helpers {
+		addr := override.Addr()
+		base, exists := c.Helpers[addr]
+		if !exists {
+			diags = diags.Append(missingOverrideBase("helper", fmt.Sprintf("helper %q %q", override.Type, override.Name), override.DeclRange))
+			continue
+		}
+		merged := *base
+		merged.Body = mergeBodies(base.Body, override.Body)
+		c.Helpers[addr] = &merged
+	}
+
+	for _, override := range f.SharedObjects {
+		addr := override.Addr()
+		base, exists := c.SharedObjects[addr]
+		if !exists {
+			diags = diags.Append(missingOverrideBase("shared object", fmt.Sprintf("shared object %q", override.Name), override.DeclRange))
+			continue
+		}
+		merged := *base
+		merged.Attributes = mergeAttributes(base.Attributes, override.Attributes)
+		c.SharedObjects[addr] = &merged
+	}
+
+	for _, override := range f.Variables {
+		addr := override.Addr()
+		base, exists := c.Variables[addr]
+		if !exists {
+			diags = diags.Append(missingOverrideBase("variable", fmt.Sprintf("variable %q", override.Name), override.DeclRange))
+			continue
+		}
+		merged, moreDiags := base.merge(override)
+		diags = append(diags, moreDiags...)
+		c.Variables[addr] = merged
+	}
+
+	for _, m := range f.Modules {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block in override file",
+			Detail:   "Modules cannot be overridden. To use a different source for a module, declare it with the same name in a configuration layer of higher precedence.",
+			Subject:  m.DeclRange.Ptr(),
+		})
+	}
+
+	for _, ae := range f.AutoEnvs {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block in override file",
+			Detail:   "The auto_env block cannot be overridden. To replace it, declare it in a configuration layer of higher precedence.",
+			Subject:  ae.DeclRange.Ptr(),
+		})
+	}
+
+	for _, p := range f.Policies {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block in override file",
+			Detail:   "Policies cannot be overridden, so that configuration of higher precedence can't weaken them. To add further restrictions, declare a new policy.",
+			Subject:  p.DeclRange.Ptr(),
+		})
+	}
+
+	for _, p := range f.Profiles {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Unsupported block in override file",
+			Detail:   "Profiles cannot be overridden. To replace a profile, declare it with the same name in a configuration layer of higher precedence.",
+			Subject:  p.DeclRange.Ptr(),
+		})
+	}
+
+	return diags
+}
+
+func missingOverrideBase(kind, desc string, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  fmt.Sprintf("Missing base %s declaration to override", kind),
+		Detail:   fmt.Sprintf("There is no %s declared in a non-override file, so there is nothing for this block to override.", desc),
+		Subject:  rng.Ptr(),
+	}
+}
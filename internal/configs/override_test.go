@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestLoadConfigOverrides(t *testing.T) {
+	cfg, diags := LoadConfig("testdata/override")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	exprString := func(expr hcl.Expression) string {
+		var s string
+		diags := gohcl.DecodeExpression(expr, nil, &s)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		return s
+	}
+	exprIsNull := func(expr hcl.Expression) bool {
+		v, diags := expr.Value(nil)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		return v.IsNull()
+	}
+
+	deploy := cfg.Commands[addrs.MakeCommand("deploy")]
+	if got, want := exprString(deploy.WorkDir), "local"; got != want {
+		t.Errorf("wrong deploy work_dir %q; want %q", got, want)
+	}
+	if exprIsNull(deploy.Executable) {
+		t.Errorf("deploy exec was lost")
+	}
+	if exprIsNull(deploy.Environment) {
+		t.Errorf("deploy env was lost")
+	}
+
+	build := cfg.Commands[addrs.MakeCommand("build")]
+	if exprIsNull(build.Executable) {
+		t.Errorf("build exec was not set")
+	}
+	if !exprIsNull(build.CommandLine) {
+		t.Errorf("build cmdline was not removed")
+	}
+
+	helper := cfg.Helpers[addrs.MakeHelper("foo", "bar")]
+	var helperContent struct {
+		A string `hcl:"a"`
+		B string `hcl:"b"`
+	}
+	if diags := gohcl.DecodeBody(helper.Body, nil, &helperContent); diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if got, want := helperContent.A, "base"; got != want {
+		t.Errorf("wrong helper a %q; want %q", got, want)
+	}
+	if got, want := helperContent.B, "override"; got != want {
+		t.Errorf("wrong helper b %q; want %q", got, want)
+	}
+
+	shared := cfg.SharedObjects[addrs.SharedObject{Name: "settings"}]
+	if got, want := exprString(shared.Attributes["region"].Expr), "eu-west-1"; got != want {
+		t.Errorf("wrong shared region %q; want %q", got, want)
+	}
+	if v, _ := shared.Attributes["extra"].Expr.Value(nil); !v.RawEquals(cty.True) {
+		t.Errorf("wrong shared extra %#v; want true", v)
+	}
+}
+
+func TestLoadConfigOverridesMissingBase(t *testing.T) {
+	_, diags := LoadConfig("testdata/override-missing-base")
+	if !diags.HasErrors() {
+		t.Fatalf("no errors; want an error about the missing base declaration")
+	}
+	if got, want := diags[0].Summary, "Missing base command declaration to override"; got != want {
+		t.Errorf("wrong error summary %q; want %q", got, want)
+	}
+}
+
+func TestIsOverrideFile(t *testing.T) {
+	tests := map[string]bool{
+		"override.nv.hcl":           true,
+		"local_override.nv.json":    true,
+		"dir/local_override.nv.hcl": true,
+		"main.nv.hcl":               false,
+		"overrides.nv.hcl":          false,
+		"override_settings.nv.hcl":  false,
+		"local_override.hcl":        false,
+	}
+	for name, want := range tests {
+		if got := IsOverrideFile(name); got != want {
+			t.Errorf("wrong result for %q: got %t, want %t", name, got, want)
+		}
+	}
+}
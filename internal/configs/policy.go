@@ -0,0 +1,220 @@
+package configs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// PolicyEffect decides whether a policy allows or denies the references it
+// matches.
+type PolicyEffect int
+
+const (
+	// PolicyAllow means that only the objects matched by the policy's From
+	// patterns may refer to the objects matched by its To patterns.
+	PolicyAllow PolicyEffect = iota
+
+	// PolicyDeny means that the objects matched by the policy's From
+	// patterns may not refer to the objects matched by its To patterns.
+	PolicyDeny
+)
+
+// String returns the keyword used to select the effect in the configuration.
+func (e PolicyEffect) String() string {
+	switch e {
+	case PolicyAllow:
+		return "allow"
+	case PolicyDeny:
+		return "deny"
+	default:
+		return "invalid"
+	}
+}
+
+// Policy represents a single "policy" block in a configuration, which
+// restricts which objects may refer to which others, such as to say that
+// only one command may use a particular helper.
+//
+// A reference is checked against the policies while envy builds the graph
+// for the command or other object that it is running. A policy's From
+// patterns match a reference if they match either the object making the
+// reference or the object being run, so that a command can't escape a
+// policy by using a helper indirectly through another helper.
+//
+// A reference that matches any deny policy is rejected. Otherwise, if the
+// object being referred to matches the To patterns of any allow policies,
+// the reference is permitted only if it also matches the From patterns of
+// at least one of them. References to objects that no allow policy covers
+// are permitted.
+//
+// Allow policies combine in that way only within a single configuration
+// layer. A reference must satisfy the allow policies of every layer that has
+// any covering the object being referred to, so a layer of higher precedence
+// can only add restrictions to those of the layers below it. For the same
+// reason, a policy can't be replaced by declaring another with the same name
+// in a layer of higher precedence, and nor can it be overridden.
+type Policy struct {
+	Name      string
+	DeclRange hcl.Range
+
+	// Layer is the configuration layer that the policy was loaded from, or
+	// nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
+	Effect PolicyEffect
+
+	// From and To are glob patterns, in the syntax of path.Match, that are
+	// matched against the string forms of addresses, such as
+	// "command.deploy" or "aws_assume_role.prod".
+	From []string
+	To   []string
+}
+
+// matchesAny returns true if any of the given patterns match the given
+// address.
+func matchesAny(patterns []string, addr addrs.Referenceable) bool {
+	s := addr.String()
+	for _, pattern := range patterns {
+		// The patterns were validated when they were decoded.
+		if matched, _ := path.Match(pattern, s); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesFrom returns true if the policy's From patterns match either the
+// referring object or the object being run.
+func (p *Policy) matchesFrom(root, from addrs.Referenceable) bool {
+	return matchesAny(p.From, from) || (root != nil && matchesAny(p.From, root))
+}
+
+// CheckReference checks a reference from the object with address from to
+// the object with address to, made while running the object with address
+// root, against the configuration's policies.
+//
+// If the policies reject the reference then the result is the policy
+// responsible, or one of them if several allow policies together rejected
+// it. Otherwise the result is nil.
+func (c *Config) CheckReference(root, from, to addrs.Referenceable) *Policy {
+	names := make([]string, 0, len(c.Policies))
+	for name := range c.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Allow policies are combined separately for each layer, so that one
+	// layer's can't extend what another's allow.
+	covering := make(map[*Layer]*Policy)
+	allowed := make(map[*Layer]bool)
+	var layers []*Layer
+	for _, name := range names {
+		p := c.Policies[name]
+		if !matchesAny(p.To, to) {
+			continue
+		}
+		switch p.Effect {
+		case PolicyDeny:
+			if p.matchesFrom(root, from) {
+				return p
+			}
+		case PolicyAllow:
+			if _, exists := covering[p.Layer]; !exists {
+				covering[p.Layer] = p
+				layers = append(layers, p.Layer)
+			}
+			if p.matchesFrom(root, from) {
+				allowed[p.Layer] = true
+			}
+		}
+	}
+	for _, layer := range layers {
+		if !allowed[layer] {
+			return covering[layer]
+		}
+	}
+	return nil
+}
+
+func decodePolicyBlock(block *hcl.Block) (*Policy, hcl.Diagnostics) {
+	p := &Policy{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+	}
+
+	type DecodePolicy struct {
+		Effect hcl.Expression `hcl:"effect"`
+		From   []string       `hcl:"from"`
+		To     []string       `hcl:"to"`
+	}
+	var decP DecodePolicy
+	diags := gohcl.DecodeBody(block.Body, nil, &decP)
+
+	var effect string
+	v, moreDiags := decP.Effect.Value(nil)
+	missing := !moreDiags.HasErrors() && v.IsNull()
+	if !missing {
+		effect, moreDiags = decodeKeywordOrString(decP.Effect)
+		diags = append(diags, moreDiags...)
+	}
+	switch {
+	case missing:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Missing policy effect",
+			Detail:   "A policy must have an effect argument, set to either allow or deny.",
+			Subject:  block.DefRange.Ptr(),
+		})
+	case moreDiags.HasErrors():
+	case effect == "allow":
+		p.Effect = PolicyAllow
+	case effect == "deny":
+		p.Effect = PolicyDeny
+	default:
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid policy effect",
+			Detail:   "Must be one of the following keywords: allow or deny.",
+			Subject:  decP.Effect.Range().Ptr(),
+		})
+	}
+
+	for _, arg := range []struct {
+		name     string
+		patterns []string
+		into     *[]string
+	}{
+		{"from", decP.From, &p.From},
+		{"to", decP.To, &p.To},
+	} {
+		for _, pattern := range arg.patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid address pattern",
+					Detail:   fmt.Sprintf("The %s pattern %q is not a valid glob pattern.", arg.name, pattern),
+					Subject:  block.DefRange.Ptr(),
+				})
+				continue
+			}
+			*arg.into = append(*arg.into, pattern)
+		}
+	}
+
+	if !validName(p.Name) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid policy name",
+			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+
+	return p, diags
+}
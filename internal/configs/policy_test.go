@@ -0,0 +1,78 @@
+package configs
+
+import (
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+func TestConfigCheckReference(t *testing.T) {
+	f, diags := LoadConfigFile("testdata/policy.nv.hcl")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	cfg, diags := BuildConfig(".", []*File{f})
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	deploy := addrs.MakeCommand("deploy")
+	console := addrs.MakeCommand("console")
+	prod := addrs.MakeHelper("aws_assume_role", "prod")
+	dev := addrs.MakeHelper("aws_assume_role", "dev")
+	tmpl := addrs.MakeHelper("template", "conf")
+	secret := addrs.MakeHelper("secret", "token")
+
+	tests := []struct {
+		name       string
+		root, from addrs.Referenceable
+		to         addrs.Referenceable
+		want       string // name of the rejecting policy, if any
+	}{
+		{"allowed command", deploy, deploy, prod, ""},
+		{"other command", console, console, prod, "prod_deploy_only"},
+		{"uncovered helper", console, console, dev, ""},
+		{"indirect from allowed command", deploy, tmpl, prod, ""},
+		{"indirect from other command", console, tmpl, prod, "prod_deploy_only"},
+		{"denied referrer", deploy, tmpl, secret, "no_secrets_in_templates"},
+		{"not denied referrer", deploy, deploy, secret, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ""
+			if p := cfg.CheckReference(test.root, test.from, test.to); p != nil {
+				got = p.Name
+			}
+			if got != test.want {
+				t.Errorf("wrong result %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodePolicyBlockErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing effect": "policy \"p\" {\n  from = [\"*\"]\n  to = [\"*\"]\n}\n",
+		"invalid effect": "policy \"p\" {\n  effect = permit\n  from = [\"*\"]\n  to = [\"*\"]\n}\n",
+		"bad pattern":    "policy \"p\" {\n  effect = deny\n  from = [\"[\"]\n  to = [\"*\"]\n}\n",
+		"missing to":     "policy \"p\" {\n  effect = deny\n  from = [\"*\"]\n}\n",
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			file, diags := hclparse.NewParser().ParseHCL([]byte(src), "test.nv.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+			content, diags := file.Body.Content(configFileSchema)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			_, diags = decodePolicyBlock(content.Blocks[0])
+			if !diags.HasErrors() {
+				t.Errorf("no errors; want errors")
+			}
+		})
+	}
+}
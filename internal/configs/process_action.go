@@ -17,32 +17,77 @@ const (
 
 	// ProcessTerminate indicates that the process should be terminated.
 	ProcessTerminate
+
+	// ProcessSignal indicates that the process should be sent a signal,
+	// typically so that it reloads files that helpers have re-rendered.
+	// The signal to send is recorded alongside the action.
+	ProcessSignal
 )
 
-func decodeProcessAction(expr hcl.Expression) (ProcessAction, hcl.Diagnostics) {
+// String returns the keyword used to select the action in the configuration.
+func (a ProcessAction) String() string {
+	switch a {
+	case ProcessIgnore:
+		return "ignore"
+	case ProcessRestart:
+		return "restart"
+	case ProcessTerminate:
+		return "terminate"
+	case ProcessSignal:
+		return "signal"
+	default:
+		return "invalid"
+	}
+}
+
+// decodeProcessAction decodes a process action given either as a keyword
+// or, if allowSignal is set, as a call like signal("SIGHUP"). For the latter,
+// the second result is the name of the signal.
+func decodeProcessAction(expr hcl.Expression, allowSignal bool) (ProcessAction, string, hcl.Diagnostics) {
 	if expr == nil {
-		return ProcessIgnore, nil
+		return ProcessIgnore, "", nil
 	}
 	if v, diags := expr.Value(nil); !diags.HasErrors() && v.IsNull() {
-		return ProcessIgnore, nil
+		return ProcessIgnore, "", nil
 	}
 
 	kw := hcl.ExprAsKeyword(expr)
 	switch kw {
 	case "ignore":
-		return ProcessIgnore, nil
+		return ProcessIgnore, "", nil
 	case "restart":
-		return ProcessRestart, nil
+		return ProcessRestart, "", nil
 	case "terminate":
-		return ProcessTerminate, nil
-	default:
-		return ProcessIgnore, hcl.Diagnostics{
-			{
-				Severity: hcl.DiagError,
-				Summary:  "Invalid process action",
-				Detail:   "Must be one of the following keywords: ignore, restart, or terminate.",
-				Subject:  expr.StartRange().Ptr(),
-			},
+		return ProcessTerminate, "", nil
+	}
+
+	detail := "Must be one of the following keywords: ignore, restart, or terminate."
+	if allowSignal {
+		detail = "Must be one of the following keywords: ignore, restart, or terminate. Alternatively, use signal(\"NAME\") to send the process a signal."
+		if call, diags := hcl.ExprCall(expr); !diags.HasErrors() && call.Name == "signal" {
+			if len(call.Arguments) != 1 {
+				return ProcessIgnore, "", hcl.Diagnostics{
+					{
+						Severity: hcl.DiagError,
+						Summary:  "Invalid process action",
+						Detail:   "The signal action requires exactly one argument: the name of the signal to send.",
+						Subject:  call.ArgsRange.Ptr(),
+					},
+				}
+			}
+			name, diags := decodeSignalName(call.Arguments[0])
+			if diags.HasErrors() {
+				return ProcessIgnore, "", diags
+			}
+			return ProcessSignal, name, nil
 		}
 	}
+	return ProcessIgnore, "", hcl.Diagnostics{
+		{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid process action",
+			Detail:   detail,
+			Subject:  expr.StartRange().Ptr(),
+		},
+	}
 }
@@ -0,0 +1,47 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+func TestDecodeProcessAction(t *testing.T) {
+	tests := []struct {
+		src         string
+		allowSignal bool
+		wantAction  ProcessAction
+		wantSignal  string
+		wantErr     bool
+	}{
+		{`null`, true, ProcessIgnore, "", false},
+		{`restart`, true, ProcessRestart, "", false},
+		{`terminate`, false, ProcessTerminate, "", false},
+		{`signal("SIGHUP")`, true, ProcessSignal, "SIGHUP", false},
+		{`signal(usr1)`, true, ProcessSignal, "SIGUSR1", false},
+		{`signal("SIGHUP")`, false, ProcessIgnore, "", true},
+		{`signal()`, true, ProcessIgnore, "", true},
+		{`signal("SIGNOPE")`, true, ProcessIgnore, "", true},
+		{`reload`, true, ProcessIgnore, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.src), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+			action, sig, diags := decodeProcessAction(expr, test.allowSignal)
+			if got, want := diags.HasErrors(), test.wantErr; got != want {
+				t.Fatalf("wrong error result %t; want %t (%s)", got, want, diags.Error())
+			}
+			if got, want := action, test.wantAction; got != want {
+				t.Errorf("wrong action %s; want %s", got, want)
+			}
+			if got, want := sig, test.wantSignal; got != want {
+				t.Errorf("wrong signal %q; want %q", got, want)
+			}
+		})
+	}
+}
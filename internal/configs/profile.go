@@ -0,0 +1,155 @@
+package configs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// DefaultProfileName is the name of the profile that is selected when no
+// other profile is requested. A configuration may declare a profile of this
+// name, but need not.
+const DefaultProfileName = "default"
+
+// Profile represents a single "profile" block in a configuration, which
+// contains alternative settings for helpers and shared objects that apply
+// only when the profile is selected.
+//
+// The blocks inside a profile are merged into the helpers and shared objects
+// of the same addresses in the same way as blocks in override files, so they
+// need to set only the attributes that differ for the profile.
+type Profile struct {
+	Name      string
+	DeclRange hcl.Range
+
+	// Layer is the configuration layer that the profile was loaded from, or
+	// nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
+	Helpers       []*Helper
+	SharedObjects []*SharedObject
+}
+
+func decodeProfileBlock(block *hcl.Block) (*Profile, hcl.Diagnostics) {
+	p := &Profile{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+	}
+
+	content, diags := block.Body.Content(profileBlockSchema)
+	for _, block := range content.Blocks {
+		switch block.Type {
+		case "helper":
+			h, moreDiags := decodeHelperBlock(block)
+			diags = append(diags, moreDiags...)
+			p.Helpers = append(p.Helpers, h)
+		case "shared":
+			so, moreDiags := decodeSharedObjectBlock(block)
+			diags = append(diags, moreDiags...)
+			p.SharedObjects = append(p.SharedObjects, so)
+		}
+	}
+
+	if !validName(p.Name) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid profile name",
+			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+
+	return p, diags
+}
+
+// WithProfile returns a copy of the receiver with the settings from the
+// profile of the given name merged in.
+//
+// It is an error to request a profile that isn't declared, except for
+// DefaultProfileName, which leaves the configuration unchanged if there is
+// no profile of that name.
+func (c *Config) WithProfile(name string) (*Config, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+
+	p, exists := c.Profiles[name]
+	if !exists {
+		if name == DefaultProfileName {
+			return c, diags
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Profile not found",
+			Detail:   fmt.Sprintf("There is no profile named %q defined in the configuration.%s", name, c.profilesDetail()),
+		})
+		return c, diags
+	}
+
+	ret := *c
+	ret.Helpers = make(map[addrs.Helper]*Helper, len(c.Helpers))
+	for addr, obj := range c.Helpers {
+		ret.Helpers[addr] = obj
+	}
+	ret.SharedObjects = make(map[addrs.SharedObject]*SharedObject, len(c.SharedObjects))
+	for addr, obj := range c.SharedObjects {
+		ret.SharedObjects[addr] = obj
+	}
+
+	for _, alt := range p.Helpers {
+		addr := alt.Addr()
+		base, exists := ret.Helpers[addr]
+		if !exists {
+			diags = diags.Append(missingProfileBase(fmt.Sprintf("helper %q %q", alt.Type, alt.Name), alt.DeclRange))
+			continue
+		}
+		merged := *base
+		merged.Body = mergeBodies(base.Body, alt.Body)
+		ret.Helpers[addr] = &merged
+	}
+	for _, alt := range p.SharedObjects {
+		addr := alt.Addr()
+		base, exists := ret.SharedObjects[addr]
+		if !exists {
+			diags = diags.Append(missingProfileBase(fmt.Sprintf("shared object %q", alt.Name), alt.DeclRange))
+			continue
+		}
+		merged := *base
+		merged.Attributes = mergeAttributes(base.Attributes, alt.Attributes)
+		ret.SharedObjects[addr] = &merged
+	}
+
+	return &ret, diags
+}
+
+// profilesDetail returns a paragraph to append to the detail of a diagnostic
+// about a missing profile, listing the profiles that are declared.
+func (c *Config) profilesDetail() string {
+	if len(c.Profiles) == 0 {
+		return " No profiles are declared."
+	}
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, fmt.Sprintf("%q", name))
+	}
+	sort.Strings(names)
+	return fmt.Sprintf(" The declared profiles are %s.", strings.Join(names, ", "))
+}
+
+func missingProfileBase(desc string, rng hcl.Range) *hcl.Diagnostic {
+	return &hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Missing base declaration for profile",
+		Detail:   fmt.Sprintf("There is no %s declared outside of the profile, so there is nothing for this block to change.", desc),
+		Subject:  rng.Ptr(),
+	}
+}
+
+var profileBlockSchema = &hcl.BodySchema{
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "helper", LabelNames: []string{"type", "name"}},
+		{Type: "shared", LabelNames: []string{"name"}},
+	},
+}
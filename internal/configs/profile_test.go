@@ -0,0 +1,68 @@
+package configs
+
+import (
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/gohcl"
+)
+
+func TestConfigWithProfile(t *testing.T) {
+	cfg, diags := LoadConfig("testdata/profile")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	type HelperContent struct {
+		A string `hcl:"a"`
+		B string `hcl:"b"`
+	}
+	helperContent := func(cfg *Config) HelperContent {
+		var content HelperContent
+		diags := gohcl.DecodeBody(cfg.Helpers[addrs.MakeHelper("foo", "bar")].Body, nil, &content)
+		if diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		return content
+	}
+	region := func(cfg *Config) string {
+		var s string
+		attr := cfg.SharedObjects[addrs.MakeSharedObject("settings")].Attributes["region"]
+		if diags := gohcl.DecodeExpression(attr.Expr, nil, &s); diags.HasErrors() {
+			t.Fatalf("unexpected errors: %s", diags.Error())
+		}
+		return s
+	}
+
+	def, diags := cfg.WithProfile(DefaultProfileName)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if got, want := helperContent(def), (HelperContent{A: "base", B: "base"}); got != want {
+		t.Errorf("wrong default helper content %#v; want %#v", got, want)
+	}
+
+	prod, diags := cfg.WithProfile("prod")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	if got, want := helperContent(prod), (HelperContent{A: "base", B: "prod"}); got != want {
+		t.Errorf("wrong prod helper content %#v; want %#v", got, want)
+	}
+	if got, want := region(prod), "eu-west-1"; got != want {
+		t.Errorf("wrong prod region %q; want %q", got, want)
+	}
+
+	// Selecting a profile must not modify the original configuration.
+	if got, want := helperContent(cfg), (HelperContent{A: "base", B: "base"}); got != want {
+		t.Errorf("original helper content was modified to %#v", got)
+	}
+	if got, want := region(cfg), "us-east-1"; got != want {
+		t.Errorf("original region was modified to %q", got)
+	}
+
+	if _, diags := cfg.WithProfile("staging"); !diags.HasErrors() {
+		t.Errorf("no errors for undeclared profile; want an error")
+	}
+}
@@ -26,6 +26,10 @@ var reservedHelperTypeNames = map[string]struct{}{
 	"socket":  struct{}{},
 	"pipe":    struct{}{},
 	"path":    struct{}{},
+	"module":  struct{}{},
+	"var":     struct{}{},
+	"args":    struct{}{},
+	"profile": struct{}{},
 }
 
 // DecodeReference decodes a reference address given as an HCL absolute
@@ -83,6 +87,184 @@ func DecodeReference(traversal hcl.Traversal) (Reference, hcl.Traversal, hcl.Dia
 			SourceRange: traversal.SourceRange(),
 		}, traversal[2:], nil
 
+	case "path":
+		const errSummary = "Invalid path reference"
+		if len(traversal) < 2 {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"path\" must be followed by a path type using attribute access syntax.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		typeStep, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"path\" must be followed by a path type using attribute access syntax.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		if !addrs.ValidPathType(typeStep.Name) {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   fmt.Sprintf("There is no path type %q. The available path types are \"cwd\", \"config\", and \"temp\".", typeStep.Name),
+					Subject:  typeStep.SourceRange().Ptr(),
+				},
+			}
+		}
+		return Reference{
+			Addr:        addrs.MakePath(typeStep.Name),
+			SourceRange: traversal.SourceRange(),
+		}, traversal[2:], nil
+
+	case "args":
+		return Reference{
+			Addr:        addrs.Args{},
+			SourceRange: traversal[0].SourceRange(),
+		}, traversal[1:], nil
+
+	case "profile":
+		return Reference{
+			Addr:        addrs.Profile{},
+			SourceRange: traversal[0].SourceRange(),
+		}, traversal[1:], nil
+
+	case "var":
+		const errSummary = "Invalid variable reference"
+		if len(traversal) < 2 {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"var\" must be followed by a variable name using attribute access syntax.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		nameStep, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"var\" must be followed by a variable name using attribute access syntax.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		if !validName(nameStep.Name) {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   fmt.Sprintf("%q is not a valid variable name.", nameStep.Name),
+					Subject:  nameStep.SourceRange().Ptr(),
+				},
+			}
+		}
+		return Reference{
+			Addr:        addrs.MakeVariable(nameStep.Name),
+			SourceRange: traversal.SourceRange(),
+		}, traversal[2:], nil
+
+	case "shared":
+		const errSummary = "Invalid shared object reference"
+		if len(traversal) < 2 {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"shared\" must be followed by a shared object name using attribute access syntax.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		nameStep, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"shared\" must be followed by a shared object name using attribute access syntax.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		if !validName(nameStep.Name) {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   fmt.Sprintf("%q is not a valid shared object name.", nameStep.Name),
+					Subject:  nameStep.SourceRange().Ptr(),
+				},
+			}
+		}
+		return Reference{
+			Addr:        addrs.MakeSharedObject(nameStep.Name),
+			SourceRange: traversal.SourceRange(),
+		}, traversal[2:], nil
+
+	case "module":
+		const errSummary = "Invalid module reference"
+		if len(traversal) < 4 {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"module\" must be followed by a module name and then a helper type and name, like module.example.vault.db.",
+					Subject:  traversal.SourceRange().Ptr(),
+				},
+			}
+		}
+		nameStep, ok := traversal[1].(hcl.TraverseAttr)
+		if !ok || !validName(nameStep.Name) {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "The keyword \"module\" must be followed by a valid module name using attribute access syntax.",
+					Subject:  traversal[1].SourceRange().Ptr(),
+				},
+			}
+		}
+		typeStep, ok := traversal[2].(hcl.TraverseAttr)
+		if !ok || IsReservedHelperType(typeStep.Name) {
+			return Reference{}, nil, hcl.Diagnostics{
+				{
+					Severity: hcl.DiagError,
+					Summary:  errSummary,
+					Detail:   "Only helpers can be accessed from a module, so the module name must be followed by a helper type and name, like module.example.vault.db.",
+					Subject:  traversal[2].SourceRange().Ptr(),
+				},
+			}
+		}
+
+		// The remainder of the traversal is a helper reference relative
+		// to the module, so we can decode it as if it were at the root.
+		inner := make(hcl.Traversal, 0, len(traversal)-2)
+		inner = append(inner, hcl.TraverseRoot{
+			Name:     typeStep.Name,
+			SrcRange: typeStep.SrcRange,
+		})
+		inner = append(inner, traversal[3:]...)
+		ref, remain, diags := DecodeReference(inner)
+		if diags.HasErrors() {
+			return Reference{}, nil, diags
+		}
+		return Reference{
+			Addr:        ref.Addr.(addrs.Helper).InModule(nameStep.Name),
+			SourceRange: traversal.SourceRange(),
+		}, remain, diags
+
 	default:
 		if IsReservedHelperType(rootName) {
 			// Should not get here; indicates we didn't handle one of the
@@ -165,7 +347,10 @@ func ParseReferenceStr(str string) (Reference, hcl.Traversal, hcl.Diagnostics) {
 }
 
 func exprReferences(expr hcl.Expression) []Reference {
-	traversals := expr.Variables()
+	return traversalsReferences(expr.Variables())
+}
+
+func traversalsReferences(traversals []hcl.Traversal) []Reference {
 	refs := make([]Reference, 0, len(traversals))
 	for _, traversal := range traversals {
 		ref, _, diags := DecodeReference(traversal)
@@ -32,6 +32,36 @@ func TestDecodeReference(t *testing.T) {
 			addrs.MakeCommand("foo"),
 			1,
 		},
+		{
+			`module.aws.vault.db`,
+			addrs.MakeHelper("vault", "db").InModule("aws"),
+			0,
+		},
+		{
+			`module.aws.vault.db.token`,
+			addrs.MakeHelper("vault", "db").InModule("aws"),
+			1,
+		},
+		{
+			`var.region`,
+			addrs.MakeVariable("region"),
+			0,
+		},
+		{
+			`shared.settings.region`,
+			addrs.MakeSharedObject("settings"),
+			1,
+		},
+		{
+			`args[0]`,
+			addrs.Args{},
+			1,
+		},
+		{
+			`path.temp`,
+			addrs.MakePath("temp"),
+			0,
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,157 @@
+package configs
+
+import (
+	"time"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// RestartMode decides which exits of a child process cause envy to start it
+// again.
+type RestartMode int
+
+const (
+	// RestartNever means that envy exits along with the child process.
+	RestartNever RestartMode = iota
+
+	// RestartOnFailure means that envy restarts the child process if it
+	// exits with a non-zero status.
+	RestartOnFailure
+
+	// RestartAlways means that envy restarts the child process whenever
+	// it exits.
+	RestartAlways
+)
+
+// String returns the keyword used to select the mode in the configuration.
+func (m RestartMode) String() string {
+	switch m {
+	case RestartNever:
+		return "never"
+	case RestartOnFailure:
+		return "on-failure"
+	case RestartAlways:
+		return "always"
+	default:
+		return "invalid"
+	}
+}
+
+// Defaults for the settings of a restart_policy block.
+const (
+	DefaultRestartBackoff    = time.Second
+	DefaultRestartMaxBackoff = time.Minute
+	DefaultRestartResetAfter = 5 * time.Minute
+)
+
+// RestartPolicy represents the "restart_policy" block inside a "command"
+// block, which decides whether and how quickly envy starts the command's
+// child process again after it exits by itself.
+//
+// Restarts caused by an on_update or on_error action happen immediately and
+// don't count against the policy.
+type RestartPolicy struct {
+	DeclRange hcl.Range
+
+	Mode RestartMode
+
+	// MaxRetries is the number of consecutive restarts after which envy
+	// gives up and exits with the child's last exit status, or zero for no
+	// limit.
+	MaxRetries int
+
+	// Backoff is the delay before the first restart, which doubles for
+	// each consecutive restart up to MaxBackoff. Envy picks each actual
+	// delay randomly from between half of and the whole of the current
+	// backoff, so that many processes failing together don't restart in
+	// lockstep.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// ResetAfter is how long the child process must run before it exits
+	// for envy to forget about the earlier restarts, resetting both the
+	// retry count and the backoff.
+	ResetAfter time.Duration
+}
+
+// DefaultRestartPolicy returns the restart policy for a command that doesn't
+// have a restart_policy block, which never restarts it.
+func DefaultRestartPolicy() *RestartPolicy {
+	return &RestartPolicy{
+		Mode:       RestartNever,
+		Backoff:    DefaultRestartBackoff,
+		MaxBackoff: DefaultRestartMaxBackoff,
+		ResetAfter: DefaultRestartResetAfter,
+	}
+}
+
+func decodeRestartPolicyBlock(block *hcl.Block) (*RestartPolicy, hcl.Diagnostics) {
+	p := DefaultRestartPolicy()
+	p.DeclRange = block.DefRange
+
+	type DecodeRestartPolicy struct {
+		Mode       hcl.Expression `hcl:"mode"`
+		MaxRetries *int           `hcl:"max_retries"`
+		Backoff    hcl.Expression `hcl:"backoff"`
+		MaxBackoff hcl.Expression `hcl:"max_backoff"`
+		ResetAfter hcl.Expression `hcl:"reset_after"`
+	}
+	var decP DecodeRestartPolicy
+	diags := gohcl.DecodeBody(block.Body, nil, &decP)
+
+	if v, moreDiags := decP.Mode.Value(nil); moreDiags.HasErrors() || !v.IsNull() {
+		mode, moreDiags := decodeKeywordOrString(decP.Mode)
+		diags = append(diags, moreDiags...)
+		switch {
+		case moreDiags.HasErrors():
+		case mode == "never":
+			p.Mode = RestartNever
+		case mode == "on-failure":
+			p.Mode = RestartOnFailure
+		case mode == "always":
+			p.Mode = RestartAlways
+		default:
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid restart mode",
+				Detail:   "Must be one of the following keywords: never, on-failure, or always.",
+				Subject:  decP.Mode.Range().Ptr(),
+			})
+		}
+	}
+
+	if decP.MaxRetries != nil {
+		if *decP.MaxRetries < 0 {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid maximum retries",
+				Detail:   "The max_retries argument must not be negative. Use zero to allow any number of retries.",
+				Subject:  block.DefRange.Ptr(),
+			})
+		} else {
+			p.MaxRetries = *decP.MaxRetries
+		}
+	}
+
+	for _, arg := range []struct {
+		name string
+		expr hcl.Expression
+		into *time.Duration
+	}{
+		{"backoff", decP.Backoff, &p.Backoff},
+		{"max_backoff", decP.MaxBackoff, &p.MaxBackoff},
+		{"reset_after", decP.ResetAfter, &p.ResetAfter},
+	} {
+		d, ok, moreDiags := decodeDuration(arg.expr, arg.name)
+		diags = append(diags, moreDiags...)
+		if ok {
+			*arg.into = d
+		}
+	}
+	if p.MaxBackoff < p.Backoff {
+		p.MaxBackoff = p.Backoff
+	}
+
+	return p, diags
+}
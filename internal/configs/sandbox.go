@@ -0,0 +1,163 @@
+package configs
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// LimitNames are the names of the resource limits that can be set in the
+// "limits" argument of a sandbox block.
+var LimitNames = []string{
+	"core_size",  // largest core dump, in bytes
+	"cpu_time",   // CPU time, in seconds
+	"file_size",  // largest file the process may create, in bytes
+	"memory",     // virtual memory, in bytes
+	"open_files", // number of open file descriptors
+	"stack_size", // stack size, in bytes
+}
+
+// LimitUnlimited is the value of a resource limit that was set to the
+// keyword "unlimited".
+const LimitUnlimited uint64 = math.MaxUint64
+
+// Sandbox represents the "sandbox" block inside a "command" block, which
+// restricts the child process that envy launches for the command.
+//
+// Other than NewSession, User and Group, these settings are supported only on
+// Linux, where envy applies them in a copy of itself that it launches in
+// place of the program, just before replacing itself with the program. That
+// copy already runs as User and Group, so they must be able to execute envy,
+// and the limits can't be raised above envy's own hard limits.
+type Sandbox struct {
+	DeclRange hcl.Range
+
+	// Limits are the resource limits for the child process, keyed by one of
+	// LimitNames. Each sets both the soft and the hard limit.
+	Limits map[string]uint64
+
+	// Umask, if not nil, replaces the file mode creation mask that the
+	// child process would otherwise inherit from envy.
+	Umask *os.FileMode
+
+	// NewSession, if true, makes the child process the leader of a new
+	// session, detaching it from envy's terminal.
+	NewSession bool
+
+	// NoNewPrivs, if true, prevents the child process and anything it
+	// launches from gaining privileges, such as by running setuid programs.
+	NoNewPrivs bool
+
+	// User and Group are the names or numeric IDs of the user and group
+	// that the child process runs as. They take effect only if envy itself
+	// is running as root, and otherwise are ignored. If only User is set
+	// then the child uses that user's primary group. The run's temporary
+	// directory is handed over to them, so that the child can still read
+	// the files that helpers write there.
+	User  string
+	Group string
+}
+
+// DefaultSandbox returns the sandbox settings for a command that doesn't have
+// a sandbox block, which don't restrict the child process at all.
+func DefaultSandbox() *Sandbox {
+	return &Sandbox{}
+}
+
+func decodeSandboxBlock(block *hcl.Block) (*Sandbox, hcl.Diagnostics) {
+	s := DefaultSandbox()
+	s.DeclRange = block.DefRange
+
+	type DecodeSandbox struct {
+		Limits     hcl.Expression `hcl:"limits"`
+		Umask      *string        `hcl:"umask"`
+		NewSession *bool          `hcl:"new_session"`
+		NoNewPrivs *bool          `hcl:"no_new_privs"`
+		User       *string        `hcl:"user"`
+		Group      *string        `hcl:"group"`
+	}
+	var decS DecodeSandbox
+	diags := gohcl.DecodeBody(block.Body, nil, &decS)
+
+	if v, moreDiags := decS.Limits.Value(nil); moreDiags.HasErrors() || !v.IsNull() {
+		var moreDiags hcl.Diagnostics
+		s.Limits, moreDiags = decodeLimits(decS.Limits)
+		diags = append(diags, moreDiags...)
+	}
+
+	if decS.Umask != nil {
+		mask, err := strconv.ParseUint(*decS.Umask, 8, 32)
+		if err != nil || mask > 0777 {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid umask",
+				Detail:   "The umask argument must be a string containing an octal file mode mask, such as \"0077\".",
+				Subject:  block.DefRange.Ptr(),
+			})
+		} else {
+			mode := os.FileMode(mask)
+			s.Umask = &mode
+		}
+	}
+
+	if decS.NewSession != nil {
+		s.NewSession = *decS.NewSession
+	}
+	if decS.NoNewPrivs != nil {
+		s.NoNewPrivs = *decS.NoNewPrivs
+	}
+	if decS.User != nil {
+		s.User = *decS.User
+	}
+	if decS.Group != nil {
+		s.Group = *decS.Group
+	}
+
+	return s, diags
+}
+
+// decodeLimits decodes the object expression given in the "limits" argument
+// of a sandbox block.
+func decodeLimits(expr hcl.Expression) (map[string]uint64, hcl.Diagnostics) {
+	pairs, diags := hcl.ExprMap(expr)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	limits := make(map[string]uint64, len(pairs))
+	for _, pair := range pairs {
+		name, moreDiags := decodeKeywordOrString(pair.Key)
+		diags = append(diags, moreDiags...)
+		if moreDiags.HasErrors() {
+			continue
+		}
+		i := sort.SearchStrings(LimitNames, name)
+		if i == len(LimitNames) || LimitNames[i] != name {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity: hcl.DiagError,
+				Summary:  "Invalid resource limit",
+				Detail:   fmt.Sprintf("Must be one of the following limits: %s.", strings.Join(LimitNames, ", ")),
+				Subject:  pair.Key.Range().Ptr(),
+			})
+			continue
+		}
+
+		if hcl.ExprAsKeyword(pair.Value) == "unlimited" {
+			limits[name] = LimitUnlimited
+			continue
+		}
+		var limit uint64
+		moreDiags = gohcl.DecodeExpression(pair.Value, nil, &limit)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() {
+			limits[name] = limit
+		}
+	}
+	return limits, diags
+}
@@ -0,0 +1,46 @@
+package configs
+
+import (
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+)
+
+func TestDecodeLimits(t *testing.T) {
+	tests := []struct {
+		src     string
+		want    map[string]uint64
+		wantErr bool
+	}{
+		{`{}`, map[string]uint64{}, false},
+		{`{ open_files = 256 }`, map[string]uint64{"open_files": 256}, false},
+		{`{ "memory" = 1073741824 }`, map[string]uint64{"memory": 1073741824}, false},
+		{`{ core_size = unlimited }`, map[string]uint64{"core_size": LimitUnlimited}, false},
+		{`{ open_files = -1 }`, map[string]uint64{}, true},
+		{`{ open_files = "many" }`, map[string]uint64{}, true},
+		{`{ processes = 10 }`, map[string]uint64{}, true},
+		{`["open_files"]`, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.src), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+			got, diags := decodeLimits(expr)
+			if gotErr := diags.HasErrors(); gotErr != test.wantErr {
+				t.Fatalf("wrong error result %t; want %t (%s)", gotErr, test.wantErr, diags.Error())
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("wrong limits %#v; want %#v", got, test.want)
+			}
+			for name, want := range test.want {
+				if got[name] != want {
+					t.Errorf("wrong %s limit %d; want %d", name, got[name], want)
+				}
+			}
+		})
+	}
+}
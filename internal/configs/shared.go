@@ -11,6 +11,10 @@ type SharedObject struct {
 	Name      string
 	DeclRange hcl.Range
 
+	// Layer is the configuration layer that the shared object was loaded
+	// from, or nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
 	Attributes hcl.Attributes
 }
 
@@ -19,6 +23,16 @@ func (o *SharedObject) Addr() addrs.SharedObject {
 	return addrs.SharedObject{Name: o.Name}
 }
 
+// AllReferences returns all of the references made from the shared object's
+// attributes.
+func (o *SharedObject) AllReferences() []Reference {
+	var refs []Reference
+	for _, attr := range o.Attributes {
+		refs = append(refs, exprReferences(attr.Expr)...)
+	}
+	return refs
+}
+
 func decodeSharedObjectBlock(block *hcl.Block) (*SharedObject, hcl.Diagnostics) {
 	so := &SharedObject{
 		Name:      block.Labels[0],
@@ -0,0 +1,156 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+)
+
+// DefaultGracePeriod is how long envy waits for a child process to exit
+// after asking it to terminate before killing it, if the command doesn't
+// specify otherwise.
+const DefaultGracePeriod = 10 * time.Second
+
+// SignalNames are the names of the signals that can be used in the
+// configuration, in the form used by the configuration.
+var SignalNames = []string{
+	"SIGHUP",
+	"SIGINT",
+	"SIGQUIT",
+	"SIGTERM",
+	"SIGUSR1",
+	"SIGUSR2",
+	"SIGWINCH",
+}
+
+// Termination represents the "termination" block inside a "command" block,
+// which decides how envy stops the command's child process, both when envy
+// itself is asked to exit and when an on_update or on_error action restarts
+// or terminates the child.
+type Termination struct {
+	DeclRange hcl.Range
+
+	// Signal is the name of the signal sent to ask the child process to
+	// exit, which is one of SignalNames.
+	Signal string
+
+	// GracePeriod is how long to wait after sending Signal before killing
+	// the child process with SIGKILL.
+	GracePeriod time.Duration
+
+	// ForwardSignals are the names of the signals that envy passes on to
+	// the child process when it receives them itself, rather than
+	// terminating the child.
+	ForwardSignals []string
+
+	// ProcessGroup, if true, runs the child in its own process group so
+	// that signals from envy reach any processes that the child launches
+	// too. The group is placed in the foreground if envy has a terminal,
+	// so that the child can still read from it.
+	ProcessGroup bool
+}
+
+// DefaultTermination returns the termination settings for a command that
+// doesn't have a termination block.
+func DefaultTermination() *Termination {
+	return &Termination{
+		Signal:      "SIGTERM",
+		GracePeriod: DefaultGracePeriod,
+	}
+}
+
+// Forwards returns true if the given signal name is one of ForwardSignals.
+func (t *Termination) Forwards(name string) bool {
+	for _, fwd := range t.ForwardSignals {
+		if fwd == name {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeTerminationBlock(block *hcl.Block) (*Termination, hcl.Diagnostics) {
+	t := DefaultTermination()
+	t.DeclRange = block.DefRange
+
+	type DecodeTermination struct {
+		Signal         hcl.Expression `hcl:"signal"`
+		GracePeriod    hcl.Expression `hcl:"grace_period"`
+		ForwardSignals hcl.Expression `hcl:"forward_signals"`
+		ProcessGroup   *bool          `hcl:"process_group"`
+	}
+	var decT DecodeTermination
+	diags := gohcl.DecodeBody(block.Body, nil, &decT)
+
+	if v, moreDiags := decT.Signal.Value(nil); moreDiags.HasErrors() || !v.IsNull() {
+		name, moreDiags := decodeSignalName(decT.Signal)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() {
+			t.Signal = name
+		}
+	}
+
+	d, ok, moreDiags := decodeDuration(decT.GracePeriod, "grace_period")
+	diags = append(diags, moreDiags...)
+	if ok {
+		t.GracePeriod = d
+	}
+
+	if v, moreDiags := decT.ForwardSignals.Value(nil); moreDiags.HasErrors() || !v.IsNull() {
+		exprs, moreDiags := hcl.ExprList(decT.ForwardSignals)
+		diags = append(diags, moreDiags...)
+		for _, expr := range exprs {
+			name, moreDiags := decodeSignalName(expr)
+			diags = append(diags, moreDiags...)
+			if !moreDiags.HasErrors() {
+				t.ForwardSignals = append(t.ForwardSignals, name)
+			}
+		}
+	}
+
+	if decT.ProcessGroup != nil {
+		t.ProcessGroup = *decT.ProcessGroup
+	}
+
+	return t, diags
+}
+
+// decodeSignalName decodes a signal name given either as a string or as a
+// keyword, such as SIGHUP. The "SIG" prefix is optional.
+func decodeSignalName(expr hcl.Expression) (string, hcl.Diagnostics) {
+	name, diags := decodeKeywordOrString(expr)
+	if diags.HasErrors() {
+		return "", diags
+	}
+
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	for _, valid := range SignalNames {
+		if name == valid {
+			return name, nil
+		}
+	}
+	return "", diags.Append(&hcl.Diagnostic{
+		Severity: hcl.DiagError,
+		Summary:  "Invalid signal name",
+		Detail:   fmt.Sprintf("Must be one of the following signals: %s.", strings.Join(SignalNames, ", ")),
+		Subject:  expr.Range().Ptr(),
+	})
+}
+
+// decodeKeywordOrString decodes an expression that selects one of a fixed
+// set of options, which can be given either as a bare keyword or as a
+// string.
+func decodeKeywordOrString(expr hcl.Expression) (string, hcl.Diagnostics) {
+	if kw := hcl.ExprAsKeyword(expr); kw != "" {
+		return kw, nil
+	}
+	var s string
+	diags := gohcl.DecodeExpression(expr, nil, &s)
+	return s, diags
+}
@@ -9,4 +9,36 @@ command "terraform" {
 
   on_update = ignore
   on_error  = terminate
+  pty       = true
+
+  arg "workspace" {
+    short   = "w"
+    default = "default"
+  }
+  arg "auto_approve" {
+    type = bool
+  }
+
+  termination {
+    signal          = SIGINT
+    grace_period    = "3s"
+    forward_signals = ["HUP", "SIGWINCH"]
+    process_group   = true
+  }
+
+  restart_policy {
+    mode        = on-failure
+    max_retries = 3
+    backoff     = "500ms"
+  }
+
+  sandbox {
+    limits = {
+      open_files = 1024
+      cpu_time   = unlimited
+    }
+    umask        = "0027"
+    no_new_privs = true
+    user         = "nobody"
+  }
 }
@@ -0,0 +1,7 @@
+helper "vault" "db" {
+  role = vault.base.role
+}
+
+helper "vault" "base" {
+  role = "reader"
+}
@@ -0,0 +1,7 @@
+module "aws" {
+  source = "aws"
+}
+
+helper "vault" "db" {
+  role = module.aws.vault.db.role
+}
@@ -0,0 +1,3 @@
+command "nonexist" {
+  work_dir = "local"
+}
@@ -0,0 +1,16 @@
+command "deploy" {
+  work_dir = "local"
+}
+
+command "build" {
+  exec = ["make", "-j8"]
+}
+
+helper "foo" "bar" {
+  b = "override"
+}
+
+shared "settings" {
+  region = "eu-west-1"
+  extra  = true
+}
@@ -0,0 +1,20 @@
+command "deploy" {
+  exec     = ["deploy"]
+  work_dir = "shared"
+  env = {
+    STAGE = "prod"
+  }
+}
+
+command "build" {
+  cmdline = ["make"]
+}
+
+helper "foo" "bar" {
+  a = "base"
+  b = "base"
+}
+
+shared "settings" {
+  region = "us-east-1"
+}
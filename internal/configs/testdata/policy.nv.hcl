@@ -0,0 +1,11 @@
+policy "prod_deploy_only" {
+  effect = allow
+  from   = ["command.deploy"]
+  to     = ["aws_assume_role.prod"]
+}
+
+policy "no_secrets_in_templates" {
+  effect = "deny"
+  from   = ["template.*"]
+  to     = ["secret.*"]
+}
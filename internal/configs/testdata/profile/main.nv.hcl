@@ -0,0 +1,18 @@
+helper "foo" "bar" {
+  a = "base"
+  b = "base"
+}
+
+shared "settings" {
+  region = "us-east-1"
+}
+
+profile "prod" {
+  helper "foo" "bar" {
+    b = "prod"
+  }
+
+  shared "settings" {
+    region = "eu-west-1"
+  }
+}
@@ -0,0 +1,14 @@
+variable "region" {
+  description = "The region to deploy to."
+  type        = string
+  default     = "eu-west-1"
+
+  validation {
+    condition     = var.region != ""
+    error_message = "The region must not be empty."
+  }
+}
+
+variable "replicas" {
+  type = number
+}
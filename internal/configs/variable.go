@@ -0,0 +1,200 @@
+package configs
+
+import (
+	"fmt"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// Variable represents a single "variable" block in a configuration, which
+// declares an input variable that can be set when running a command and
+// referenced as var.NAME in expressions.
+type Variable struct {
+	Name      string
+	DeclRange hcl.Range
+
+	// Layer is the configuration layer that the variable was loaded from,
+	// or nil if it wasn't loaded as part of a directory.
+	Layer *Layer
+
+	// Description is an optional human-readable description of what the
+	// variable is for.
+	Description string
+
+	// Type is the type constraint that any value for the variable must
+	// conform to. It is cty.DynamicPseudoType if the configuration doesn't
+	// constrain the type.
+	Type cty.Type
+
+	// Default is the value to use if no other value is given for the
+	// variable, already converted to Type, or cty.NilVal if the variable is
+	// required.
+	Default cty.Value
+
+	// Validations are rules that a value for the variable must satisfy, in
+	// addition to its type constraint.
+	Validations []*VariableValidation
+
+	// body is the body that the variable was decoded from, retained so that
+	// override files can be merged into it.
+	body hcl.Body
+}
+
+// VariableValidation represents a single "validation" block inside a
+// "variable" block.
+type VariableValidation struct {
+	// Condition is an expression that must return true for a valid value.
+	// It may refer only to the variable that it belongs to.
+	Condition hcl.Expression
+
+	// ErrorMessage is the message to return if Condition returns false.
+	ErrorMessage string
+
+	DeclRange hcl.Range
+}
+
+// Addr returns the address for the variable that was declared.
+func (v *Variable) Addr() addrs.Variable {
+	return addrs.Variable{Name: v.Name}
+}
+
+// Required returns true if the variable has no default value, and so a
+// value must always be given for it.
+func (v *Variable) Required() bool {
+	return v.Default == cty.NilVal
+}
+
+func decodeVariableBlock(block *hcl.Block) (*Variable, hcl.Diagnostics) {
+	var diags hcl.Diagnostics
+	v := &Variable{
+		Name:      block.Labels[0],
+		DeclRange: block.DefRange,
+	}
+
+	diags = append(diags, v.decodeBody(block.Body)...)
+
+	if !validName(v.Name) {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid variable name",
+			Detail:   "All object names must begin with a letter and contain only letters, digits, and underscores.",
+			Subject:  block.LabelRanges[0].Ptr(),
+		})
+	}
+
+	return v, diags
+}
+
+// decodeBody populates the receiver's settings from the given block body.
+func (v *Variable) decodeBody(body hcl.Body) hcl.Diagnostics {
+	var diags hcl.Diagnostics
+	v.body = body
+	v.Type = cty.DynamicPseudoType
+	v.Default = cty.NilVal
+	v.Description = ""
+	v.Validations = nil
+
+	content, moreDiags := body.Content(variableBlockSchema)
+	diags = append(diags, moreDiags...)
+
+	if attr, exists := content.Attributes["description"]; exists {
+		moreDiags := gohcl.DecodeExpression(attr.Expr, nil, &v.Description)
+		diags = append(diags, moreDiags...)
+	}
+
+	if attr, exists := content.Attributes["type"]; exists {
+		ty, moreDiags := typeexpr.TypeConstraint(attr.Expr)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() {
+			v.Type = ty
+		}
+	}
+
+	if attr, exists := content.Attributes["default"]; exists {
+		val, moreDiags := attr.Expr.Value(nil)
+		diags = append(diags, moreDiags...)
+		if !moreDiags.HasErrors() {
+			val, err := convert.Convert(val, v.Type)
+			if err != nil {
+				diags = diags.Append(&hcl.Diagnostic{
+					Severity: hcl.DiagError,
+					Summary:  "Invalid default value for variable",
+					Detail:   fmt.Sprintf("This default value is not compatible with the variable's type constraint: %s.", err),
+					Subject:  attr.Expr.Range().Ptr(),
+				})
+			} else {
+				v.Default = val
+			}
+		}
+	}
+
+	for _, block := range content.Blocks {
+		vv, moreDiags := decodeVariableValidationBlock(v.Name, block)
+		diags = append(diags, moreDiags...)
+		v.Validations = append(v.Validations, vv)
+	}
+
+	return diags
+}
+
+// merge returns a new variable that has the settings of the receiver
+// overridden by any that are set in the given variable from an override
+// file.
+func (v *Variable) merge(override *Variable) (*Variable, hcl.Diagnostics) {
+	merged := &Variable{
+		Name:      v.Name,
+		DeclRange: v.DeclRange,
+		Layer:     v.Layer,
+	}
+	diags := merged.decodeBody(mergeBodies(v.body, override.body))
+	return merged, diags
+}
+
+func decodeVariableValidationBlock(varName string, block *hcl.Block) (*VariableValidation, hcl.Diagnostics) {
+	vv := &VariableValidation{
+		DeclRange: block.DefRange,
+	}
+
+	type DecodeValidation struct {
+		Condition    hcl.Expression `hcl:"condition"`
+		ErrorMessage string         `hcl:"error_message"`
+	}
+	var decVal DecodeValidation
+	diags := gohcl.DecodeBody(block.Body, nil, &decVal)
+	vv.Condition = decVal.Condition
+	vv.ErrorMessage = decVal.ErrorMessage
+
+	if vv.Condition == nil {
+		return vv, diags
+	}
+	for _, ref := range exprReferences(vv.Condition) {
+		if addr, ok := ref.Addr.(addrs.Variable); ok && addr.Name == varName {
+			continue
+		}
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity: hcl.DiagError,
+			Summary:  "Invalid reference in variable validation",
+			Detail:   fmt.Sprintf("The condition for variable %q can refer only to the variable itself, not to %s.", varName, ref.Addr),
+			Subject:  ref.SourceRange.Ptr(),
+		})
+	}
+
+	return vv, diags
+}
+
+var variableBlockSchema = &hcl.BodySchema{
+	Attributes: []hcl.AttributeSchema{
+		{Name: "description"},
+		{Name: "type"},
+		{Name: "default"},
+	},
+	Blocks: []hcl.BlockHeaderSchema{
+		{Type: "validation"},
+	},
+}
@@ -0,0 +1,86 @@
+package configs
+
+import (
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestLoadConfigVariables(t *testing.T) {
+	cfg, diags := LoadConfig("testdata/variables")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+
+	region := cfg.Variables[addrs.MakeVariable("region")]
+	if got, want := region.Type, cty.String; !got.Equals(want) {
+		t.Errorf("wrong region type %#v; want %#v", got, want)
+	}
+	if got, want := region.Default, cty.StringVal("eu-west-1"); !got.RawEquals(want) {
+		t.Errorf("wrong region default %#v; want %#v", got, want)
+	}
+	if got, want := region.Description, "The region to deploy to."; got != want {
+		t.Errorf("wrong region description %q; want %q", got, want)
+	}
+	if got, want := len(region.Validations), 1; got != want {
+		t.Fatalf("wrong number of region validations %d; want %d", got, want)
+	}
+	if got, want := region.Validations[0].ErrorMessage, "The region must not be empty."; got != want {
+		t.Errorf("wrong validation error message %q; want %q", got, want)
+	}
+
+	replicas := cfg.Variables[addrs.MakeVariable("replicas")]
+	if !replicas.Required() {
+		t.Errorf("replicas is not required; want required")
+	}
+
+	values, diags := LoadVariableValues("testdata/variables")
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Error())
+	}
+	v, _ := values["replicas"].Expr.Value(nil)
+	if got, want := v, cty.NumberIntVal(3); !got.RawEquals(want) {
+		t.Errorf("wrong replicas value %#v; want %#v", got, want)
+	}
+}
+
+func TestDecodeVariableBlockErrors(t *testing.T) {
+	tests := map[string]string{
+		"incompatible default": `
+variable "a" {
+  type    = number
+  default = "not a number"
+}
+`,
+		"validation of other variable": `
+variable "a" {
+  validation {
+    condition     = var.b != ""
+    error_message = "Nope."
+  }
+}
+`,
+	}
+
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, diags := hclparse.NewParser().ParseHCL([]byte(src), "test.nv.hcl")
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+			content, _ := f.Body.Content(configFileSchema)
+			var block *hcl.Block
+			for _, b := range content.Blocks {
+				block = b
+			}
+			_, diags = decodeVariableBlock(block)
+			if !diags.HasErrors() {
+				t.Fatalf("no errors; want an error")
+			}
+		})
+	}
+}
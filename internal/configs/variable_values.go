@@ -0,0 +1,51 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hclparse"
+)
+
+// VariableValuesFileName is the name of the file in a configuration
+// directory that can set values for input variables, as attributes in HCL
+// native syntax.
+//
+// The name begins with a period so that the file is not mistaken for a
+// configuration file, and so that it is conventionally hidden.
+const VariableValuesFileName = ".nvvars"
+
+// LoadVariableValues reads the variable values file in the given directory,
+// if any, and returns its attributes keyed by variable name.
+//
+// If there is no variable values file in the directory then the result is
+// empty, without any diagnostics. The attributes are returned unevaluated
+// because a variable's type constraint decides how to interpret its value.
+func LoadVariableValues(dir string) (hcl.Attributes, hcl.Diagnostics) {
+	filename := filepath.Join(dir, VariableValuesFileName)
+	src, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, hcl.Diagnostics{
+			{
+				Severity: hcl.DiagError,
+				Summary:  "Failed to read file",
+				Detail:   fmt.Sprintf("The variable values file %q could not be read: %s.", filename, err),
+			},
+		}
+	}
+
+	parser := hclparse.NewParser()
+	file, diags := parser.ParseHCL(src, filename)
+	if file == nil {
+		return nil, diags
+	}
+	attrs, moreDiags := file.Body.JustAttributes()
+	diags = append(diags, moreDiags...)
+	return attrs, diags
+}
@@ -0,0 +1,169 @@
+package funcs
+
+import (
+	"fmt"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// MergeFunc is a function that combines any number of maps or objects into
+// a single object. Where more than one argument defines the same key, the
+// value from the last such argument is used.
+var MergeFunc = function.New(&function.Spec{
+	VarParam: &function.Parameter{
+		Name:             "maps",
+		Type:             cty.DynamicPseudoType,
+		AllowDynamicType: true,
+		AllowNull:        true,
+	},
+	Type: function.StaticReturnType(cty.DynamicPseudoType),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		attrs := make(map[string]cty.Value)
+		for i, arg := range args {
+			if arg.IsNull() {
+				continue
+			}
+			ty := arg.Type()
+			if !(ty.IsMapType() || ty.IsObjectType()) {
+				return cty.DynamicVal, function.NewArgErrorf(i, "arguments must be maps or objects, got %s", ty.FriendlyName())
+			}
+			if !arg.IsKnown() {
+				return cty.DynamicVal, nil
+			}
+			for it := arg.ElementIterator(); it.Next(); {
+				k, v := it.Element()
+				attrs[k.AsString()] = v
+			}
+		}
+		return cty.ObjectVal(attrs), nil
+	},
+})
+
+// LookupFunc is a function that retrieves the value for a key in a map or
+// object, returning the optional third argument if the key is not present.
+var LookupFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name:             "inputMap",
+			Type:             cty.DynamicPseudoType,
+			AllowDynamicType: true,
+		},
+		{
+			Name: "key",
+			Type: cty.String,
+		},
+	},
+	VarParam: &function.Parameter{
+		Name:             "default",
+		Type:             cty.DynamicPseudoType,
+		AllowDynamicType: true,
+		AllowNull:        true,
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		if len(args) > 3 {
+			return cty.NilType, function.NewArgErrorf(3, "lookup accepts at most three arguments")
+		}
+		ty := args[0].Type()
+		switch {
+		case ty.IsMapType():
+			return ty.ElementType(), nil
+		case ty.IsObjectType(), ty == cty.DynamicPseudoType:
+			return cty.DynamicPseudoType, nil
+		default:
+			return cty.NilType, function.NewArgErrorf(0, "the first argument must be a map or object, got %s", ty.FriendlyName())
+		}
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		m, key := args[0], args[1].AsString()
+		if !m.IsKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+		ty := m.Type()
+		switch {
+		case ty.IsObjectType() && ty.HasAttribute(key):
+			return m.GetAttr(key), nil
+		case ty.IsMapType() && m.HasIndex(cty.StringVal(key)).True():
+			return m.Index(cty.StringVal(key)), nil
+		}
+		if len(args) < 3 {
+			return cty.DynamicVal, function.NewArgErrorf(1, "there is no element with key %q", key)
+		}
+		return convert.Convert(args[2], retType)
+	},
+})
+
+// KeysFunc is a function that returns the keys of a map or the attribute
+// names of an object, in lexical order.
+var KeysFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name:             "inputMap",
+			Type:             cty.DynamicPseudoType,
+			AllowDynamicType: true,
+		},
+	},
+	Type: function.StaticReturnType(cty.List(cty.String)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		m := args[0]
+		ty := m.Type()
+		if !(ty.IsMapType() || ty.IsObjectType()) {
+			return cty.UnknownVal(retType), function.NewArgErrorf(0, "must be a map or object, got %s", ty.FriendlyName())
+		}
+		if !m.IsKnown() {
+			return cty.UnknownVal(retType), nil
+		}
+		if m.LengthInt() == 0 {
+			return cty.ListValEmpty(cty.String), nil
+		}
+		var keys []cty.Value
+		for it := m.ElementIterator(); it.Next(); {
+			k, _ := it.Element()
+			keys = append(keys, k)
+		}
+		return cty.ListVal(keys), nil
+	},
+})
+
+// CoalesceFunc is a function that returns the first of its arguments that
+// is neither null nor an empty string.
+var CoalesceFunc = function.New(&function.Spec{
+	VarParam: &function.Parameter{
+		Name:             "vals",
+		Type:             cty.DynamicPseudoType,
+		AllowUnknown:     true,
+		AllowDynamicType: true,
+		AllowNull:        true,
+	},
+	Type: func(args []cty.Value) (cty.Type, error) {
+		types := make([]cty.Type, len(args))
+		for i, arg := range args {
+			types[i] = arg.Type()
+		}
+		ty, _ := convert.UnifyUnsafe(types)
+		if ty == cty.NilType {
+			return cty.NilType, fmt.Errorf("all arguments must have the same type")
+		}
+		return ty, nil
+	},
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		for _, arg := range args {
+			if !arg.IsKnown() {
+				return cty.UnknownVal(retType), nil
+			}
+			if arg.IsNull() {
+				continue
+			}
+			v, err := convert.Convert(arg, retType)
+			if err != nil {
+				return cty.UnknownVal(retType), err
+			}
+			if v.Type() == cty.String && v.AsString() == "" {
+				continue
+			}
+			return v, nil
+		}
+		return cty.NilVal, fmt.Errorf("no non-null, non-empty-string arguments")
+	},
+})
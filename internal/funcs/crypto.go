@@ -0,0 +1,36 @@
+package funcs
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// SHA256Func is a function that computes the SHA256 hash of a string and
+// returns it in hexadecimal.
+var SHA256Func = makeHashFunc(sha256.New)
+
+// MD5Func is a function that computes the MD5 hash of a string and returns
+// it in hexadecimal.
+var MD5Func = makeHashFunc(md5.New)
+
+func makeHashFunc(newHash func() hash.Hash) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "str",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			h := newHash()
+			h.Write([]byte(args[0].AsString()))
+			return cty.StringVal(hex.EncodeToString(h.Sum(nil))), nil
+		},
+	})
+}
@@ -0,0 +1,3 @@
+// Package funcs contains the functions available in expressions throughout
+// the envy configuration language.
+package funcs // import "envy.pw/cli/internal/funcs"
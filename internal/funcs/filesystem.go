@@ -0,0 +1,65 @@
+package funcs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// MakeFileFunc constructs a function that reads the contents of a file,
+// which must be valid UTF-8. Relative paths are resolved against the given
+// base directory.
+func MakeFileFunc(baseDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := resolvePath(baseDir, args[0].AsString())
+			src, err := ioutil.ReadFile(path)
+			if err != nil {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to read %s: %s", path, err)
+			}
+			if !utf8.Valid(src) {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "contents of %s are not valid UTF-8", path)
+			}
+			return cty.StringVal(string(src)), nil
+		},
+	})
+}
+
+// MakeAbsPathFunc constructs a function that converts a path to an absolute
+// path, resolving relative paths against the given base directory.
+func MakeAbsPathFunc(baseDir string) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name: "path",
+				Type: cty.String,
+			},
+		},
+		Type: function.StaticReturnType(cty.String),
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			path := resolvePath(baseDir, args[0].AsString())
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(0, "failed to make %s absolute: %s", path, err)
+			}
+			return cty.StringVal(abs), nil
+		},
+	})
+}
+
+func resolvePath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(baseDir, path)
+}
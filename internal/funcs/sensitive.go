@@ -0,0 +1,41 @@
+package funcs
+
+import (
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// NonsensitiveFunc is a function that returns its argument unchanged.
+//
+// Sensitivity is decided by analyzing which objects an expression refers
+// to rather than by the values themselves, so this function exists only to
+// mark a part of an expression that is safe to show even though it derives
+// from sensitive values.
+var NonsensitiveFunc = MakeNonsensitiveFunc(nil)
+
+// MakeNonsensitiveFunc constructs a function that behaves like
+// NonsensitiveFunc, but which also passes each known argument value to the
+// given function, if it isn't nil, so that the caller can avoid hiding the
+// value wherever else it appears.
+func MakeNonsensitiveFunc(declassify func(cty.Value)) function.Function {
+	return function.New(&function.Spec{
+		Params: []function.Parameter{
+			{
+				Name:             "value",
+				Type:             cty.DynamicPseudoType,
+				AllowUnknown:     true,
+				AllowNull:        true,
+				AllowDynamicType: true,
+			},
+		},
+		Type: func(args []cty.Value) (cty.Type, error) {
+			return args[0].Type(), nil
+		},
+		Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+			if declassify != nil && args[0].IsWhollyKnown() {
+				declassify(args[0])
+			}
+			return args[0], nil
+		},
+	})
+}
@@ -0,0 +1,98 @@
+package funcs
+
+import (
+	"strings"
+
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// JoinFunc is a function that concatenates the elements of a list of
+// strings, placing the given separator between each one.
+var JoinFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "separator",
+			Type: cty.String,
+		},
+		{
+			Name: "list",
+			Type: cty.List(cty.String),
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		sep := args[0].AsString()
+		var parts []string
+		for it := args[1].ElementIterator(); it.Next(); {
+			_, v := it.Element()
+			if v.IsNull() {
+				return cty.UnknownVal(cty.String), function.NewArgErrorf(1, "cannot join a null string")
+			}
+			parts = append(parts, v.AsString())
+		}
+		return cty.StringVal(strings.Join(parts, sep)), nil
+	},
+})
+
+// SplitFunc is a function that divides a string into a list of strings at
+// each occurrence of the given separator.
+var SplitFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "separator",
+			Type: cty.String,
+		},
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.List(cty.String)),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		parts := strings.Split(args[1].AsString(), args[0].AsString())
+		vals := make([]cty.Value, len(parts))
+		for i, part := range parts {
+			vals[i] = cty.StringVal(part)
+		}
+		return cty.ListVal(vals), nil
+	},
+})
+
+// ReplaceFunc is a function that replaces every occurrence of a substring
+// in a string with another string.
+var ReplaceFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+		{
+			Name: "substr",
+			Type: cty.String,
+		},
+		{
+			Name: "replace",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return cty.StringVal(strings.Replace(args[0].AsString(), args[1].AsString(), args[2].AsString(), -1)), nil
+	},
+})
+
+// TrimSpaceFunc is a function that removes leading and trailing whitespace
+// from a string.
+var TrimSpaceFunc = function.New(&function.Spec{
+	Params: []function.Parameter{
+		{
+			Name: "str",
+			Type: cty.String,
+		},
+	},
+	Type: function.StaticReturnType(cty.String),
+	Impl: func(args []cty.Value, retType cty.Type) (cty.Value, error) {
+		return cty.StringVal(strings.TrimSpace(args[0].AsString())), nil
+	},
+})
@@ -0,0 +1,36 @@
+package funcs
+
+import (
+	"github.com/zclconf/go-cty/cty/function"
+	"github.com/zclconf/go-cty/cty/function/stdlib"
+)
+
+// Table returns the full table of functions available in expressions, for
+// a configuration whose relative paths are resolved against the given base
+// directory.
+func Table(baseDir string) map[string]function.Function {
+	return map[string]function.Function{
+		"abspath":      MakeAbsPathFunc(baseDir),
+		"base64decode": Base64DecodeFunc,
+		"base64encode": Base64EncodeFunc,
+		"coalesce":     CoalesceFunc,
+		"file":         MakeFileFunc(baseDir),
+		"format":       stdlib.FormatFunc,
+		"join":         JoinFunc,
+		"jsondecode":   stdlib.JSONDecodeFunc,
+		"jsonencode":   stdlib.JSONEncodeFunc,
+		"keys":         KeysFunc,
+		"length":       stdlib.LengthFunc,
+		"lookup":       LookupFunc,
+		"lower":        stdlib.LowerFunc,
+		"md5":          MD5Func,
+		"merge":        MergeFunc,
+		"nonsensitive": NonsensitiveFunc,
+		"replace":      ReplaceFunc,
+		"sha256":       SHA256Func,
+		"split":        SplitFunc,
+		"trimspace":    TrimSpaceFunc,
+		"upper":        stdlib.UpperFunc,
+		"urlencode":    URLEncodeFunc,
+	}
+}
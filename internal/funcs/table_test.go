@@ -0,0 +1,136 @@
+package funcs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTable(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-funcs-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "greeting.txt"), []byte("hello\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr string
+		want cty.Value
+	}{
+		{
+			`upper("hello")`,
+			cty.StringVal("HELLO"),
+		},
+		{
+			`format("%s-%d", "a", 1)`,
+			cty.StringVal("a-1"),
+		},
+		{
+			`join(",", ["a", "b", "c"])`,
+			cty.StringVal("a,b,c"),
+		},
+		{
+			`split(",", "a,b")`,
+			cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+		{
+			`replace("a-b-c", "-", "+")`,
+			cty.StringVal("a+b+c"),
+		},
+		{
+			`trimspace("  a b \n")`,
+			cty.StringVal("a b"),
+		},
+		{
+			`base64encode("hello")`,
+			cty.StringVal("aGVsbG8="),
+		},
+		{
+			`base64decode("aGVsbG8=")`,
+			cty.StringVal("hello"),
+		},
+		{
+			`jsonencode({a = 1})`,
+			cty.StringVal(`{"a":1}`),
+		},
+		{
+			`jsondecode("{\"a\":true}").a`,
+			cty.True,
+		},
+		{
+			`urlencode("a b&c")`,
+			cty.StringVal("a+b%26c"),
+		},
+		{
+			`sha256("hello")`,
+			cty.StringVal("2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"),
+		},
+		{
+			`md5("hello")`,
+			cty.StringVal("5d41402abc4b2a76b9719d911017c592"),
+		},
+		{
+			`merge({a = 1, b = 2}, {b = 3})`,
+			cty.ObjectVal(map[string]cty.Value{
+				"a": cty.NumberIntVal(1),
+				"b": cty.NumberIntVal(3),
+			}),
+		},
+		{
+			`lookup({a = "x"}, "a")`,
+			cty.StringVal("x"),
+		},
+		{
+			`lookup({a = "x"}, "b", "y")`,
+			cty.StringVal("y"),
+		},
+		{
+			`keys({b = 1, a = 2})`,
+			cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+		},
+		{
+			`coalesce("", "b")`,
+			cty.StringVal("b"),
+		},
+		{
+			`nonsensitive("a")`,
+			cty.StringVal("a"),
+		},
+		{
+			`file("greeting.txt")`,
+			cty.StringVal("hello\n"),
+		},
+		{
+			`abspath("sub/../greeting.txt")`,
+			cty.StringVal(filepath.Join(dir, "greeting.txt")),
+		},
+	}
+
+	ctx := &hcl.EvalContext{
+		Functions: Table(dir),
+	}
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			expr, diags := hclsyntax.ParseExpression([]byte(test.expr), "", hcl.Pos{Line: 1, Column: 1})
+			if diags.HasErrors() {
+				t.Fatalf("unexpected parse errors: %s", diags.Error())
+			}
+			got, diags := expr.Value(ctx)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diags.Error())
+			}
+			if !got.RawEquals(test.want) {
+				t.Errorf("wrong result\ngot:  %#v\nwant: %#v", got, test.want)
+			}
+		})
+	}
+}
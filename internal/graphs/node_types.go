@@ -32,3 +32,42 @@ var _ Node = (*HelperNode)(nil)
 func (n *HelperNode) ReferenceableAddr() addrs.Referenceable {
 	return n.Addr
 }
+
+// VariableNode is a Node representing an input Variable.
+type VariableNode struct {
+	Addr addrs.Variable
+	graphNodeImpl
+}
+
+var _ Node = (*VariableNode)(nil)
+
+// ReferenceableAddr is the implementation of ReferenceableNode.
+func (n *VariableNode) ReferenceableAddr() addrs.Referenceable {
+	return n.Addr
+}
+
+// SharedObjectNode is a Node representing a SharedObject.
+type SharedObjectNode struct {
+	Addr addrs.SharedObject
+	graphNodeImpl
+}
+
+var _ Node = (*SharedObjectNode)(nil)
+
+// ReferenceableAddr is the implementation of ReferenceableNode.
+func (n *SharedObjectNode) ReferenceableAddr() addrs.Referenceable {
+	return n.Addr
+}
+
+// AutoEnvNode is a Node representing the auto_env block of a configuration.
+type AutoEnvNode struct {
+	Addr addrs.AutoEnv
+	graphNodeImpl
+}
+
+var _ Node = (*AutoEnvNode)(nil)
+
+// ReferenceableAddr is the implementation of ReferenceableNode.
+func (n *AutoEnvNode) ReferenceableAddr() addrs.Referenceable {
+	return n.Addr
+}
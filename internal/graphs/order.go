@@ -0,0 +1,57 @@
+package graphs
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DependencyOrder returns all of the nodes in the graph in an order where
+// each node appears after all of the nodes that it refers to.
+//
+// Nodes that have no dependency relationship with one another are ordered
+// by their debug names, so that the result is deterministic.
+//
+// If the graph contains a cycle then DependencyOrder returns an error naming
+// one of the nodes involved in it.
+func (g *Graph) DependencyOrder() ([]Node, error) {
+	g.l.RLock()
+	defer g.l.RUnlock()
+
+	remain := make(map[Node]int, len(g.nodes))
+	var ready []Node
+	for n := range g.nodes {
+		remain[n] = len(g.edgesOut[n])
+		if remain[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+
+	ret := make([]Node, 0, len(g.nodes))
+	for len(ready) > 0 {
+		sort.SliceStable(ready, func(i, j int) bool {
+			return NodeDebugName(ready[i]) < NodeDebugName(ready[j])
+		})
+		n := ready[0]
+		ready = ready[1:]
+		ret = append(ret, n)
+
+		for referrer := range g.edgesIn[n] {
+			remain[referrer]--
+			if remain[referrer] == 0 {
+				ready = append(ready, referrer)
+			}
+		}
+	}
+
+	if len(ret) != len(g.nodes) {
+		var names []string
+		for n, count := range remain {
+			if count > 0 {
+				names = append(names, NodeDebugName(n))
+			}
+		}
+		sort.Strings(names)
+		return ret, fmt.Errorf("%s is part of a dependency cycle", names[0])
+	}
+	return ret, nil
+}
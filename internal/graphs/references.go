@@ -3,6 +3,7 @@ package graphs
 import (
 	"envy.pw/cli/internal/addrs"
 	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/logging"
 	"envy.pw/cli/internal/nvdiags"
 )
 
@@ -34,6 +35,14 @@ type ReferrerNode interface {
 // creating diagnostics.
 type ReferenceableNodeFactory func(referrer addrs.Referenceable, ref configs.Reference) (Node, nvdiags.Diagnostics)
 
+// A ReferenceCheck is a function that decides whether a reference found
+// while building a graph is permitted, returning error diagnostics if not.
+// A reference that fails the check doesn't produce a node or an edge.
+//
+// Unlike a ReferenceableNodeFactory, which is called only for the first
+// reference to each object, a ReferenceCheck is called for every reference.
+type ReferenceCheck func(referrer addrs.Referenceable, ref configs.Reference) nvdiags.Diagnostics
+
 // NodeReferences is a helper to get the references for a node that may or
 // may not implement ReferrerNode.
 func NodeReferences(node Node) []configs.Reference {
@@ -55,7 +64,9 @@ func NodeReferenceableAddr(node Node) addrs.Referenceable {
 // AddWithReferents adds the given start node along with nodes representing
 // objects it refers to directly or indirectly and edges representing the
 // dependencies implied by those references.
-func (g *Graph) AddWithReferents(start Node, factory ReferenceableNodeFactory) nvdiags.Diagnostics {
+//
+// If check is not nil then it is called for each of the references first.
+func (g *Graph) AddWithReferents(start Node, factory ReferenceableNodeFactory, check ReferenceCheck) nvdiags.Diagnostics {
 	g.l.Lock()
 	defer g.l.Unlock()
 
@@ -72,15 +83,24 @@ func (g *Graph) AddWithReferents(start Node, factory ReferenceableNodeFactory) n
 		nodes[addr] = n
 	}
 
-	return g.addReferents(start, nodes, factory)
+	return g.addReferents(start, nodes, factory, check)
 }
 
-func (g *Graph) addReferents(current Node, nodes map[addrs.Referenceable]Node, factory ReferenceableNodeFactory) nvdiags.Diagnostics {
+func (g *Graph) addReferents(current Node, nodes map[addrs.Referenceable]Node, factory ReferenceableNodeFactory, check ReferenceCheck) nvdiags.Diagnostics {
 	var diags nvdiags.Diagnostics
 	refs := NodeReferences(current)
 
 	for _, ref := range refs {
+		if check != nil {
+			moreDiags := check(NodeReferenceableAddr(current), ref)
+			diags = diags.Append(moreDiags)
+			if moreDiags.HasErrors() {
+				continue
+			}
+		}
+
 		target, exists := nodes[ref.Addr]
+		isNew := false
 		if target == nil && !exists {
 			newTarget, moreDiags := factory(NodeReferenceableAddr(current), ref)
 			diags = diags.Append(moreDiags)
@@ -89,13 +109,24 @@ func (g *Graph) addReferents(current Node, nodes map[addrs.Referenceable]Node, f
 			}
 			target = newTarget
 			nodes[ref.Addr] = newTarget
+			isNew = true
+			if target != nil {
+				logging.Trace("adding graph node", "node", NodeDebugName(target), "ref", ref.SourceRange)
+			}
 		}
 		if target == nil {
 			continue
 		}
 
+		if !g.edgesOut[current].Has(target) {
+			logging.Trace("adding graph edge", "from", NodeDebugName(current), "to", NodeDebugName(target))
+		}
 		g.connect(current, target) // implicitly adds target if it isn't already present
-		g.addReferents(target, nodes, factory)
+		if isNew {
+			// The referents of an existing node were already added when
+			// it was created, so we visit each node only once.
+			diags = diags.Append(g.addReferents(target, nodes, factory, check))
+		}
 	}
 
 	return diags
@@ -0,0 +1,240 @@
+// Package awssts contains the "aws_assume_role" helper type, which obtains
+// temporary AWS credentials from the AWS Security Token Service.
+package awssts // import "envy.pw/cli/internal/helpers/awssts"
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// Type is the implementation of helpers.Type for the "aws_assume_role"
+// helper type.
+type Type struct {
+	// HTTPClient is the client used to make requests to the STS API.
+	HTTPClient *http.Client
+}
+
+var _ helpers.Type = (*Type)(nil)
+var _ helpers.NonsensitiveResults = (*Type)(nil)
+
+// NewType returns a new aws_assume_role helper type that uses the default
+// HTTP client.
+func NewType() *Type {
+	return &Type{
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// ConfigSpec implements helpers.Type.
+func (t *Type) ConfigSpec() hcldec.Spec {
+	spec := hcldec.ObjectSpec{}
+	for _, name := range []string{
+		"access_key_id", "secret_access_key", "session_token",
+		"role_arn", "role_session_name", "external_id",
+		"mfa_serial", "mfa_code",
+		"region", "endpoint",
+	} {
+		spec[name] = &hcldec.AttrSpec{
+			Name: name,
+			Type: cty.String,
+		}
+	}
+	spec["duration_seconds"] = &hcldec.AttrSpec{
+		Name: "duration_seconds",
+		Type: cty.Number,
+	}
+	return &spec
+}
+
+// NonsensitiveAttrs implements helpers.NonsensitiveResults.
+func (t *Type) NonsensitiveAttrs() []string {
+	return []string{"expiration", "region"}
+}
+
+// NewInstance implements helpers.Type.
+func (t *Type) NewInstance(meta helpers.InstanceMeta) helpers.Instance {
+	return &instance{typ: t, addr: meta.Addr}
+}
+
+type instance struct {
+	typ       *Type
+	addr      addrs.Helper
+	refreshAt time.Time
+
+	// mfaSession holds the credentials from a GetSessionToken call made
+	// with an MFA code, which are used to assume the role again when
+	// refreshing, because STS won't accept the same MFA code twice.
+	// mfaSessionKey identifies the source credentials and MFA device that
+	// the session belongs to.
+	mfaSession    *stsCredentials
+	mfaSessionKey string
+}
+
+var _ helpers.Refresher = (*instance)(nil)
+
+func (i *instance) Update(ctx context.Context, config cty.Value) (cty.Value, error) {
+	region := stringAttr(config, "region", firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"))
+	endpoint := "https://sts.amazonaws.com/"
+	signingRegion := "us-east-1"
+	if region != "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", region)
+		signingRegion = region
+	}
+	endpoint = stringAttr(config, "endpoint", endpoint)
+
+	c := &client{
+		endpoint: endpoint,
+		region:   signingRegion,
+		creds: credentials{
+			AccessKeyID:     stringAttr(config, "access_key_id", os.Getenv("AWS_ACCESS_KEY_ID")),
+			SecretAccessKey: stringAttr(config, "secret_access_key", os.Getenv("AWS_SECRET_ACCESS_KEY")),
+			SessionToken:    stringAttr(config, "session_token", os.Getenv("AWS_SESSION_TOKEN")),
+		},
+		http: i.typ.HTTPClient,
+	}
+	if c.creds.AccessKeyID == "" || c.creds.SecretAccessKey == "" {
+		return cty.NilVal, fmt.Errorf("no source credentials are available; set access_key_id and secret_access_key, or the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables")
+	}
+
+	params := url.Values{}
+	duration := 3600
+	if v := config.GetAttr("duration_seconds"); !v.IsNull() {
+		if err := gocty.FromCtyValue(v, &duration); err != nil {
+			return cty.NilVal, fmt.Errorf("invalid duration_seconds: %s", err)
+		}
+	}
+	params.Set("DurationSeconds", strconv.Itoa(duration))
+
+	roleARN := stringAttr(config, "role_arn", "")
+	serial := stringAttr(config, "mfa_serial", "")
+	mfaParams := func() (url.Values, error) {
+		code := stringAttr(config, "mfa_code", "")
+		if code == "" {
+			return nil, fmt.Errorf("mfa_code is required when mfa_serial is set")
+		}
+		return url.Values{
+			"SerialNumber": {serial},
+			"TokenCode":    {code},
+		}, nil
+	}
+	switch {
+	case serial != "" && roleARN != "":
+		// An MFA code can be used only once, so rather than sending it with
+		// AssumeRole we use it to start an MFA session, and assume the role
+		// with the session's credentials both now and at each refresh.
+		key := serial + "\x00" + c.creds.AccessKeyID
+		if i.mfaSession == nil || i.mfaSessionKey != key || !time.Now().Before(i.mfaSession.Expiration) {
+			sessionParams, err := mfaParams()
+			if err != nil {
+				return cty.NilVal, err
+			}
+			session, err := c.call(ctx, "GetSessionToken", sessionParams)
+			if err != nil {
+				return cty.NilVal, err
+			}
+			i.mfaSession = session
+			i.mfaSessionKey = key
+		}
+		c.creds = credentials{
+			AccessKeyID:     i.mfaSession.AccessKeyID,
+			SecretAccessKey: i.mfaSession.SecretAccessKey,
+			SessionToken:    i.mfaSession.SessionToken,
+		}
+	case serial != "":
+		moreParams, err := mfaParams()
+		if err != nil {
+			return cty.NilVal, err
+		}
+		for k, v := range moreParams {
+			params[k] = v
+		}
+	}
+
+	action := "GetSessionToken"
+	if roleARN != "" {
+		action = "AssumeRole"
+		params.Set("RoleArn", roleARN)
+		params.Set("RoleSessionName", stringAttr(config, "role_session_name", "envy-"+i.addr.Name))
+		if externalID := stringAttr(config, "external_id", ""); externalID != "" {
+			params.Set("ExternalId", externalID)
+		}
+	}
+
+	creds, err := c.call(ctx, action, params)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	// We'll refresh the credentials once two thirds of their lifetime has
+	// passed, so that the child never sees them expire. With MFA, though,
+	// we can refresh only while the MFA session lasts, and a session token
+	// obtained directly with an MFA code can't be refreshed at all without
+	// prompting for a new code, so it lasts only for its own lifetime.
+	i.refreshAt = time.Time{}
+	if ttl := time.Until(creds.Expiration); ttl > 0 {
+		refreshAt := time.Now().Add(ttl * 2 / 3)
+		switch {
+		case serial != "" && roleARN == "":
+		case serial != "" && !i.mfaSession.Expiration.After(refreshAt):
+		default:
+			i.refreshAt = refreshAt
+		}
+	}
+
+	env := map[string]cty.Value{
+		"AWS_ACCESS_KEY_ID":     cty.StringVal(creds.AccessKeyID),
+		"AWS_SECRET_ACCESS_KEY": cty.StringVal(creds.SecretAccessKey),
+		"AWS_SESSION_TOKEN":     cty.StringVal(creds.SessionToken),
+	}
+	if region != "" {
+		env["AWS_REGION"] = cty.StringVal(region)
+		env["AWS_DEFAULT_REGION"] = cty.StringVal(region)
+	}
+
+	return cty.ObjectVal(map[string]cty.Value{
+		"access_key_id":     cty.StringVal(creds.AccessKeyID),
+		"secret_access_key": cty.StringVal(creds.SecretAccessKey),
+		"session_token":     cty.StringVal(creds.SessionToken),
+		"expiration":        cty.StringVal(creds.Expiration.UTC().Format(time.RFC3339)),
+		"region":            cty.StringVal(region),
+		"env":               cty.MapVal(env),
+	}), nil
+}
+
+func (i *instance) NextRefresh() time.Time {
+	return i.refreshAt
+}
+
+func (i *instance) Close() error {
+	// Temporary STS credentials cannot be revoked early, so there's nothing
+	// to clean up.
+	return nil
+}
+
+func stringAttr(obj cty.Value, name, def string) string {
+	if v := obj.GetAttr(name); !v.IsNull() {
+		return v.AsString()
+	}
+	return def
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
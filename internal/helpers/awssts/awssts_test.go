@@ -0,0 +1,205 @@
+package awssts
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestSignRequest(t *testing.T) {
+	// This is the example request from the AWS Signature Version 4
+	// documentation.
+	req, _ := http.NewRequest("GET", "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signRequest(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	got := req.Header.Get("Authorization")
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got != want {
+		t.Errorf("wrong Authorization header\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestAssumeRole(t *testing.T) {
+	sts := newFakeSTS()
+	server := httptest.NewServer(sts)
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client()}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("aws_assume_role", "prod")})
+	config := testConfig(map[string]cty.Value{
+		"access_key_id":     cty.StringVal("AKIDSOURCE"),
+		"secret_access_key": cty.StringVal("sourcesecret"),
+		"role_arn":          cty.StringVal("arn:aws:iam::123456789012:role/deploy"),
+		"mfa_serial":        cty.StringVal("arn:aws:iam::123456789012:mfa/me"),
+		"mfa_code":          cty.StringVal("123456"),
+		"region":            cty.StringVal("eu-west-1"),
+		"endpoint":          cty.StringVal(server.URL),
+	})
+
+	got, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, want := len(sts.calls), 2; got != want {
+		t.Fatalf("wrong number of STS calls %d; want %d", got, want)
+	}
+	wantSession := map[string]string{
+		"Action":       "GetSessionToken",
+		"SerialNumber": "arn:aws:iam::123456789012:mfa/me",
+		"TokenCode":    "123456",
+		"key":          "AKIDSOURCE",
+	}
+	wantRole := map[string]string{
+		"Action":          "AssumeRole",
+		"Version":         "2011-06-15",
+		"RoleArn":         "arn:aws:iam::123456789012:role/deploy",
+		"RoleSessionName": "envy-prod",
+		"DurationSeconds": "3600",
+		"SerialNumber":    "",
+		"TokenCode":       "",
+		"key":             "ASIASESSION",
+	}
+	for i, want := range []map[string]string{wantSession, wantRole} {
+		for k, want := range want {
+			if got := sts.calls[i][k]; got != want {
+				t.Errorf("wrong %s %q in call %d; want %q", k, got, i, want)
+			}
+		}
+	}
+
+	if got, want := got.GetAttr("session_token"), cty.StringVal("temptoken"); !got.RawEquals(want) {
+		t.Errorf("wrong session_token %#v; want %#v", got, want)
+	}
+	env := got.GetAttr("env")
+	if got, want := env.Index(cty.StringVal("AWS_ACCESS_KEY_ID")), cty.StringVal("ASIATEMP"); !got.RawEquals(want) {
+		t.Errorf("wrong AWS_ACCESS_KEY_ID %#v; want %#v", got, want)
+	}
+	if got, want := env.Index(cty.StringVal("AWS_REGION")), cty.StringVal("eu-west-1"); !got.RawEquals(want) {
+		t.Errorf("wrong AWS_REGION %#v; want %#v", got, want)
+	}
+	if next := inst.(*instance).NextRefresh(); next.IsZero() || next.After(time.Now().Add(time.Hour)) {
+		t.Errorf("wrong refresh time %s", next)
+	}
+
+	t.Run("refresh", func(t *testing.T) {
+		// The prompt helper gives the same code again, which STS would
+		// reject, so the refresh must reuse the MFA session instead.
+		sts.calls = nil
+		if _, err := inst.Update(context.Background(), config); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got, want := len(sts.calls), 1; got != want {
+			t.Fatalf("wrong number of STS calls %d; want %d", got, want)
+		}
+		for k, want := range wantRole {
+			if got := sts.calls[0][k]; got != want {
+				t.Errorf("wrong %s %q; want %q", k, got, want)
+			}
+		}
+	})
+}
+
+func TestGetSessionTokenMFA(t *testing.T) {
+	sts := newFakeSTS()
+	server := httptest.NewServer(sts)
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client()}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("aws_assume_role", "session")})
+	config := testConfig(map[string]cty.Value{
+		"access_key_id":     cty.StringVal("AKIDSOURCE"),
+		"secret_access_key": cty.StringVal("sourcesecret"),
+		"mfa_serial":        cty.StringVal("arn:aws:iam::123456789012:mfa/me"),
+		"mfa_code":          cty.StringVal("123456"),
+		"endpoint":          cty.StringVal(server.URL),
+	})
+
+	if _, err := inst.Update(context.Background(), config); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := sts.calls[0]["TokenCode"], "123456"; got != want {
+		t.Errorf("wrong TokenCode %q; want %q", got, want)
+	}
+	// Refreshing would need a new MFA code, so the credentials must last
+	// for their whole lifetime instead.
+	if next := inst.(*instance).NextRefresh(); !next.IsZero() {
+		t.Errorf("unexpected refresh at %s", next)
+	}
+}
+
+// fakeSTS is a minimal fake of the STS API, which issues credentials valid
+// for an hour and rejects MFA codes that have already been used.
+type fakeSTS struct {
+	// calls records the form of each request, with the access key ID that
+	// signed it as "key".
+	calls     []map[string]string
+	usedCodes map[string]bool
+}
+
+func newFakeSTS() *fakeSTS {
+	return &fakeSTS{usedCodes: map[string]bool{}}
+}
+
+func (s *fakeSTS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fail := func(code string) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<ErrorResponse><Error><Code>` + code + `</Code><Message>bad</Message></Error></ErrorResponse>`))
+	}
+	auth := strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=")
+	key := strings.SplitN(auth, "/", 2)[0]
+	if key != "AKIDSOURCE" && key != "ASIASESSION" {
+		fail("InvalidClientTokenId")
+		return
+	}
+	r.ParseForm()
+	form := map[string]string{"key": key}
+	for k := range r.PostForm {
+		form[k] = r.PostForm.Get(k)
+	}
+	s.calls = append(s.calls, form)
+	if code := form["TokenCode"]; code != "" {
+		if s.usedCodes[code] {
+			fail("AccessDenied")
+			return
+		}
+		s.usedCodes[code] = true
+	}
+
+	creds := "<AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>tempsecret</SecretAccessKey><SessionToken>temptoken</SessionToken>"
+	if form["Action"] == "GetSessionToken" {
+		creds = "<AccessKeyId>ASIASESSION</AccessKeyId><SecretAccessKey>sessionsecret</SecretAccessKey><SessionToken>sessiontoken</SessionToken>"
+	}
+	exp := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	w.Write([]byte(`<` + form["Action"] + `Response xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <` + form["Action"] + `Result>
+    <Credentials>` + creds + `<Expiration>` + exp + `</Expiration></Credentials>
+  </` + form["Action"] + `Result>
+</` + form["Action"] + `Response>`))
+}
+
+func testConfig(attrs map[string]cty.Value) cty.Value {
+	all := map[string]cty.Value{}
+	for name := range *(&Type{}).ConfigSpec().(*hcldec.ObjectSpec) {
+		all[name] = cty.NullVal(cty.String)
+	}
+	all["duration_seconds"] = cty.NullVal(cty.Number)
+	for k, v := range attrs {
+		all[k] = v
+	}
+	return cty.ObjectVal(all)
+}
@@ -0,0 +1,91 @@
+package awssts
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// client is a minimal client for the AWS Security Token Service query API.
+type client struct {
+	endpoint string
+	region   string
+	creds    credentials
+	http     *http.Client
+}
+
+// stsCredentials is the structure of the temporary credentials returned by
+// both AssumeRole and GetSessionToken.
+type stsCredentials struct {
+	AccessKeyID     string    `xml:"AccessKeyId"`
+	SecretAccessKey string    `xml:"SecretAccessKey"`
+	SessionToken    string    `xml:"SessionToken"`
+	Expiration      time.Time `xml:"Expiration"`
+}
+
+// call makes a request for the given STS action and returns the temporary
+// credentials from the response.
+func (c *client) call(ctx context.Context, action string, params url.Values) (*stsCredentials, error) {
+	form := url.Values{}
+	for k, v := range params {
+		form[k] = v
+	}
+	form.Set("Action", action)
+	form.Set("Version", "2011-06-15")
+	body := []byte(form.Encode())
+
+	req, err := http.NewRequest("POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signRequest(req, body, c.creds, c.region, "sts", time.Now())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	src, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error struct {
+				Code    string `xml:"Code"`
+				Message string `xml:"Message"`
+			} `xml:"Error"`
+		}
+		if xml.Unmarshal(src, &errResp) == nil && errResp.Error.Code != "" {
+			return nil, fmt.Errorf("%s failed: %s: %s", action, errResp.Error.Code, errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("%s failed: unexpected response %s", action, resp.Status)
+	}
+
+	// AssumeRole and GetSessionToken responses differ only in the name of
+	// the result element, so we can decode both the same way.
+	var result struct {
+		Results []struct {
+			XMLName     xml.Name
+			Credentials *stsCredentials `xml:"Credentials"`
+		} `xml:",any"`
+	}
+	if err := xml.Unmarshal(src, &result); err != nil {
+		return nil, fmt.Errorf("%s returned invalid response: %s", action, err)
+	}
+	for _, r := range result.Results {
+		if strings.HasSuffix(r.XMLName.Local, "Result") && r.Credentials != nil {
+			return r.Credentials, nil
+		}
+	}
+	return nil, fmt.Errorf("%s response did not include credentials", action)
+}
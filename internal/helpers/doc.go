@@ -0,0 +1,5 @@
+// Package helpers defines the interfaces that helper types implement in
+// order to produce data for use in commands.
+//
+// The helper types themselves live in subpackages of this package.
+package helpers // import "envy.pw/cli/internal/helpers"
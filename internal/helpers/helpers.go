@@ -0,0 +1,102 @@
+package helpers
+
+import (
+	"context"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Type is an interface implemented by each of the available helper types.
+//
+// A Type is a factory for Instances, each of which corresponds to a single
+// "helper" block in the configuration.
+type Type interface {
+	// ConfigSpec returns the specification used to decode the body of
+	// a helper block of this type.
+	ConfigSpec() hcldec.Spec
+
+	// NewInstance creates a new, not-yet-configured instance of the type
+	// for the helper described by the given metadata.
+	NewInstance(meta InstanceMeta) Instance
+}
+
+// InstanceMeta describes the helper block that an instance belongs to.
+type InstanceMeta struct {
+	Addr addrs.Helper
+
+	// TempDir is a private directory that the instance may use for any
+	// files it creates. It is the same directory that "path.temp" refers to
+	// in the helper's configuration, and will be deleted after the instance
+	// is closed.
+	TempDir string
+
+	// ConfigDir is the directory containing the configuration that declared
+	// the helper, which any relative paths in its configuration should be
+	// resolved relative to.
+	ConfigDir string
+
+	// Functions are the functions available in the helper's configuration,
+	// for use by helper types that evaluate further expressions of their
+	// own, such as templates.
+	Functions map[string]function.Function
+}
+
+// Instance is an interface implemented by the instances of a helper type.
+type Instance interface {
+	// Update configures the instance using the given configuration value,
+	// which conforms to the type's ConfigSpec, and returns the resulting
+	// value that will be exposed to expressions elsewhere in the
+	// configuration.
+	//
+	// Update is called again whenever the configuration changes, and also
+	// with an unchanged configuration when a Refresher asks to be refreshed.
+	Update(ctx context.Context, config cty.Value) (cty.Value, error)
+
+	// Close releases any resources held by the instance. The instance must
+	// not be used again after Close returns.
+	Close() error
+}
+
+// Refresher is an optional interface implemented by instances whose results
+// can expire, such as time-limited credentials.
+type Refresher interface {
+	Instance
+
+	// NextRefresh returns the time at which Update should next be called,
+	// even if the configuration hasn't changed, or the zero time if no
+	// refresh is needed.
+	NextRefresh() time.Time
+}
+
+// CacheReporter is an optional interface implemented by instances that can
+// reuse something cached by an earlier run of envy, such as a refresh token,
+// so that the audit log can record whether they did.
+type CacheReporter interface {
+	Instance
+
+	// UsedCache returns true if the most recent successful call to Update
+	// produced its result using something cached by an earlier run, rather
+	// than obtaining it anew.
+	UsedCache() bool
+}
+
+// NonsensitiveResults is an optional interface implemented by helper types
+// whose results include attributes that are safe to show, such as
+// expiration times or the paths of files. All other attributes of a helper's
+// result are treated as sensitive.
+type NonsensitiveResults interface {
+	Type
+
+	// NonsensitiveAttrs returns the names of the attributes of the
+	// instances' results that are not sensitive.
+	NonsensitiveAttrs() []string
+}
+
+// Types is a collection of helper types, keyed by the type names used in
+// the configuration language.
+type Types map[string]Type
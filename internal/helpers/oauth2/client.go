@@ -0,0 +1,86 @@
+package oauth2
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tokenResponse is the structure of a successful response from an OAuth 2.0
+// token endpoint, as defined in RFC 6749 section 5.1.
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// deviceAuthResponse is the structure of a response from a device
+// authorization endpoint, as defined in RFC 8628 section 3.2.
+type deviceAuthResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                *int   `json:"interval"`
+}
+
+// oauthError is an error returned from an OAuth 2.0 endpoint.
+type oauthError struct {
+	Code        string
+	Description string
+}
+
+func (e *oauthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("%s: %s", e.Code, e.Description)
+	}
+	return e.Code
+}
+
+// postForm sends a form-encoded POST request to the given endpoint and
+// decodes the JSON response into the given value.
+//
+// If the response includes an "error" property, postForm returns an
+// *oauthError describing it.
+func postForm(ctx context.Context, httpClient *http.Client, endpoint, clientID, clientSecret string, form url.Values, into interface{}) error {
+	req, err := http.NewRequest("POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(clientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("invalid response from %s (%s): %s", endpoint, resp.Status, err)
+	}
+	var errResp struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(raw, &errResp) == nil && errResp.Error != "" {
+		return &oauthError{
+			Code:        errResp.Error,
+			Description: errResp.ErrorDescription,
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+	}
+	return json.Unmarshal(raw, into)
+}
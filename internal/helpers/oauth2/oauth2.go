@@ -0,0 +1,456 @@
+// Package oauth2 contains the "oauth2" helper type, which obtains access
+// tokens from an OAuth 2.0 authorization server.
+package oauth2 // import "envy.pw/cli/internal/helpers/oauth2"
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/secrets"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/gocty"
+)
+
+// slowDownIncrease is how much longer the device grant waits between polls
+// each time the authorization server asks it to slow down, as RFC 8628
+// requires.
+var slowDownIncrease = 5 * time.Second
+
+// OpenStoreFunc is the signature of a function that opens the secret store,
+// creating it if necessary, so that refresh tokens can be cached there.
+type OpenStoreFunc func() (*secrets.Store, error)
+
+// Type is the implementation of helpers.Type for the "oauth2" helper type.
+type Type struct {
+	// HTTPClient is the client used to make requests to the authorization
+	// server.
+	HTTPClient *http.Client
+
+	// Messages is where instructions for the user are written during the
+	// device authorization grant.
+	Messages io.Writer
+
+	openStore OpenStoreFunc
+}
+
+var _ helpers.Type = (*Type)(nil)
+var _ helpers.NonsensitiveResults = (*Type)(nil)
+
+// NewType returns a new oauth2 helper type that caches refresh tokens in the
+// secret store returned by the given function.
+func NewType(openStore OpenStoreFunc) *Type {
+	return &Type{
+		HTTPClient: http.DefaultClient,
+		Messages:   os.Stderr,
+		openStore:  openStore,
+	}
+}
+
+// ConfigSpec implements helpers.Type.
+func (t *Type) ConfigSpec() hcldec.Spec {
+	return &hcldec.ObjectSpec{
+		"grant": &hcldec.AttrSpec{
+			Name:     "grant",
+			Type:     cty.String,
+			Required: true,
+		},
+		"token_url": &hcldec.AttrSpec{
+			Name:     "token_url",
+			Type:     cty.String,
+			Required: true,
+		},
+		"device_authorization_url": &hcldec.AttrSpec{
+			Name: "device_authorization_url",
+			Type: cty.String,
+		},
+		"client_id": &hcldec.AttrSpec{
+			Name:     "client_id",
+			Type:     cty.String,
+			Required: true,
+		},
+		"client_secret": &hcldec.AttrSpec{
+			Name: "client_secret",
+			Type: cty.String,
+		},
+		"scopes": &hcldec.AttrSpec{
+			Name: "scopes",
+			Type: cty.List(cty.String),
+		},
+		"refresh_token": &hcldec.AttrSpec{
+			Name: "refresh_token",
+			Type: cty.String,
+		},
+		"params": &hcldec.AttrSpec{
+			Name: "params",
+			Type: cty.Map(cty.String),
+		},
+		"cache_refresh_token": &hcldec.AttrSpec{
+			Name: "cache_refresh_token",
+			Type: cty.Bool,
+		},
+	}
+}
+
+// NonsensitiveAttrs implements helpers.NonsensitiveResults.
+func (t *Type) NonsensitiveAttrs() []string {
+	return []string{"token_type", "expires_at"}
+}
+
+// NewInstance implements helpers.Type.
+func (t *Type) NewInstance(meta helpers.InstanceMeta) helpers.Instance {
+	return &instance{typ: t, addr: meta.Addr}
+}
+
+// The supported values for the "grant" argument.
+const (
+	grantClientCredentials = "client_credentials"
+	grantRefreshToken      = "refresh_token"
+	grantDevice            = "device"
+)
+
+type instance struct {
+	typ  *Type
+	addr addrs.Helper
+
+	settings     *settings
+	refreshToken string
+	refreshAt    time.Time
+
+	// fromCache is true if refreshToken descends from a refresh token that
+	// was cached in the secret store by an earlier run, and usedCache is
+	// true if the most recent successful Update used it.
+	fromCache bool
+	usedCache bool
+}
+
+var _ helpers.Refresher = (*instance)(nil)
+var _ helpers.CacheReporter = (*instance)(nil)
+
+// settings is a more convenient representation of the helper configuration.
+type settings struct {
+	Grant         string
+	TokenURL      string
+	DeviceAuthURL string
+	ClientID      string
+	ClientSecret  string
+	Scopes        []string
+	RefreshToken  string
+	Params        map[string]string
+	Cache         bool
+}
+
+func decodeSettings(config cty.Value) (*settings, error) {
+	s := &settings{
+		Grant:    config.GetAttr("grant").AsString(),
+		TokenURL: config.GetAttr("token_url").AsString(),
+		ClientID: config.GetAttr("client_id").AsString(),
+		Cache:    true,
+	}
+	if v := config.GetAttr("device_authorization_url"); !v.IsNull() {
+		s.DeviceAuthURL = v.AsString()
+	}
+	if v := config.GetAttr("client_secret"); !v.IsNull() {
+		s.ClientSecret = v.AsString()
+	}
+	if v := config.GetAttr("scopes"); !v.IsNull() {
+		if err := gocty.FromCtyValue(v, &s.Scopes); err != nil {
+			return nil, fmt.Errorf("invalid scopes: %s", err)
+		}
+	}
+	if v := config.GetAttr("refresh_token"); !v.IsNull() {
+		s.RefreshToken = v.AsString()
+	}
+	if v := config.GetAttr("params"); !v.IsNull() {
+		if err := gocty.FromCtyValue(v, &s.Params); err != nil {
+			return nil, fmt.Errorf("invalid params: %s", err)
+		}
+	}
+	if v := config.GetAttr("cache_refresh_token"); !v.IsNull() {
+		s.Cache = v.True()
+	}
+
+	switch s.Grant {
+	case grantClientCredentials:
+		// Client credentials are renewed by just repeating the grant, so
+		// there's never a refresh token to cache.
+		s.Cache = false
+	case grantRefreshToken:
+		if s.RefreshToken == "" {
+			return nil, fmt.Errorf("refresh_token is required for the %q grant", s.Grant)
+		}
+	case grantDevice:
+		if s.DeviceAuthURL == "" {
+			return nil, fmt.Errorf("device_authorization_url is required for the %q grant", s.Grant)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported grant %q; must be %q, %q, or %q", s.Grant, grantClientCredentials, grantRefreshToken, grantDevice)
+	}
+	return s, nil
+}
+
+func (i *instance) Update(ctx context.Context, config cty.Value) (cty.Value, error) {
+	s, err := decodeSettings(config)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	if i.settings == nil || !s.sameClient(i.settings) {
+		// Any refresh token we already have belongs to a different client
+		// configuration, so we must start over.
+		i.refreshToken = s.RefreshToken
+		i.fromCache = false
+		if cached := i.cachedRefreshToken(s); cached != "" {
+			i.refreshToken = cached
+			i.fromCache = true
+		}
+	}
+	i.settings = s
+
+	var token *tokenResponse
+	if i.refreshToken != "" {
+		token, err = i.refresh(ctx)
+		if err != nil {
+			if oerr, ok := err.(*oauthError); !ok || oerr.Code != "invalid_grant" || s.Grant == grantRefreshToken {
+				return cty.NilVal, err
+			}
+			// The refresh token has expired or been revoked, so we'll fall
+			// back on performing the main grant again.
+			i.refreshToken = ""
+			i.fromCache = false
+			token = nil
+		}
+	}
+	usedCache := token != nil && i.fromCache
+	if token == nil {
+		switch s.Grant {
+		case grantClientCredentials:
+			token, err = i.clientCredentials(ctx)
+		case grantDevice:
+			token, err = i.device(ctx)
+		}
+		if err != nil {
+			return cty.NilVal, err
+		}
+	}
+
+	if token.RefreshToken != "" {
+		i.refreshToken = token.RefreshToken
+		if err := i.cacheRefreshToken(s, token.RefreshToken); err != nil {
+			// We still have a usable token, so failing to cache it only
+			// means that a later run will need to obtain one again.
+			logging.Info("failed to cache refresh token", "helper", i.addr, "error", err)
+			fmt.Fprintf(i.typ.Messages, "Warning: could not cache the refresh token for %s: %s\n", i.addr, err)
+		}
+	}
+
+	expiresAt := ""
+	i.refreshAt = time.Time{}
+	if token.ExpiresIn > 0 {
+		ttl := time.Duration(token.ExpiresIn) * time.Second
+		expiresAt = time.Now().Add(ttl).UTC().Format(time.RFC3339)
+		i.refreshAt = time.Now().Add(ttl * 2 / 3)
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+
+	i.usedCache = usedCache
+	return cty.ObjectVal(map[string]cty.Value{
+		"access_token":         cty.StringVal(token.AccessToken),
+		"token_type":           cty.StringVal(tokenType),
+		"expires_at":           cty.StringVal(expiresAt),
+		"authorization_header": cty.StringVal(tokenType + " " + token.AccessToken),
+	}), nil
+}
+
+func (i *instance) NextRefresh() time.Time {
+	return i.refreshAt
+}
+
+func (i *instance) UsedCache() bool {
+	return i.usedCache
+}
+
+func (i *instance) Close() error {
+	return nil
+}
+
+func (i *instance) clientCredentials(ctx context.Context) (*tokenResponse, error) {
+	form := i.baseForm()
+	form.Set("grant_type", "client_credentials")
+	return i.requestToken(ctx, form)
+}
+
+func (i *instance) refresh(ctx context.Context) (*tokenResponse, error) {
+	form := i.baseForm()
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", i.refreshToken)
+	return i.requestToken(ctx, form)
+}
+
+// device performs the device authorization grant from RFC 8628, which
+// requires the user to visit a URL to approve the request.
+func (i *instance) device(ctx context.Context) (*tokenResponse, error) {
+	s := i.settings
+	form := i.baseForm()
+	var auth deviceAuthResponse
+	err := postForm(ctx, i.typ.HTTPClient, s.DeviceAuthURL, s.ClientID, s.ClientSecret, form, &auth)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request failed: %s", err)
+	}
+
+	if auth.VerificationURIComplete != "" {
+		fmt.Fprintf(i.typ.Messages, "To authorize %s, visit:\n    %s\n", i.addr, auth.VerificationURIComplete)
+	} else {
+		fmt.Fprintf(i.typ.Messages, "To authorize %s, visit:\n    %s\nand enter the code: %s\n", i.addr, auth.VerificationURI, auth.UserCode)
+	}
+
+	interval := 5 * time.Second
+	if auth.Interval != nil {
+		interval = time.Duration(*auth.Interval) * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	form = i.baseForm()
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	form.Set("device_code", auth.DeviceCode)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		token, err := i.requestToken(ctx, form)
+		if oerr, ok := err.(*oauthError); ok {
+			switch oerr.Code {
+			case "authorization_pending", "slow_down":
+				if oerr.Code == "slow_down" {
+					interval += slowDownIncrease
+				}
+				if auth.ExpiresIn > 0 && time.Now().After(deadline) {
+					return nil, fmt.Errorf("device authorization timed out")
+				}
+				continue
+			}
+		}
+		return token, err
+	}
+}
+
+func (i *instance) requestToken(ctx context.Context, form url.Values) (*tokenResponse, error) {
+	s := i.settings
+	var token tokenResponse
+	if err := postForm(ctx, i.typ.HTTPClient, s.TokenURL, s.ClientID, s.ClientSecret, form, &token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("token response from %s did not include an access token", s.TokenURL)
+	}
+	return &token, nil
+}
+
+// baseForm returns the form parameters common to all requests.
+func (i *instance) baseForm() url.Values {
+	s := i.settings
+	form := url.Values{}
+	if s.ClientSecret == "" {
+		// Public clients identify themselves in the request body, while
+		// confidential clients use HTTP basic authentication instead.
+		form.Set("client_id", s.ClientID)
+	}
+	if len(s.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.Scopes, " "))
+	}
+	for k, v := range s.Params {
+		form.Set(k, v)
+	}
+	return form
+}
+
+// cachedToken is the structure of a refresh token cached in the secret
+// store. We record which client it belongs to so that we won't try to use
+// it after the configuration changes.
+type cachedToken struct {
+	TokenURL     string `json:"token_url"`
+	ClientID     string `json:"client_id"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// cacheKey returns the name of the secret where the refresh token for the
+// helper with the given address and settings is cached.
+//
+// The name includes the helper's full address, so that helpers of the same
+// name in different modules don't replace each other's tokens, and a hash
+// of the client ID and token URL, so that helpers that happen to share an
+// address in different projects do so only if they're for the same client.
+func cacheKey(addr addrs.Helper, s *settings) string {
+	h := sha256.Sum256([]byte(s.TokenURL + "\x00" + s.ClientID))
+	return "oauth2/" + addr.String() + "/" + hex.EncodeToString(h[:8])
+}
+
+func (i *instance) cachedRefreshToken(s *settings) string {
+	if !s.Cache || i.typ.openStore == nil {
+		return ""
+	}
+	store, err := i.typ.openStore()
+	if err != nil {
+		return "" // we'll try again when we need to save a token
+	}
+	raw, ok := store.Get(cacheKey(i.addr, s))
+	if !ok {
+		return ""
+	}
+	var cached cachedToken
+	if err := json.Unmarshal([]byte(raw), &cached); err != nil {
+		return ""
+	}
+	if cached.TokenURL != s.TokenURL || cached.ClientID != s.ClientID {
+		return ""
+	}
+	return cached.RefreshToken
+}
+
+func (i *instance) cacheRefreshToken(s *settings, refreshToken string) error {
+	if !s.Cache || i.typ.openStore == nil {
+		return nil
+	}
+	store, err := i.typ.openStore()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(cachedToken{
+		TokenURL:     s.TokenURL,
+		ClientID:     s.ClientID,
+		RefreshToken: refreshToken,
+	})
+	if err != nil {
+		return err
+	}
+	if existing, ok := store.Get(cacheKey(i.addr, s)); ok && existing == string(raw) {
+		return nil
+	}
+	store.Set(cacheKey(i.addr, s), string(raw))
+	return store.Save()
+}
+
+// sameClient returns true if the receiver and the other given settings
+// describe the same OAuth client at the same authorization server, and so
+// can share a refresh token.
+func (s *settings) sameClient(other *settings) bool {
+	return s.TokenURL == other.TokenURL && s.ClientID == other.ClientID
+}
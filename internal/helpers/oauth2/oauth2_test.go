@@ -0,0 +1,298 @@
+package oauth2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/secrets"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fakeAuthServer is a stand-in for an OAuth 2.0 authorization server
+// supporting the grants that the helper uses.
+type fakeAuthServer struct {
+	mu     sync.Mutex
+	polls  int
+	grants []string
+}
+
+func (s *fakeAuthServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.ParseForm()
+
+	respond := func(status int, body map[string]interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}
+
+	switch r.URL.Path {
+	case "/device":
+		respond(200, map[string]interface{}{
+			"device_code":      "dev123",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": "https://example.com/activate",
+			"expires_in":       600,
+			"interval":         0,
+		})
+	case "/token":
+		grant := r.PostForm.Get("grant_type")
+		s.grants = append(s.grants, grant)
+		switch grant {
+		case "client_credentials":
+			if id, secret, _ := r.BasicAuth(); id != "svc" || secret != "s3cret" {
+				respond(401, map[string]interface{}{"error": "invalid_client"})
+				return
+			}
+			respond(200, map[string]interface{}{
+				"access_token": "cc-token",
+				"token_type":   "bearer",
+				"expires_in":   300,
+			})
+		case "urn:ietf:params:oauth:grant-type:device_code":
+			s.polls++
+			if s.polls < 2 {
+				respond(400, map[string]interface{}{"error": "authorization_pending"})
+				return
+			}
+			respond(200, map[string]interface{}{
+				"access_token":  "device-token",
+				"token_type":    "Bearer",
+				"expires_in":    300,
+				"refresh_token": "refresh-1",
+			})
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "refresh-1" {
+				respond(400, map[string]interface{}{"error": "invalid_grant"})
+				return
+			}
+			respond(200, map[string]interface{}{
+				"access_token":  "refreshed-token",
+				"token_type":    "Bearer",
+				"expires_in":    300,
+				"refresh_token": "refresh-2",
+			})
+		default:
+			respond(400, map[string]interface{}{"error": "unsupported_grant_type"})
+		}
+	default:
+		w.WriteHeader(404)
+	}
+}
+
+func TestClientCredentials(t *testing.T) {
+	server := httptest.NewServer(&fakeAuthServer{})
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client()}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("oauth2", "api")})
+	got, err := inst.Update(context.Background(), testConfig(map[string]cty.Value{
+		"grant":         cty.StringVal("client_credentials"),
+		"token_url":     cty.StringVal(server.URL + "/token"),
+		"client_id":     cty.StringVal("svc"),
+		"client_secret": cty.StringVal("s3cret"),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := got.GetAttr("authorization_header"), cty.StringVal("Bearer cc-token"); !got.RawEquals(want) {
+		t.Errorf("wrong authorization_header %#v; want %#v", got, want)
+	}
+	if inst.(*instance).NextRefresh().IsZero() {
+		t.Errorf("no refresh scheduled for expiring token")
+	}
+}
+
+func TestDeviceWithCachedRefreshToken(t *testing.T) {
+	fake := &fakeAuthServer{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "envy-oauth2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	storePath := filepath.Join(dir, "secrets.json")
+	openStore := func() (*secrets.Store, error) {
+		return secrets.Open(storePath, secrets.PassphraseKey("test"))
+	}
+
+	var messages bytes.Buffer
+	typ := &Type{
+		HTTPClient: server.Client(),
+		Messages:   &messages,
+		openStore:  openStore,
+	}
+	config := testConfig(map[string]cty.Value{
+		"grant":                    cty.StringVal("device"),
+		"token_url":                cty.StringVal(server.URL + "/token"),
+		"device_authorization_url": cty.StringVal(server.URL + "/device"),
+		"client_id":                cty.StringVal("cli"),
+	})
+
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("oauth2", "api")})
+	got, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := got.GetAttr("access_token"), cty.StringVal("device-token"); !got.RawEquals(want) {
+		t.Errorf("wrong access_token %#v; want %#v", got, want)
+	}
+	if inst.(helpers.CacheReporter).UsedCache() {
+		t.Errorf("first run reports using the cache")
+	}
+	if !strings.Contains(messages.String(), "ABCD-EFGH") {
+		t.Errorf("user code not shown to user; messages were:\n%s", messages.String())
+	}
+
+	// A new instance, as in a subsequent run, should use the cached refresh
+	// token rather than prompting the user again.
+	inst = typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("oauth2", "api")})
+	got, err = inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error on second run: %s", err)
+	}
+	if !inst.(helpers.CacheReporter).UsedCache() {
+		t.Errorf("second run doesn't report using the cache")
+	}
+	if got, want := got.GetAttr("access_token"), cty.StringVal("refreshed-token"); !got.RawEquals(want) {
+		t.Errorf("wrong access_token on second run %#v; want %#v", got, want)
+	}
+	if got, want := fake.grants[len(fake.grants)-1], "refresh_token"; got != want {
+		t.Errorf("wrong grant on second run %q; want %q", got, want)
+	}
+
+	store, err := openStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := store.Get(cacheKey(addrs.MakeHelper("oauth2", "api"), &settings{
+		TokenURL: server.URL + "/token",
+		ClientID: "cli",
+	}))
+	if !strings.Contains(raw, "refresh-2") {
+		t.Errorf("rotated refresh token was not cached; store has %q", raw)
+	}
+}
+
+func TestDeviceCacheFailure(t *testing.T) {
+	server := httptest.NewServer(&fakeAuthServer{})
+	defer server.Close()
+
+	var messages bytes.Buffer
+	typ := &Type{
+		HTTPClient: server.Client(),
+		Messages:   &messages,
+		openStore: func() (*secrets.Store, error) {
+			return nil, fmt.Errorf("store is locked")
+		},
+	}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("oauth2", "api")})
+	got, err := inst.Update(context.Background(), testConfig(map[string]cty.Value{
+		"grant":                    cty.StringVal("device"),
+		"token_url":                cty.StringVal(server.URL + "/token"),
+		"device_authorization_url": cty.StringVal(server.URL + "/device"),
+		"client_id":                cty.StringVal("cli"),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := got.GetAttr("access_token"), cty.StringVal("device-token"); !got.RawEquals(want) {
+		t.Errorf("wrong access_token %#v; want %#v", got, want)
+	}
+	if !strings.Contains(messages.String(), "could not cache the refresh token") {
+		t.Errorf("no warning about the cache; messages were:\n%s", messages.String())
+	}
+}
+
+func TestDeviceSlowDownTimeout(t *testing.T) {
+	defer func(d time.Duration) { slowDownIncrease = d }(slowDownIncrease)
+	slowDownIncrease = 100 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/device" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "dev123",
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://example.com/activate",
+				"expires_in":       1,
+				"interval":         0,
+			})
+			return
+		}
+		// The server never stops asking the client to slow down, so only
+		// the deadline can end the polling.
+		w.WriteHeader(400)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "slow_down"})
+	}))
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client(), Messages: ioutil.Discard}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("oauth2", "api")})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := inst.Update(ctx, testConfig(map[string]cty.Value{
+		"grant":                    cty.StringVal("device"),
+		"token_url":                cty.StringVal(server.URL + "/token"),
+		"device_authorization_url": cty.StringVal(server.URL + "/device"),
+		"client_id":                cty.StringVal("cli"),
+	}))
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("wrong error %v; want a timeout", err)
+	}
+}
+
+func testConfig(attrs map[string]cty.Value) cty.Value {
+	all := map[string]cty.Value{
+		"grant":                    cty.NullVal(cty.String),
+		"token_url":                cty.NullVal(cty.String),
+		"device_authorization_url": cty.NullVal(cty.String),
+		"client_id":                cty.NullVal(cty.String),
+		"client_secret":            cty.NullVal(cty.String),
+		"scopes":                   cty.NullVal(cty.List(cty.String)),
+		"refresh_token":            cty.NullVal(cty.String),
+		"params":                   cty.NullVal(cty.Map(cty.String)),
+		"cache_refresh_token":      cty.NullVal(cty.Bool),
+	}
+	for k, v := range attrs {
+		all[k] = v
+	}
+	return cty.ObjectVal(all)
+}
+
+func TestCacheKey(t *testing.T) {
+	addr := addrs.MakeHelper("oauth2", "github")
+	client := &settings{TokenURL: "https://example.com/token", ClientID: "cli"}
+	key := cacheKey(addr, client)
+
+	if got := cacheKey(addrs.MakeHelper("oauth2", "github"), &settings{TokenURL: client.TokenURL, ClientID: client.ClientID}); got != key {
+		t.Errorf("same helper and client got different keys %q and %q", got, key)
+	}
+	others := map[string]string{
+		"module":    cacheKey(addr.InModule("x"), client),
+		"client":    cacheKey(addr, &settings{TokenURL: client.TokenURL, ClientID: "other"}),
+		"token URL": cacheKey(addr, &settings{TokenURL: "https://example.net/token", ClientID: client.ClientID}),
+	}
+	for desc, other := range others {
+		if other == key {
+			t.Errorf("different %s got the same key %q", desc, key)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Package prompt contains the "prompt" helper type, which asks the user to
+// enter a value interactively, such as a one-time MFA code.
+package prompt // import "envy.pw/cli/internal/helpers/prompt"
+
+import (
+	"context"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Func is the signature of a function that shows the given message to the
+// user and returns what they enter. If echo is false, the user's input must
+// not be shown on the terminal as they type it.
+type Func func(message string, echo bool) (string, error)
+
+// Type is the implementation of helpers.Type for the "prompt" helper type.
+type Type struct {
+	prompt Func
+}
+
+var _ helpers.Type = (*Type)(nil)
+
+// NewType returns a new prompt helper type that uses the given function to
+// prompt the user.
+func NewType(prompt Func) *Type {
+	return &Type{prompt: prompt}
+}
+
+// ConfigSpec implements helpers.Type.
+func (t *Type) ConfigSpec() hcldec.Spec {
+	return &hcldec.ObjectSpec{
+		"message": &hcldec.AttrSpec{
+			Name: "message",
+			Type: cty.String,
+		},
+		"echo": &hcldec.AttrSpec{
+			Name: "echo",
+			Type: cty.Bool,
+		},
+	}
+}
+
+// NewInstance implements helpers.Type.
+func (t *Type) NewInstance(meta helpers.InstanceMeta) helpers.Instance {
+	return &instance{typ: t, addr: meta.Addr}
+}
+
+type instance struct {
+	typ  *Type
+	addr addrs.Helper
+}
+
+func (i *instance) Update(ctx context.Context, config cty.Value) (cty.Value, error) {
+	message := "Value for " + i.addr.String() + ": "
+	if v := config.GetAttr("message"); !v.IsNull() {
+		message = v.AsString()
+	}
+	echo := false
+	if v := config.GetAttr("echo"); !v.IsNull() {
+		echo = v.True()
+	}
+
+	value, err := i.typ.prompt(message, echo)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return cty.ObjectVal(map[string]cty.Value{
+		"value": cty.StringVal(value),
+	}), nil
+}
+
+func (i *instance) Close() error {
+	return nil
+}
@@ -0,0 +1,92 @@
+// Package secret contains the "secret" helper type, which retrieves values
+// from the user's Envy-managed encrypted secret store.
+package secret // import "envy.pw/cli/internal/helpers/secret"
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/secrets"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// OpenFunc is the signature of a function that opens the secret store.
+//
+// It may prompt the user for a passphrase, and so is called at most once
+// per Type, and only if a secret helper is actually evaluated.
+type OpenFunc func() (*secrets.Store, error)
+
+// Type is the implementation of helpers.Type for the "secret" helper type.
+type Type struct {
+	open OpenFunc
+
+	once  sync.Once
+	store *secrets.Store
+	err   error
+}
+
+var _ helpers.Type = (*Type)(nil)
+
+// NewType returns a new secret helper type that will use the given function
+// to open the secret store.
+func NewType(open OpenFunc) *Type {
+	return &Type{open: open}
+}
+
+// ConfigSpec implements helpers.Type.
+func (t *Type) ConfigSpec() hcldec.Spec {
+	return &hcldec.ObjectSpec{
+		"key": &hcldec.AttrSpec{
+			Name: "key",
+			Type: cty.String,
+		},
+	}
+}
+
+// NewInstance implements helpers.Type.
+func (t *Type) NewInstance(meta helpers.InstanceMeta) helpers.Instance {
+	return &instance{typ: t, addr: meta.Addr}
+}
+
+func (t *Type) openStore() (*secrets.Store, error) {
+	t.once.Do(func() {
+		t.store, t.err = t.open()
+	})
+	return t.store, t.err
+}
+
+type instance struct {
+	typ  *Type
+	addr addrs.Helper
+}
+
+func (i *instance) Update(ctx context.Context, config cty.Value) (cty.Value, error) {
+	// The key defaults to the name of the helper, so that in the common case
+	// a helper "secret" "github" block can have an empty body.
+	key := config.GetAttr("key")
+	if key.IsNull() {
+		key = cty.StringVal(i.addr.Name)
+	}
+
+	store, err := i.typ.openStore()
+	if err != nil {
+		return cty.NilVal, fmt.Errorf("failed to open secret store: %s", err)
+	}
+	v, ok := store.Get(key.AsString())
+	if !ok {
+		return cty.NilVal, fmt.Errorf("there is no secret named %q in the secret store; use \"envy secret set %s\" to create it", key.AsString(), key.AsString())
+	}
+
+	return cty.ObjectVal(map[string]cty.Value{
+		"value": cty.StringVal(v),
+	}), nil
+}
+
+func (i *instance) Close() error {
+	return nil
+}
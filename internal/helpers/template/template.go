@@ -0,0 +1,204 @@
+// Package template contains the "template" helper type, which renders
+// a template into a private file so that programs which only read settings
+// from files can still use values produced by other helpers.
+package template // import "envy.pw/cli/internal/helpers/template"
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// Type is the implementation of helpers.Type for the "template" helper type.
+type Type struct{}
+
+var _ helpers.Type = (*Type)(nil)
+var _ helpers.NonsensitiveResults = (*Type)(nil)
+
+// NewType returns a new template helper type.
+func NewType() *Type {
+	return &Type{}
+}
+
+// ConfigSpec implements helpers.Type.
+func (t *Type) ConfigSpec() hcldec.Spec {
+	return &hcldec.ObjectSpec{
+		"content": &hcldec.AttrSpec{
+			Name: "content",
+			Type: cty.String,
+		},
+		"file": &hcldec.AttrSpec{
+			Name: "file",
+			Type: cty.String,
+		},
+		"vars": &hcldec.AttrSpec{
+			Name: "vars",
+			Type: cty.DynamicPseudoType,
+		},
+		"filename": &hcldec.AttrSpec{
+			Name: "filename",
+			Type: cty.String,
+		},
+		"mode": &hcldec.AttrSpec{
+			Name: "mode",
+			Type: cty.String,
+		},
+	}
+}
+
+// NonsensitiveAttrs implements helpers.NonsensitiveResults.
+func (t *Type) NonsensitiveAttrs() []string {
+	return []string{"path"}
+}
+
+// NewInstance implements helpers.Type.
+func (t *Type) NewInstance(meta helpers.InstanceMeta) helpers.Instance {
+	return &instance{meta: meta}
+}
+
+type instance struct {
+	meta helpers.InstanceMeta
+	path string
+}
+
+func (i *instance) Update(ctx context.Context, config cty.Value) (cty.Value, error) {
+	content := config.GetAttr("content")
+	file := config.GetAttr("file")
+	var rendered string
+	switch {
+	case !content.IsNull() && !file.IsNull():
+		return cty.NilVal, fmt.Errorf("only one of \"content\" and \"file\" may be set")
+	case !content.IsNull():
+		// An inline template is just a string expression, which HCL has
+		// therefore already rendered for us.
+		rendered = content.AsString()
+	case !file.IsNull():
+		var err error
+		filename := file.AsString()
+		if !filepath.IsAbs(filename) {
+			filename = filepath.Join(i.meta.ConfigDir, filename)
+		}
+		rendered, err = renderFile(filename, config.GetAttr("vars"), i.meta.Functions)
+		if err != nil {
+			return cty.NilVal, err
+		}
+	default:
+		return cty.NilVal, fmt.Errorf("either \"content\" or \"file\" must be set")
+	}
+
+	filename := i.meta.Addr.Name
+	if v := config.GetAttr("filename"); !v.IsNull() {
+		filename = v.AsString()
+		if filename == "" || strings.ContainsRune(filename, filepath.Separator) || filename == "." || filename == ".." {
+			return cty.NilVal, fmt.Errorf("filename must be a plain filename, without any directory")
+		}
+	}
+	mode := os.FileMode(0600)
+	if v := config.GetAttr("mode"); !v.IsNull() {
+		m, err := strconv.ParseUint(v.AsString(), 8, 32)
+		if err != nil || m > 0777 {
+			return cty.NilVal, fmt.Errorf("mode must be a permissions mode in octal notation, like \"0600\"")
+		}
+		mode = os.FileMode(m)
+	}
+
+	path := filepath.Join(i.meta.TempDir, filename)
+	if err := writeFile(path, []byte(rendered), mode); err != nil {
+		return cty.NilVal, fmt.Errorf("failed to write %s: %s", path, err)
+	}
+	if i.path != "" && i.path != path {
+		os.Remove(i.path)
+	}
+	i.path = path
+
+	return cty.ObjectVal(map[string]cty.Value{
+		"path":    cty.StringVal(path),
+		"content": cty.StringVal(rendered),
+	}), nil
+}
+
+func (i *instance) Close() error {
+	if i.path == "" {
+		return nil
+	}
+	err := os.Remove(i.path)
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	i.path = ""
+	return err
+}
+
+// renderFile renders the HCL template in the given file, making the
+// attributes of the given object available as variables along with the
+// given functions.
+func renderFile(filename string, vars cty.Value, functions map[string]function.Function) (string, error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %s", err)
+	}
+	expr, diags := hclsyntax.ParseTemplate(src, filename, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return "", fmt.Errorf("invalid template: %s", diags.Error())
+	}
+
+	ctx := &hcl.EvalContext{
+		Variables: map[string]cty.Value{},
+		Functions: functions,
+	}
+	if !vars.IsNull() {
+		ty := vars.Type()
+		if !ty.IsObjectType() && !ty.IsMapType() {
+			return "", fmt.Errorf("vars must be an object")
+		}
+		for it := vars.ElementIterator(); it.Next(); {
+			k, v := it.Element()
+			ctx.Variables[k.AsString()] = v
+		}
+	}
+
+	v, diags := expr.Value(ctx)
+	if diags.HasErrors() {
+		return "", fmt.Errorf("failed to render template: %s", diags.Error())
+	}
+	v, err = convert.Convert(v, cty.String)
+	if err != nil || v.IsNull() || !v.IsKnown() {
+		return "", fmt.Errorf("template did not produce a string")
+	}
+	return v.AsString(), nil
+}
+
+// writeFile replaces the content of the given file, by first writing it to
+// a temporary file and then renaming it into place, so that a program
+// reading the file will never see it partially written.
+func writeFile(path string, content []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Chmod(mode)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
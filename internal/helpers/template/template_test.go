@@ -0,0 +1,68 @@
+package template
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestTemplateFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-template")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tmplPath := filepath.Join(dir, "npmrc.tmpl")
+	err = ioutil.WriteFile(tmplPath, []byte("//registry.example.com/:_authToken=${token}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inst := NewType().NewInstance(helpers.InstanceMeta{
+		Addr:    addrs.MakeHelper("template", "npmrc"),
+		TempDir: dir,
+	})
+	got, err := inst.Update(context.Background(), cty.ObjectVal(map[string]cty.Value{
+		"content": cty.NullVal(cty.String),
+		"file":    cty.StringVal(tmplPath),
+		"vars": cty.ObjectVal(map[string]cty.Value{
+			"token": cty.StringVal("abc123"),
+		}),
+		"filename": cty.StringVal(".npmrc"),
+		"mode":     cty.NullVal(cty.String),
+	}))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	path := got.GetAttr("path").AsString()
+	if got, want := path, filepath.Join(dir, ".npmrc"); got != want {
+		t.Errorf("wrong path %q; want %q", got, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Mode().Perm(), os.FileMode(0600); got != want {
+		t.Errorf("wrong mode %s; want %s", got, want)
+	}
+	content, _ := ioutil.ReadFile(path)
+	if got, want := string(content), "//registry.example.com/:_authToken=abc123\n"; got != want {
+		t.Errorf("wrong content %q; want %q", got, want)
+	}
+
+	if err := inst.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("rendered file still exists after close")
+	}
+}
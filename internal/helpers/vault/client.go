@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// client is a minimal client for the subset of the Vault HTTP API that the
+// vault helper uses.
+type client struct {
+	addr      string
+	namespace string
+	token     string
+	http      *http.Client
+}
+
+// secret is the common response structure returned by most Vault API
+// endpoints.
+type secret struct {
+	LeaseID       string          `json:"lease_id"`
+	LeaseDuration int             `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *secretAuth     `json:"auth"`
+}
+
+type secretAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int    `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// do makes a request to the given API path, which is relative to the "/v1/"
+// prefix, and decodes the response.
+//
+// If body is non-nil then it is encoded as JSON and sent as the request
+// body. The result is nil if Vault responds with 204 No Content.
+func (c *client) do(ctx context.Context, method, path string, body interface{}) (*secret, error) {
+	var reqBody io.Reader
+	if body != nil {
+		src, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(src)
+	}
+
+	url := strings.TrimSuffix(c.addr, "/") + "/v1/" + strings.TrimPrefix(path, "/")
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if c.token != "" {
+		req.Header.Set("X-Vault-Token", c.token)
+	}
+	if c.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var errResp struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		if len(errResp.Errors) > 0 {
+			return nil, fmt.Errorf("%s %s: %s", method, path, strings.Join(errResp.Errors, "; "))
+		}
+		return nil, fmt.Errorf("%s %s: unexpected response %s", method, path, resp.Status)
+	}
+
+	var ret secret
+	if err := json.NewDecoder(resp.Body).Decode(&ret); err != nil {
+		return nil, fmt.Errorf("%s %s: invalid response: %s", method, path, err)
+	}
+	return &ret, nil
+}
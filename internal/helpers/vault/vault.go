@@ -0,0 +1,469 @@
+// Package vault contains the "vault" helper type, which obtains secrets from
+// HashiCorp Vault.
+package vault // import "envy.pw/cli/internal/helpers/vault"
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// Type is the implementation of helpers.Type for the "vault" helper type.
+type Type struct {
+	// HTTPClient is the client used to make requests to the Vault API.
+	HTTPClient *http.Client
+}
+
+var _ helpers.Type = (*Type)(nil)
+var _ helpers.NonsensitiveResults = (*Type)(nil)
+
+// NewType returns a new vault helper type that uses the default HTTP client.
+func NewType() *Type {
+	return &Type{
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// ConfigSpec implements helpers.Type.
+func (t *Type) ConfigSpec() hcldec.Spec {
+	return &hcldec.ObjectSpec{
+		"address": &hcldec.AttrSpec{
+			Name: "address",
+			Type: cty.String,
+		},
+		"namespace": &hcldec.AttrSpec{
+			Name: "namespace",
+			Type: cty.String,
+		},
+		"token": &hcldec.AttrSpec{
+			Name: "token",
+			Type: cty.String,
+		},
+		"path": &hcldec.AttrSpec{
+			Name:     "path",
+			Type:     cty.String,
+			Required: true,
+		},
+		"kv_version": &hcldec.AttrSpec{
+			Name: "kv_version",
+			Type: cty.Number,
+		},
+		"params": &hcldec.AttrSpec{
+			Name: "params",
+			Type: cty.DynamicPseudoType,
+		},
+		"approle": &hcldec.BlockSpec{
+			TypeName: "approle",
+			Nested: &hcldec.ObjectSpec{
+				"role_id": &hcldec.AttrSpec{
+					Name:     "role_id",
+					Type:     cty.String,
+					Required: true,
+				},
+				"secret_id": &hcldec.AttrSpec{
+					Name: "secret_id",
+					Type: cty.String,
+				},
+				"mount": &hcldec.AttrSpec{
+					Name: "mount",
+					Type: cty.String,
+				},
+			},
+		},
+		"jwt": &hcldec.BlockSpec{
+			TypeName: "jwt",
+			Nested: &hcldec.ObjectSpec{
+				"role": &hcldec.AttrSpec{
+					Name:     "role",
+					Type:     cty.String,
+					Required: true,
+				},
+				"jwt": &hcldec.AttrSpec{
+					Name:     "jwt",
+					Type:     cty.String,
+					Required: true,
+				},
+				"mount": &hcldec.AttrSpec{
+					Name: "mount",
+					Type: cty.String,
+				},
+			},
+		},
+	}
+}
+
+// NonsensitiveAttrs implements helpers.NonsensitiveResults.
+func (t *Type) NonsensitiveAttrs() []string {
+	return []string{"lease_id", "lease_duration", "renewable"}
+}
+
+// NewInstance implements helpers.Type.
+func (t *Type) NewInstance(meta helpers.InstanceMeta) helpers.Instance {
+	return &instance{typ: t, secretCleanup: -1}
+}
+
+type instance struct {
+	typ *Type
+
+	config cty.Value
+	client *client
+	result cty.Value
+
+	// loggedIn is true if the client's token was obtained by logging in,
+	// rather than given directly, in which case we're responsible for
+	// renewing and eventually revoking it.
+	loggedIn     bool
+	tokenLease   time.Duration
+	tokenRenewAt time.Time
+
+	secret        *secret
+	secretRenewAt time.Time
+
+	// cleanup are functions to call on Close, to revoke all of the leases
+	// and tokens we've obtained over the life of the instance.
+	cleanup []func(ctx context.Context) error
+
+	// secretCleanup is the index in cleanup of the function that revokes
+	// the current secret's lease, or -1 if it has none.
+	secretCleanup int
+}
+
+var _ helpers.Refresher = (*instance)(nil)
+
+func (i *instance) Update(ctx context.Context, config cty.Value) (cty.Value, error) {
+	if i.client == nil || !config.RawEquals(i.config) {
+		if err := i.login(ctx, config); err != nil {
+			return cty.NilVal, err
+		}
+		if err := i.read(ctx, config); err != nil {
+			return cty.NilVal, err
+		}
+		i.config = config
+		return i.result, nil
+	}
+
+	// If we get here then we're refreshing with an unchanged configuration,
+	// so we just need to deal with whatever has expired.
+	now := time.Now()
+	if i.loggedIn && !i.tokenRenewAt.IsZero() && !i.tokenRenewAt.After(now) {
+		if err := i.renewToken(ctx); err != nil {
+			if err := i.login(ctx, config); err != nil {
+				return cty.NilVal, err
+			}
+			// Vault revokes the leases obtained with the old token once it
+			// expires, so the secret must be read again with the new one.
+			// The old lease goes with the old token, which is why we don't
+			// revoke it ourselves.
+			i.forgetSecretLease()
+			if err := i.read(ctx, config); err != nil {
+				return cty.NilVal, err
+			}
+			return i.result, nil
+		}
+	}
+	if !i.secretRenewAt.IsZero() && !i.secretRenewAt.After(now) {
+		if err := i.renewSecret(ctx); err != nil {
+			if err := i.read(ctx, config); err != nil {
+				return cty.NilVal, err
+			}
+		}
+	}
+	return i.result, nil
+}
+
+func (i *instance) NextRefresh() time.Time {
+	next := i.secretRenewAt
+	if i.loggedIn && !i.tokenRenewAt.IsZero() && (next.IsZero() || i.tokenRenewAt.Before(next)) {
+		next = i.tokenRenewAt
+	}
+	return next
+}
+
+func (i *instance) Close() error {
+	// We use a fresh context here because the run's context may already
+	// have been cancelled, but we still want to clean up.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var errs []string
+	for j := len(i.cleanup) - 1; j >= 0; j-- {
+		if err := i.cleanup[j](ctx); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	i.cleanup = nil
+	i.secretCleanup = -1
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to revoke Vault leases: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// login prepares a client using the authentication settings in the given
+// configuration, logging in to Vault if necessary.
+func (i *instance) login(ctx context.Context, config cty.Value) error {
+	c := &client{
+		addr: os.Getenv("VAULT_ADDR"),
+		http: i.typ.HTTPClient,
+	}
+	if v := config.GetAttr("address"); !v.IsNull() {
+		c.addr = v.AsString()
+	}
+	if v := config.GetAttr("namespace"); !v.IsNull() {
+		c.namespace = v.AsString()
+	} else {
+		c.namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if c.addr == "" {
+		return fmt.Errorf("no Vault server address is set; set the \"address\" argument or the VAULT_ADDR environment variable")
+	}
+
+	approle := config.GetAttr("approle")
+	jwt := config.GetAttr("jwt")
+	var loginPath string
+	var loginBody map[string]string
+	switch {
+	case !approle.IsNull() && !jwt.IsNull():
+		return fmt.Errorf("only one of the \"approle\" and \"jwt\" authentication blocks may be used")
+	case !approle.IsNull():
+		loginPath = "auth/" + stringAttrDefault(approle, "mount", "approle") + "/login"
+		loginBody = map[string]string{
+			"role_id": approle.GetAttr("role_id").AsString(),
+		}
+		if v := approle.GetAttr("secret_id"); !v.IsNull() {
+			loginBody["secret_id"] = v.AsString()
+		}
+	case !jwt.IsNull():
+		loginPath = "auth/" + stringAttrDefault(jwt, "mount", "jwt") + "/login"
+		loginBody = map[string]string{
+			"role": jwt.GetAttr("role").AsString(),
+			"jwt":  jwt.GetAttr("jwt").AsString(),
+		}
+	default:
+		token, err := defaultToken(config)
+		if err != nil {
+			return err
+		}
+		c.token = token
+		i.client = c
+		i.loggedIn = false
+		return nil
+	}
+
+	resp, err := c.do(ctx, "POST", loginPath, loginBody)
+	if err != nil {
+		return fmt.Errorf("failed to log in to Vault: %s", err)
+	}
+	if resp == nil || resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("failed to log in to Vault: response did not include a token")
+	}
+	c.token = resp.Auth.ClientToken
+	i.client = c
+	i.loggedIn = true
+	i.tokenLease = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	i.tokenRenewAt = renewAt(resp.Auth.LeaseDuration)
+	i.cleanup = append(i.cleanup, func(ctx context.Context) error {
+		_, err := c.do(ctx, "POST", "auth/token/revoke-self", nil)
+		return err
+	})
+	return nil
+}
+
+// renewToken extends the lease of a token we obtained by logging in.
+func (i *instance) renewToken(ctx context.Context) error {
+	resp, err := i.client.do(ctx, "POST", "auth/token/renew-self", nil)
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.Auth == nil {
+		return fmt.Errorf("token renewal response did not include auth information")
+	}
+	if time.Duration(resp.Auth.LeaseDuration)*time.Second < i.tokenLease/3 {
+		// The token is reaching its maximum TTL, so renewing it won't buy
+		// us much more time. We'll log in again instead.
+		return fmt.Errorf("token is approaching its maximum TTL")
+	}
+	i.tokenRenewAt = renewAt(resp.Auth.LeaseDuration)
+	return nil
+}
+
+// read reads the secret at the configured path and updates the instance's
+// result to reflect it.
+func (i *instance) read(ctx context.Context, config cty.Value) error {
+	path := config.GetAttr("path").AsString()
+	kv2 := false
+	if v := config.GetAttr("kv_version"); !v.IsNull() {
+		switch v.AsBigFloat().String() {
+		case "1":
+		case "2":
+			kv2 = true
+			path = kvV2DataPath(path)
+		default:
+			return fmt.Errorf("kv_version must be either 1 or 2")
+		}
+	}
+
+	var resp *secret
+	var err error
+	if params := config.GetAttr("params"); !params.IsNull() {
+		var body json.RawMessage
+		body, err = ctyjson.Marshal(params, params.Type())
+		if err != nil {
+			return fmt.Errorf("invalid params: %s", err)
+		}
+		resp, err = i.client.do(ctx, "POST", path, body)
+	} else {
+		resp, err = i.client.do(ctx, "GET", path, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read from Vault: %s", err)
+	}
+	if resp == nil {
+		return fmt.Errorf("failed to read from Vault: no secret at %s", path)
+	}
+
+	data := resp.Data
+	if kv2 {
+		var wrapped struct {
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return fmt.Errorf("invalid KV version 2 response: %s", err)
+		}
+		data = wrapped.Data
+	}
+	dataVal, err := jsonValue(data)
+	if err != nil {
+		return fmt.Errorf("invalid secret data in response: %s", err)
+	}
+
+	i.secret = resp
+	i.secretRenewAt = renewAt(resp.LeaseDuration)
+	i.secretCleanup = -1
+	if resp.LeaseID != "" {
+		c := i.client
+		leaseID := resp.LeaseID
+		i.secretCleanup = len(i.cleanup)
+		i.cleanup = append(i.cleanup, func(ctx context.Context) error {
+			_, err := c.do(ctx, "PUT", "sys/leases/revoke", map[string]string{
+				"lease_id": leaseID,
+			})
+			return err
+		})
+	}
+	i.result = cty.ObjectVal(map[string]cty.Value{
+		"data":           dataVal,
+		"lease_id":       cty.StringVal(resp.LeaseID),
+		"lease_duration": cty.NumberIntVal(int64(resp.LeaseDuration)),
+		"renewable":      cty.BoolVal(resp.Renewable),
+	})
+	return nil
+}
+
+// renewSecret extends the lease of the secret we most recently read, if
+// possible. If it returns an error then the caller should read the secret
+// again to get a new lease.
+func (i *instance) renewSecret(ctx context.Context) error {
+	if i.secret.LeaseID == "" || !i.secret.Renewable {
+		return fmt.Errorf("secret is not renewable")
+	}
+	resp, err := i.client.do(ctx, "PUT", "sys/leases/renew", map[string]interface{}{
+		"lease_id":  i.secret.LeaseID,
+		"increment": i.secret.LeaseDuration,
+	})
+	if err != nil {
+		return err
+	}
+	if resp == nil || resp.LeaseDuration < i.secret.LeaseDuration/3 {
+		// The lease is reaching its maximum TTL, so we need a new secret.
+		return fmt.Errorf("lease is approaching its maximum TTL")
+	}
+	i.secretRenewAt = renewAt(resp.LeaseDuration)
+
+	attrs := i.result.AsValueMap()
+	attrs["lease_duration"] = cty.NumberIntVal(int64(resp.LeaseDuration))
+	i.result = cty.ObjectVal(attrs)
+	return nil
+}
+
+// forgetSecretLease removes the revocation of the current secret's lease
+// from the functions to call on Close, for when Vault will revoke the lease
+// without our help.
+func (i *instance) forgetSecretLease() {
+	if i.secretCleanup < 0 {
+		return
+	}
+	i.cleanup = append(i.cleanup[:i.secretCleanup], i.cleanup[i.secretCleanup+1:]...)
+	i.secretCleanup = -1
+}
+
+// defaultToken decides which token to use when no login method is
+// configured, following the same conventions as the Vault CLI.
+func defaultToken(config cty.Value) (string, error) {
+	if v := config.GetAttr("token"); !v.IsNull() {
+		return v.AsString(), nil
+	}
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		src, err := ioutil.ReadFile(filepath.Join(home, ".vault-token"))
+		if err == nil {
+			return strings.TrimSpace(string(src)), nil
+		}
+	}
+	return "", fmt.Errorf("no Vault token is available; set the \"token\" argument, use an authentication block, set VAULT_TOKEN, or run \"vault login\"")
+}
+
+// kvV2DataPath converts a path like "secret/foo" into the corresponding
+// KV version 2 data path "secret/data/foo", assuming that the first path
+// segment is the mount path.
+func kvV2DataPath(path string) string {
+	path = strings.Trim(path, "/")
+	slash := strings.Index(path, "/")
+	if slash < 0 {
+		return path + "/data"
+	}
+	return path[:slash] + "/data" + path[slash:]
+}
+
+// renewAt returns the time at which we should renew or replace a lease of
+// the given duration in seconds, leaving a safety margin before it actually
+// expires.
+func renewAt(leaseDuration int) time.Time {
+	if leaseDuration <= 0 {
+		return time.Time{}
+	}
+	d := time.Duration(leaseDuration) * time.Second
+	return time.Now().Add(d * 2 / 3)
+}
+
+func jsonValue(raw json.RawMessage) (cty.Value, error) {
+	if len(raw) == 0 {
+		return cty.EmptyObjectVal, nil
+	}
+	ty, err := ctyjson.ImpliedType(raw)
+	if err != nil {
+		return cty.NilVal, err
+	}
+	return ctyjson.Unmarshal(raw, ty)
+}
+
+func stringAttrDefault(obj cty.Value, name, def string) string {
+	if v := obj.GetAttr(name); !v.IsNull() {
+		return v.AsString()
+	}
+	return def
+}
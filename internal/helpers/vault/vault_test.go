@@ -0,0 +1,247 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/helpers"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// fakeVault is an in-process stand-in for the parts of the Vault API that
+// the helper uses.
+type fakeVault struct {
+	mu       sync.Mutex
+	requests []string
+	revoked  []string
+
+	// logins counts the AppRole logins, each of which issues a new token.
+	logins int
+}
+
+func (v *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.requests = append(v.requests, r.Method+" "+r.URL.Path)
+
+	var body map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&body)
+
+	switch r.URL.Path {
+	case "/v1/auth/approle/login":
+		if body["role_id"] != "my-role" || body["secret_id"] != "my-secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["invalid role or secret ID"]}`))
+			return
+		}
+		v.logins++
+		token := "approle-token"
+		if v.logins > 1 {
+			token = fmt.Sprintf("approle-token-%d", v.logins)
+		}
+		fmt.Fprintf(w, `{"auth":{"client_token":%q,"lease_duration":3600,"renewable":true}}`, token)
+	case "/v1/auth/token/renew-self":
+		// The token has nearly reached its maximum TTL.
+		w.Write([]byte(`{"auth":{"client_token":"approle-token","lease_duration":60,"renewable":true}}`))
+	case "/v1/database/creds/app":
+		token := r.Header.Get("X-Vault-Token")
+		if !strings.HasPrefix(token, "approle-token") {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		fmt.Fprintf(w, `{"lease_id":"database/creds/app/%s","lease_duration":600,"renewable":true,"data":{"username":%q}}`, token, token)
+	case "/v1/secret/data/app":
+		if r.Header.Get("X-Vault-Token") != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"password":"hunter2"},"metadata":{"version":3}}}`))
+	case "/v1/database/creds/readonly":
+		if r.Header.Get("X-Vault-Token") != "static-token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		w.Write([]byte(`{"lease_id":"database/creds/readonly/abc","lease_duration":60,"renewable":true,"data":{"username":"u1","password":"p1"}}`))
+	case "/v1/sys/leases/renew":
+		w.Write([]byte(`{"lease_id":"database/creds/readonly/abc","lease_duration":50,"renewable":true}`))
+	case "/v1/sys/leases/revoke":
+		v.revoked = append(v.revoked, body["lease_id"].(string))
+		w.WriteHeader(http.StatusNoContent)
+	case "/v1/auth/token/revoke-self":
+		v.revoked = append(v.revoked, r.Header.Get("X-Vault-Token"))
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"errors":[]}`))
+	}
+}
+
+func TestVaultKV2WithAppRole(t *testing.T) {
+	fake := &fakeVault{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client()}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("vault", "app")})
+	config := testConfig(map[string]cty.Value{
+		"address":    cty.StringVal(server.URL),
+		"path":       cty.StringVal("secret/app"),
+		"kv_version": cty.NumberIntVal(2),
+		"approle": cty.ObjectVal(map[string]cty.Value{
+			"role_id":   cty.StringVal("my-role"),
+			"secret_id": cty.StringVal("my-secret"),
+			"mount":     cty.NullVal(cty.String),
+		}),
+	})
+
+	got, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := got.GetAttr("data").GetAttr("password"), cty.StringVal("hunter2"); !got.RawEquals(want) {
+		t.Errorf("wrong password %#v; want %#v", got, want)
+	}
+
+	if err := inst.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+	if got, want := fake.revoked, []string{"approle-token"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("wrong revocations %#v; want %#v", got, want)
+	}
+}
+
+func TestVaultDynamicSecretLease(t *testing.T) {
+	fake := &fakeVault{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client()}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("vault", "db")}).(*instance)
+	config := testConfig(map[string]cty.Value{
+		"address": cty.StringVal(server.URL),
+		"token":   cty.StringVal("static-token"),
+		"path":    cty.StringVal("database/creds/readonly"),
+	})
+
+	got, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := got.GetAttr("lease_id"), cty.StringVal("database/creds/readonly/abc"); !got.RawEquals(want) {
+		t.Errorf("wrong lease_id %#v; want %#v", got, want)
+	}
+	if inst.NextRefresh().IsZero() {
+		t.Errorf("no refresh scheduled for leased secret")
+	}
+
+	// Simulate the refresh time arriving, which should renew the lease
+	// rather than reading a new secret.
+	inst.secretRenewAt = time.Now().Add(-time.Second)
+	again, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error refreshing: %s", err)
+	}
+	if got, want := again.GetAttr("data"), got.GetAttr("data"); !got.RawEquals(want) {
+		t.Errorf("data changed after renewal\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if got, want := again.GetAttr("lease_duration"), cty.NumberIntVal(50); !got.RawEquals(want) {
+		t.Errorf("wrong lease_duration after renewal %#v; want %#v", got, want)
+	}
+	if got, want := fake.requests[len(fake.requests)-1], "PUT /v1/sys/leases/renew"; got != want {
+		t.Errorf("wrong last request %q; want %q", got, want)
+	}
+
+	if err := inst.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+	if got, want := fake.revoked, []string{"database/creds/readonly/abc"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("wrong revocations %#v; want %#v", got, want)
+	}
+}
+
+func TestVaultReloginRereadsSecret(t *testing.T) {
+	fake := &fakeVault{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	typ := &Type{HTTPClient: server.Client()}
+	inst := typ.NewInstance(helpers.InstanceMeta{Addr: addrs.MakeHelper("vault", "db")}).(*instance)
+	config := testConfig(map[string]cty.Value{
+		"address": cty.StringVal(server.URL),
+		"path":    cty.StringVal("database/creds/app"),
+		"approle": cty.ObjectVal(map[string]cty.Value{
+			"role_id":   cty.StringVal("my-role"),
+			"secret_id": cty.StringVal("my-secret"),
+			"mount":     cty.NullVal(cty.String),
+		}),
+	})
+
+	got, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := got.GetAttr("lease_id"), cty.StringVal("database/creds/app/approle-token"); !got.RawEquals(want) {
+		t.Errorf("wrong lease_id %#v; want %#v", got, want)
+	}
+
+	// Simulate the token's refresh time arriving. The fake refuses to
+	// extend the token much further, so the helper must log in again and
+	// then replace the secret, whose lease Vault will revoke along with
+	// the old token.
+	inst.tokenRenewAt = time.Now().Add(-time.Second)
+	again, err := inst.Update(context.Background(), config)
+	if err != nil {
+		t.Fatalf("unexpected error refreshing: %s", err)
+	}
+	if got, want := again.GetAttr("lease_id"), cty.StringVal("database/creds/app/approle-token-2"); !got.RawEquals(want) {
+		t.Errorf("wrong lease_id after login %#v; want %#v", got, want)
+	}
+	if got, want := again.GetAttr("data").GetAttr("username"), cty.StringVal("approle-token-2"); !got.RawEquals(want) {
+		t.Errorf("wrong username after login %#v; want %#v", got, want)
+	}
+
+	if err := inst.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %s", err)
+	}
+	want := []string{"database/creds/app/approle-token-2", "approle-token-2", "approle-token"}
+	if got := fake.revoked; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("wrong revocations %#v; want %#v", got, want)
+	}
+}
+
+func testConfig(attrs map[string]cty.Value) cty.Value {
+	all := map[string]cty.Value{
+		"address":    cty.NullVal(cty.String),
+		"namespace":  cty.NullVal(cty.String),
+		"token":      cty.NullVal(cty.String),
+		"path":       cty.NullVal(cty.String),
+		"kv_version": cty.NullVal(cty.Number),
+		"params":     cty.NullVal(cty.DynamicPseudoType),
+		"approle": cty.NullVal(cty.Object(map[string]cty.Type{
+			"role_id":   cty.String,
+			"secret_id": cty.String,
+			"mount":     cty.String,
+		})),
+		"jwt": cty.NullVal(cty.Object(map[string]cty.Type{
+			"role":  cty.String,
+			"jwt":   cty.String,
+			"mount": cty.String,
+		})),
+	}
+	for k, v := range attrs {
+		all[k] = v
+	}
+	return cty.ObjectVal(all)
+}
@@ -0,0 +1,156 @@
+// Package logging contains the leveled, structured logger that envy uses
+// to explain what it is doing, for the benefit of those debugging envy or
+// their configurations.
+//
+// Logging is disabled by default. The log messages are intended for
+// developers rather than end users, so the main user-facing output should
+// always be via diagnostics instead.
+//
+// Callers must never include sensitive values in log messages or their
+// fields, since the logs might be shared in bug reports.
+package logging // import "envy.pw/cli/internal/logging"
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level represents the verbosity of a log message, or the minimum verbosity
+// of the messages to include in the log.
+type Level int
+
+const (
+	// LevelTrace is the most verbose level, for fine-grained details such as the
+	// individual nodes of a graph.
+	LevelTrace Level = iota
+
+	// LevelDebug is for details about each step of an operation.
+	LevelDebug
+
+	// LevelInfo is for significant events during an operation, such as the
+	// launching of a child process.
+	LevelInfo
+
+	// LevelOff is a level that disables logging altogether.
+	LevelOff
+)
+
+var levelNames = map[Level]string{
+	LevelTrace: "TRACE",
+	LevelDebug: "DEBUG",
+	LevelInfo:  "INFO",
+	LevelOff:   "OFF",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("Level(%d)", int(l))
+}
+
+// ParseLevel parses the name of a level, as given in the ENVY_LOG
+// environment variable or the --log-level option. Names are not
+// case-sensitive.
+//
+// The empty string selects LevelOff.
+func ParseLevel(s string) (Level, error) {
+	if s == "" {
+		return LevelOff, nil
+	}
+	for l, name := range levelNames {
+		if strings.EqualFold(s, name) {
+			return l, nil
+		}
+	}
+	return LevelOff, fmt.Errorf("invalid log level %q; must be trace, debug, info or off", s)
+}
+
+var (
+	level  = LevelOff
+	output io.Writer
+	lock   sync.Mutex
+)
+
+// Configure sets the minimum level of messages to write and the writer to
+// write them to.
+func Configure(l Level, w io.Writer) {
+	lock.Lock()
+	level = l
+	output = w
+	lock.Unlock()
+}
+
+// Enabled returns true if messages at the given level will be written, so
+// callers can avoid expensive preparation of fields that won't be used.
+func Enabled(l Level) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return output != nil && l >= level && l < LevelOff
+}
+
+// Log writes a message at the given level, if that level is enabled.
+//
+// The message is followed by any number of fields given as alternating
+// keys and values. Keys must be strings, while values are formatted with
+// fmt's %v verb.
+func Log(l Level, msg string, fields ...interface{}) {
+	if !Enabled(l) {
+		return
+	}
+
+	var buf strings.Builder
+	buf.WriteString(time.Now().UTC().Format("2006-01-02T15:04:05.000Z"))
+	buf.WriteString(" [")
+	buf.WriteString(l.String())
+	buf.WriteString("] ")
+	buf.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		buf.WriteByte(' ')
+		buf.WriteString(fmt.Sprint(fields[i]))
+		buf.WriteByte('=')
+		if i+1 < len(fields) {
+			buf.WriteString(formatValue(fields[i+1]))
+		}
+	}
+	buf.WriteByte('\n')
+
+	lock.Lock()
+	io.WriteString(output, buf.String())
+	lock.Unlock()
+}
+
+// Trace writes a message at LevelTrace.
+func Trace(msg string, fields ...interface{}) {
+	Log(LevelTrace, msg, fields...)
+}
+
+// Debug writes a message at LevelDebug.
+func Debug(msg string, fields ...interface{}) {
+	Log(LevelDebug, msg, fields...)
+}
+
+// Info writes a message at LevelInfo.
+func Info(msg string, fields ...interface{}) {
+	Log(LevelInfo, msg, fields...)
+}
+
+func formatValue(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case time.Duration:
+		s = v.Round(time.Microsecond).String()
+	case time.Time:
+		s = v.UTC().Format(time.RFC3339)
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	var buf strings.Builder
+	Configure(LevelDebug, &buf)
+	defer Configure(LevelOff, nil)
+
+	Trace("not included")
+	Debug("evaluating node", "node", "helper.vault.db", "detail", "has spaces")
+	Info("child exited", "pid", 1234, "status", 0)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("wrong number of lines %d; want %d\n%s", got, want, buf.String())
+	}
+	wantSuffixes := []string{
+		` [DEBUG] evaluating node node=helper.vault.db detail="has spaces"`,
+		` [INFO] child exited pid=1234 status=0`,
+	}
+	for i, want := range wantSuffixes {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("wrong line %d\ngot:  %s\nwant: ...%s", i, lines[i], want)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]Level{
+		"":      LevelOff,
+		"trace": LevelTrace,
+		"DEBUG": LevelDebug,
+		"Info":  LevelInfo,
+		"off":   LevelOff,
+	}
+	for input, want := range tests {
+		got, err := ParseLevel(input)
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", input, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("wrong result for %q: got %s, want %s", input, got, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Errorf("no error for invalid level")
+	}
+}
@@ -0,0 +1,45 @@
+package redact
+
+import (
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// Diagnostics returns a copy of the given diagnostics where the messages
+// and any expression evaluation contexts are redacted.
+func (r *Redactor) Diagnostics(diags nvdiags.Diagnostics) nvdiags.Diagnostics {
+	if r == nil || len(r.secrets) == 0 || len(diags) == 0 {
+		return diags
+	}
+	ret := make(nvdiags.Diagnostics, len(diags))
+	for i, diag := range diags {
+		ret[i] = redactedDiagnostic{
+			Diagnostic: diag,
+			r:          r,
+		}
+	}
+	return ret
+}
+
+type redactedDiagnostic struct {
+	nvdiags.Diagnostic
+	r *Redactor
+}
+
+func (diag redactedDiagnostic) Messages() nvdiags.Messages {
+	msgs := diag.Diagnostic.Messages()
+	return nvdiags.Messages{
+		Summary: diag.r.String(msgs.Summary),
+		Detail:  diag.r.String(msgs.Detail),
+	}
+}
+
+func (diag redactedDiagnostic) ExprContext() *nvdiags.ExprContext {
+	ectx := diag.Diagnostic.ExprContext()
+	if ectx == nil {
+		return nil
+	}
+	return &nvdiags.ExprContext{
+		Expression:  ectx.Expression,
+		EvalContext: diag.r.EvalContext(ectx.EvalContext),
+	}
+}
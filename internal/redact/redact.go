@@ -0,0 +1,140 @@
+// Package redact contains the mechanism for hiding sensitive values in any
+// output that envy produces, such as diagnostics and logs.
+package redact // import "envy.pw/cli/internal/redact"
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Placeholder is the text shown in place of a sensitive value.
+const Placeholder = "(sensitive value)"
+
+// minSecretLen is the length below which a string is not considered a
+// secret for the purpose of redacting text, because shorter strings are
+// likely to appear coincidentally in unrelated places, such as "true" in
+// a message.
+const minSecretLen = 4
+
+// Redactor hides a particular set of sensitive strings wherever they
+// appear.
+//
+// The zero value of Redactor is a valid Redactor that hides nothing, and a
+// nil *Redactor behaves in the same way.
+type Redactor struct {
+	secrets []string
+}
+
+// NewRedactor returns a redactor that hides all of the strings found
+// anywhere within the given values.
+func NewRedactor(vals ...cty.Value) *Redactor {
+	seen := make(map[string]struct{})
+	for _, v := range vals {
+		cty.Walk(v, func(path cty.Path, v cty.Value) (bool, error) {
+			if !v.IsKnown() || v.IsNull() {
+				return false, nil
+			}
+			if v.Type() == cty.String {
+				if s := v.AsString(); len(s) >= minSecretLen {
+					seen[s] = struct{}{}
+				}
+			}
+			return true, nil
+		})
+	}
+
+	r := &Redactor{
+		secrets: make([]string, 0, len(seen)),
+	}
+	for s := range seen {
+		r.secrets = append(r.secrets, s)
+	}
+	// We replace the longest strings first so that a secret containing
+	// another secret is hidden as a whole.
+	sort.Slice(r.secrets, func(i, j int) bool {
+		if len(r.secrets[i]) != len(r.secrets[j]) {
+			return len(r.secrets[i]) > len(r.secrets[j])
+		}
+		return r.secrets[i] < r.secrets[j]
+	})
+	return r
+}
+
+// Except returns a copy of the redactor that doesn't hide any of the strings
+// found within the given values, which are known not to be sensitive even
+// though they may also appear within sensitive values.
+func (r *Redactor) Except(vals ...cty.Value) *Redactor {
+	if r == nil || len(vals) == 0 {
+		return r
+	}
+	exempt := make(map[string]struct{})
+	for _, v := range vals {
+		cty.Walk(v, func(path cty.Path, v cty.Value) (bool, error) {
+			if !v.IsKnown() || v.IsNull() {
+				return false, nil
+			}
+			if v.Type() == cty.String {
+				exempt[v.AsString()] = struct{}{}
+			}
+			return true, nil
+		})
+	}
+
+	ret := &Redactor{
+		secrets: make([]string, 0, len(r.secrets)),
+	}
+	for _, s := range r.secrets {
+		if _, ok := exempt[s]; !ok {
+			ret.secrets = append(ret.secrets, s)
+		}
+	}
+	return ret
+}
+
+// String returns the given string with any sensitive strings within it
+// replaced by Placeholder.
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, secret := range r.secrets {
+		if strings.Contains(s, secret) {
+			s = strings.Replace(s, secret, Placeholder, -1)
+		}
+	}
+	return s
+}
+
+// Value returns a copy of the given value where any strings that include
+// sensitive strings are redacted using String.
+func (r *Redactor) Value(v cty.Value) cty.Value {
+	if r == nil || len(r.secrets) == 0 {
+		return v
+	}
+	ret, _ := cty.Transform(v, func(path cty.Path, v cty.Value) (cty.Value, error) {
+		if v.Type() != cty.String || !v.IsKnown() || v.IsNull() {
+			return v, nil
+		}
+		return cty.StringVal(r.String(v.AsString())), nil
+	})
+	return ret
+}
+
+// EvalContext returns a copy of the given evaluation context whose
+// variables are redacted using Value.
+func (r *Redactor) EvalContext(ctx *hcl.EvalContext) *hcl.EvalContext {
+	if ctx == nil || r == nil || len(r.secrets) == 0 {
+		return ctx
+	}
+	ret := &hcl.EvalContext{
+		Variables: make(map[string]cty.Value, len(ctx.Variables)),
+		Functions: ctx.Functions,
+	}
+	for name, v := range ctx.Variables {
+		ret.Variables[name] = r.Value(v)
+	}
+	return ret
+}
@@ -0,0 +1,65 @@
+package redact
+
+import (
+	"testing"
+
+	"envy.pw/cli/internal/nvdiags"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor(cty.ObjectVal(map[string]cty.Value{
+		"token":  cty.StringVal("s3cr3t-token"),
+		"prefix": cty.StringVal("s3cr3t"),
+		"short":  cty.StringVal("abc"),
+		"number": cty.NumberIntVal(1234),
+	}))
+
+	if got, want := r.String("Authorization: Bearer s3cr3t-token"), "Authorization: Bearer "+Placeholder; got != want {
+		t.Errorf("wrong string result\ngot:  %q\nwant: %q", got, want)
+	}
+	if got, want := r.String("s3cr3t abc 1234"), Placeholder+" abc 1234"; got != want {
+		t.Errorf("wrong string result\ngot:  %q\nwant: %q", got, want)
+	}
+
+	got := r.Value(cty.ObjectVal(map[string]cty.Value{
+		"header": cty.StringVal("Bearer s3cr3t-token"),
+		"count":  cty.NumberIntVal(2),
+	}))
+	want := cty.ObjectVal(map[string]cty.Value{
+		"header": cty.StringVal("Bearer " + Placeholder),
+		"count":  cty.NumberIntVal(2),
+	})
+	if !got.RawEquals(want) {
+		t.Errorf("wrong value result\ngot:  %#v\nwant: %#v", got, want)
+	}
+
+	diags := r.Diagnostics(nvdiags.Diagnostics{
+		nvdiags.Sourceless(nvdiags.Error, "Helper failed", "The server rejected s3cr3t-token."),
+	})
+	if got, want := diags[0].Messages().Detail, "The server rejected "+Placeholder+"."; got != want {
+		t.Errorf("wrong diagnostic detail\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestRedactorExcept(t *testing.T) {
+	r := NewRedactor(cty.ObjectVal(map[string]cty.Value{
+		"token": cty.StringVal("s3cr3t-token"),
+		"env": cty.MapVal(map[string]cty.Value{
+			"TOKEN":  cty.StringVal("s3cr3t-token"),
+			"REGION": cty.StringVal("eu-west-1"),
+		}),
+	})).Except(cty.StringVal("eu-west-1"))
+
+	if got, want := r.String("s3cr3t-token in eu-west-1"), Placeholder+" in eu-west-1"; got != want {
+		t.Errorf("wrong string result\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestRedactorNil(t *testing.T) {
+	var r *Redactor
+	if got, want := r.String("s3cr3t"), "s3cr3t"; got != want {
+		t.Errorf("wrong result %q; want %q", got, want)
+	}
+}
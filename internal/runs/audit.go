@@ -0,0 +1,101 @@
+package runs
+
+import (
+	"fmt"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/audit"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// SetAuditLog makes the runner record each command it runs in the given
+// audit log. If it is never called then the runner keeps no audit log.
+func (r *Runner) SetAuditLog(log *audit.Log) {
+	r.auditLog = log
+}
+
+// recordRun appends a record of a finished command run to the runner's
+// audit log, if it has one.
+//
+// The run is nil if it failed before any helpers were started. The action
+// is the audit.Record.Action describing how the command was used, and the
+// pids are those of the child processes launched for the run.
+func (r *Runner) recordRun(call *CommandCall, run *commandRun, action string, start time.Time, pids []int, status int) nvdiags.Diagnostics {
+	if pids == nil {
+		pids = []int{}
+	}
+	rec := &audit.Record{
+		Start:      start.UTC(),
+		End:        time.Now().UTC(),
+		Command:    call.Addr.String(),
+		Action:     action,
+		ArgsHash:   audit.HashArgs(call.Args),
+		WorkingDir: call.WorkingDir,
+		Profile:    call.Profile,
+		PIDs:       pids,
+		Status:     status,
+	}
+	var graph *graphRun
+	if run != nil {
+		graph = run.graphRun
+	}
+	return r.appendAuditRecord(rec, graph)
+}
+
+// recordAutoEnv appends a record of an evaluation of an auto_env block by the
+// shell hook to the runner's audit log, if it has one, since the hook
+// exports the credentials of the helpers it evaluates into the user's shell.
+//
+// The run is nil if it failed before any helpers were started.
+func (r *Runner) recordAutoEnv(call *AutoEnvCall, run *graphRun, start time.Time, status int) nvdiags.Diagnostics {
+	rec := &audit.Record{
+		Start:      start.UTC(),
+		End:        time.Now().UTC(),
+		Command:    addrs.AutoEnv{}.String(),
+		Action:     audit.ActionHook,
+		ArgsHash:   audit.HashArgs(nil),
+		WorkingDir: call.WorkingDir,
+		Profile:    call.Profile,
+		PIDs:       []int{},
+		Status:     status,
+	}
+	return r.appendAuditRecord(rec, run)
+}
+
+// appendAuditRecord adds the helpers evaluated during the given run, which
+// may be nil, to the given record and then appends it to the runner's audit
+// log, if it has one.
+func (r *Runner) appendAuditRecord(rec *audit.Record, run *graphRun) nvdiags.Diagnostics {
+	var diags nvdiags.Diagnostics
+	if r.auditLog == nil {
+		return diags
+	}
+
+	rec.Helpers = []audit.Helper{}
+	if run != nil {
+		for _, n := range run.helpers {
+			if n.instance == nil {
+				continue // never evaluated, because an earlier helper failed
+			}
+			h := audit.Helper{Addr: n.Addr.String()}
+			if cr, ok := n.instance.(helpers.CacheReporter); ok {
+				h.Cache = audit.CacheMiss
+				if cr.UsedCache() {
+					h.Cache = audit.CacheHit
+				}
+			}
+			rec.Helpers = append(rec.Helpers, h)
+		}
+	}
+
+	if err := r.auditLog.Append(rec); err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Warning,
+			"Failed to write audit log",
+			fmt.Sprintf("Could not record this run in the audit log %s: %s.", r.auditLog.Path(), err),
+		))
+	}
+	return diags
+}
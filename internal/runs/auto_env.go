@@ -0,0 +1,134 @@
+package runs
+
+import (
+	"context"
+	"time"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/states"
+
+	"github.com/hashicorp/hcl2/gohcl"
+)
+
+// AutoEnvCall represents a request to evaluate the auto_env block of a
+// configuration, as the shell hook does when entering a directory.
+type AutoEnvCall struct {
+	// WorkingDir is the directory that the shell is in.
+	WorkingDir string
+
+	// Environ is the shell's environment, which can set input variables.
+	Environ []string
+
+	// Profile is the name of the profile that the configuration was
+	// prepared for, or empty for configs.DefaultProfileName.
+	Profile string
+}
+
+// AutoEnvironment is the result of evaluating an auto_env block.
+type AutoEnvironment struct {
+	// Env are the environment variables to set.
+	Env map[string]string
+
+	// Expires is the time after which the block should be evaluated again,
+	// either because its cache period has elapsed or because one of the
+	// helpers it uses has asked to be refreshed by then.
+	Expires time.Time
+}
+
+type autoEnvNode struct {
+	graphs.AutoEnvNode
+	Config *configs.AutoEnv
+}
+
+func (n *autoEnvNode) References() []configs.Reference {
+	return n.Config.AllReferences()
+}
+
+// AutoEnvironment evaluates the auto_env block of the given configuration,
+// starting the helpers it depends on in order to do so and then closing
+// them again before returning.
+//
+// As with CommandEnvironment, the result includes sensitive values verbatim,
+// and any temporary files that the values refer to no longer exist once this
+// function returns. For the same reason, the evaluation is recorded in the
+// audit log with the action audit.ActionHook.
+//
+// It's an error to call this for a configuration without an auto_env block.
+func (r *Runner) AutoEnvironment(ctx context.Context, call *AutoEnvCall, cfg *configs.Config) (result *AutoEnvironment, diags nvdiags.Diagnostics) {
+	if cfg.AutoEnv == nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"No auto_env block",
+			"The configuration does not declare an auto_env block.",
+		))
+		return nil, diags
+	}
+
+	start := time.Now()
+	graph := graphs.NewGraph()
+	root := &autoEnvNode{Config: cfg.AutoEnv}
+	diags = diags.Append(graph.AddWithReferents(root, referentNodeFactory(cfg, r.helperTypes), policyCheck(cfg, root.Config.Addr())))
+	if diags.HasErrors() {
+		diags = diags.Append(r.recordAutoEnv(call, nil, start, 1))
+		return nil, diags
+	}
+
+	run, moreDiags := r.startGraphRun(ctx, graph, root, cfg.AutoEnv.DeclRange, cfg, &runInputs{
+		WorkingDir: call.WorkingDir,
+		Environ:    call.Environ,
+		Profile:    call.Profile,
+	})
+	diags = diags.Append(moreDiags)
+	if run != nil {
+		defer func() {
+			diags = diags.Append(run.close())
+		}()
+	}
+	defer func() {
+		status := 0
+		if diags.HasErrors() {
+			status = 1
+		}
+		diags = diags.Append(r.recordAutoEnv(call, run, start, status))
+	}()
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	env, moreDiags := root.environment(run.state, run.scope)
+	diags = diags.Append(run.redact(moreDiags))
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	expires := time.Now().Add(cfg.AutoEnv.CacheFor)
+	for _, n := range run.helpers {
+		if next := n.nextRefresh(); !next.IsZero() && next.Before(expires) {
+			expires = next
+		}
+	}
+
+	return &AutoEnvironment{
+		Env:     env,
+		Expires: expires,
+	}, diags
+}
+
+// environment evaluates the block's environment variables using the values
+// in the given state.
+func (n *autoEnvNode) environment(state *states.State, scope *evalScope) (map[string]string, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	ctx := scope.EvalContext(state, addrs.AutoEnv{})
+	logging.Debug("evaluating auto_env", "layer", n.Config.Layer)
+
+	env := map[string]string{}
+	if v, hclDiags := n.Config.Environment.Value(ctx); !v.IsNull() || hclDiags.HasErrors() {
+		hclDiags = gohcl.DecodeExpression(n.Config.Environment, ctx, &env)
+		diags = diags.Append(hclDiags)
+	}
+	return env, diags
+}
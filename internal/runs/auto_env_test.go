@@ -0,0 +1,61 @@
+package runs
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"envy.pw/cli/internal/audit"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/helpers"
+)
+
+func TestAutoEnvironmentAudit(t *testing.T) {
+	cfg, hclDiags := configs.LoadConfig("testdata/auto-env")
+	if hclDiags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", hclDiags.Error())
+	}
+
+	dir, err := ioutil.TempDir("", "envy-audit-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	log := audit.NewLog(filepath.Join(dir, "audit.jsonl"))
+
+	runner := NewRunner(helpers.Types{})
+	runner.SetAuditLog(log)
+	result, diags := runner.AutoEnvironment(context.Background(), &AutoEnvCall{
+		WorkingDir: "/work",
+		Profile:    configs.DefaultProfileName,
+	}, cfg)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diagMessages(diags))
+	}
+	if got, want := result.Env["GREETING"], "hello"; got != want {
+		t.Errorf("wrong GREETING %q; want %q", got, want)
+	}
+
+	recs, err := log.Query(&audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 {
+		t.Fatalf("wrong number of records %d; want 1", len(recs))
+	}
+	rec := recs[0]
+	if got, want := rec.Command, "auto_env"; got != want {
+		t.Errorf("wrong command %q; want %q", got, want)
+	}
+	if got, want := rec.Action, audit.ActionHook; got != want {
+		t.Errorf("wrong action %q; want %q", got, want)
+	}
+	if got, want := rec.WorkingDir, "/work"; got != want {
+		t.Errorf("wrong working directory %q; want %q", got, want)
+	}
+	if got, want := rec.Status, 0; got != want {
+		t.Errorf("wrong status %d; want %d", got, want)
+	}
+}
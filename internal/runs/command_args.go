@@ -0,0 +1,128 @@
+package runs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+// parseCommandArgs parses the options declared for the given command from
+// the given command line arguments, returning the value of the "args" object
+// for the command's expressions along with the remaining positional
+// arguments.
+//
+// The "args" object has an attribute for each declared option, along with
+// "all" for the arguments exactly as given, "positional" for the arguments
+// other than the declared options, and numbered attributes so that the
+// positional arguments can be accessed as args[0], args[1], and so on.
+//
+// Arguments that look like options but don't match any declared option are
+// treated as positional, so that they are passed through to the program.
+// An argument of "--" ends option parsing, and is not itself included in
+// the positional arguments.
+func parseCommandArgs(cmd *configs.Command, all []string) (cty.Value, []string, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	var positional []string
+	given := make(map[string]cty.Value)
+	if len(cmd.Args) == 0 {
+		// Without any declared options there's nothing to parse, and so
+		// "--" is passed through like any other argument.
+		positional = all
+	} else {
+		byFlag := make(map[string]*configs.CommandArg)
+		for _, arg := range cmd.Args {
+			byFlag["--"+arg.Name] = arg
+			if arg.Short != "" {
+				byFlag["-"+arg.Short] = arg
+			}
+		}
+
+		for i := 0; i < len(all); i++ {
+			s := all[i]
+			if s == "--" {
+				positional = append(positional, all[i+1:]...)
+				break
+			}
+
+			flag, val, hasVal := s, "", false
+			if eq := strings.Index(s, "="); eq > 0 {
+				flag, val, hasVal = s[:eq], s[eq+1:], true
+			}
+			arg, ok := byFlag[flag]
+			if !ok && len(s) > 2 && s[0] == '-' && s[1] != '-' {
+				// A short option can also have its value attached, as in
+				// "-pprod".
+				if arg, ok = byFlag[s[:2]]; ok {
+					flag, val, hasVal = s[:2], s[2:], true
+				}
+			}
+			if !ok {
+				positional = append(positional, s)
+				continue
+			}
+
+			if !hasVal {
+				switch {
+				case arg.Type == cty.Bool:
+					val = "true"
+				case i+1 < len(all):
+					i++
+					val = all[i]
+				default:
+					diags = diags.Append(nvdiags.Sourceless(
+						nvdiags.Error,
+						"Missing argument value",
+						fmt.Sprintf("The option %s must be followed by a value.", flag),
+					))
+					continue
+				}
+			}
+
+			v, err := convert.Convert(cty.StringVal(val), arg.Type)
+			if err != nil {
+				diags = diags.Append(nvdiags.Sourceless(
+					nvdiags.Error,
+					"Invalid argument value",
+					fmt.Sprintf("The value %q for option %s is not a valid %s.", val, flag, typeexpr.TypeString(arg.Type)),
+				))
+				continue
+			}
+			given[arg.Name] = v
+		}
+	}
+
+	attrs := map[string]cty.Value{
+		"all":        stringListVal(all),
+		"positional": stringListVal(positional),
+	}
+	for i, s := range positional {
+		attrs[strconv.Itoa(i)] = cty.StringVal(s)
+	}
+	for _, arg := range cmd.Args {
+		if v, ok := given[arg.Name]; ok {
+			attrs[arg.Name] = v
+		} else {
+			attrs[arg.Name] = arg.Default
+		}
+	}
+	return cty.ObjectVal(attrs), positional, diags
+}
+
+func stringListVal(strs []string) cty.Value {
+	if len(strs) == 0 {
+		return cty.ListValEmpty(cty.String)
+	}
+	vals := make([]cty.Value, len(strs))
+	for i, s := range strs {
+		vals[i] = cty.StringVal(s)
+	}
+	return cty.ListVal(vals)
+}
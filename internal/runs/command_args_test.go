@@ -0,0 +1,174 @@
+package runs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestParseCommandArgs(t *testing.T) {
+	cmd := &configs.Command{
+		Args: []*configs.CommandArg{
+			{Name: "profile", Short: "p", Type: cty.String, Default: cty.StringVal("dev")},
+			{Name: "verbose", Short: "v", Type: cty.Bool, Default: cty.False},
+			{Name: "count", Type: cty.Number, Default: cty.NullVal(cty.Number)},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    map[string]cty.Value // the declared options' values
+		wantPos []string
+		wantErr bool
+	}{
+		{
+			"defaults",
+			nil,
+			map[string]cty.Value{"profile": cty.StringVal("dev"), "verbose": cty.False, "count": cty.NullVal(cty.Number)},
+			nil,
+			false,
+		},
+		{
+			"separate value",
+			[]string{"--profile", "prod", "deploy"},
+			map[string]cty.Value{"profile": cty.StringVal("prod"), "verbose": cty.False, "count": cty.NullVal(cty.Number)},
+			[]string{"deploy"},
+			false,
+		},
+		{
+			"long with equals",
+			[]string{"--profile=prod", "--count=3"},
+			map[string]cty.Value{"profile": cty.StringVal("prod"), "verbose": cty.False, "count": cty.NumberIntVal(3)},
+			nil,
+			false,
+		},
+		{
+			"short attached value",
+			[]string{"-pprod", "deploy"},
+			map[string]cty.Value{"profile": cty.StringVal("prod"), "verbose": cty.False, "count": cty.NullVal(cty.Number)},
+			[]string{"deploy"},
+			false,
+		},
+		{
+			"short separate value",
+			[]string{"-p", "prod"},
+			map[string]cty.Value{"profile": cty.StringVal("prod"), "verbose": cty.False, "count": cty.NullVal(cty.Number)},
+			nil,
+			false,
+		},
+		{
+			"bool flags",
+			[]string{"-v", "deploy", "--verbose=false"},
+			map[string]cty.Value{"profile": cty.StringVal("dev"), "verbose": cty.False, "count": cty.NullVal(cty.Number)},
+			[]string{"deploy"},
+			false,
+		},
+		{
+			"bool flag doesn't take a value",
+			[]string{"--verbose", "deploy"},
+			map[string]cty.Value{"profile": cty.StringVal("dev"), "verbose": cty.True, "count": cty.NullVal(cty.Number)},
+			[]string{"deploy"},
+			false,
+		},
+		{
+			"unknown flags passed through",
+			[]string{"--force", "-x", "--region=eu", "-v"},
+			map[string]cty.Value{"profile": cty.StringVal("dev"), "verbose": cty.True, "count": cty.NullVal(cty.Number)},
+			[]string{"--force", "-x", "--region=eu"},
+			false,
+		},
+		{
+			"double dash",
+			[]string{"-v", "--", "--profile", "prod", "--"},
+			map[string]cty.Value{"profile": cty.StringVal("dev"), "verbose": cty.True, "count": cty.NullVal(cty.Number)},
+			[]string{"--profile", "prod", "--"},
+			false,
+		},
+		{
+			"missing value",
+			[]string{"deploy", "--profile"},
+			nil,
+			nil,
+			true,
+		},
+		{
+			"invalid value",
+			[]string{"--count", "many"},
+			nil,
+			nil,
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, pos, diags := parseCommandArgs(cmd, test.args)
+			if gotErr := diags.HasErrors(); gotErr != test.wantErr {
+				t.Fatalf("wrong error result %t; want %t (%s)", gotErr, test.wantErr, diagMessages(diags))
+			}
+			if test.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(pos, test.wantPos) {
+				t.Errorf("wrong positional arguments\ngot:  %q\nwant: %q", pos, test.wantPos)
+			}
+			for name, want := range test.want {
+				if v := got.GetAttr(name); !v.RawEquals(want) {
+					t.Errorf("wrong value for %s %#v; want %#v", name, v, want)
+				}
+			}
+			if all := got.GetAttr("all"); all.LengthInt() != len(test.args) {
+				t.Errorf("wrong number of arguments in all %d; want %d", all.LengthInt(), len(test.args))
+			}
+			if got.GetAttr("positional").LengthInt() != len(test.wantPos) {
+				t.Errorf("wrong positional attribute %#v", got.GetAttr("positional"))
+			}
+			for i, want := range test.wantPos {
+				name := fmt.Sprint(i)
+				if !got.Type().HasAttribute(name) {
+					t.Errorf("no attribute %s for positional argument %q", name, want)
+					continue
+				}
+				if v := got.GetAttr(name); !v.RawEquals(cty.StringVal(want)) {
+					t.Errorf("wrong value for args[%d] %#v; want %q", i, v, want)
+				}
+			}
+			if got.Type().HasAttribute(fmt.Sprint(len(test.wantPos))) {
+				t.Errorf("unexpected attribute for args[%d]", len(test.wantPos))
+			}
+		})
+	}
+}
+
+// diagMessages returns the messages of the given diagnostics, for test
+// failure messages.
+func diagMessages(diags nvdiags.Diagnostics) string {
+	var msgs []string
+	for _, diag := range diags {
+		m := diag.Messages()
+		msgs = append(msgs, m.Summary+": "+m.Detail)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func TestParseCommandArgsUndeclared(t *testing.T) {
+	// Without any declared options, "--" is an ordinary argument.
+	args := []string{"--", "-v", "x"}
+	got, pos, diags := parseCommandArgs(&configs.Command{}, args)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diagMessages(diags))
+	}
+	if !reflect.DeepEqual(pos, args) {
+		t.Errorf("wrong positional arguments\ngot:  %q\nwant: %q", pos, args)
+	}
+	if v := got.GetAttr("0"); !v.RawEquals(cty.StringVal("--")) {
+		t.Errorf("wrong value for args[0] %#v", v)
+	}
+}
@@ -0,0 +1,57 @@
+package runs
+
+import (
+	"context"
+	"time"
+
+	"envy.pw/cli/internal/audit"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// CommandEnvironment evaluates the given command call in the same way as
+// RunCommand would, but then returns the environment variables set by the
+// command's configuration instead of launching the command.
+//
+// Unlike DescribeCommand, the result includes sensitive values verbatim,
+// because the caller is expected to pass them on to some other program.
+//
+// The command's helpers are started in order to evaluate the command, and
+// are then closed again before returning, so any temporary files that the
+// values refer to no longer exist once this function returns.
+//
+// Since the result typically contains credentials, the call is recorded in
+// the audit log just as a run of the command would be, with a status of 1
+// if it failed.
+func (r *Runner) CommandEnvironment(ctx context.Context, call *CommandCall, cfg *configs.Config) (env map[string]string, diags nvdiags.Diagnostics) {
+	start := time.Now()
+	run, moreDiags := r.prepareCommandRun(ctx, call, cfg)
+	diags = diags.Append(moreDiags)
+	if run != nil {
+		defer func() {
+			diags = diags.Append(run.close())
+		}()
+	}
+	defer func() {
+		status := 0
+		if diags.HasErrors() {
+			status = 1
+		}
+		diags = diags.Append(r.recordRun(call, run, audit.ActionEnv, start, nil, status))
+	}()
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	proc, moreDiags := run.process()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	env = make(map[string]string, len(proc.SetEnv))
+	for k, v := range proc.SetEnv {
+		env[k] = v
+	}
+	return env, diags
+}
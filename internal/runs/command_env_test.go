@@ -0,0 +1,87 @@
+package runs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/helpers"
+)
+
+func TestCommandEnvironmentSharedProfile(t *testing.T) {
+	base, hclDiags := configs.LoadConfig("testdata/shared-profile")
+	if hclDiags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", hclDiags.Error())
+	}
+
+	tests := []struct {
+		profile string
+		want    map[string]string
+	}{
+		{
+			configs.DefaultProfileName,
+			map[string]string{"REGION": "us-east-1", "ACCOUNT": "dev"},
+		},
+		{
+			"prod",
+			map[string]string{"REGION": "eu-west-1", "ACCOUNT": "dev"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.profile, func(t *testing.T) {
+			cfg, hclDiags := base.WithProfile(test.profile)
+			if hclDiags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", hclDiags.Error())
+			}
+
+			runner := NewRunner(helpers.Types{})
+			env, diags := runner.CommandEnvironment(context.Background(), &CommandCall{
+				Addr:       addrs.MakeCommand("show"),
+				Profile:    test.profile,
+				WorkingDir: ".",
+			}, cfg)
+			if diags.HasErrors() {
+				t.Fatalf("unexpected errors: %s", diagMessages(diags))
+			}
+
+			for k, want := range test.want {
+				if got := env[k]; got != want {
+					t.Errorf("wrong value for %s %q; want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandEnvironmentProgramEnv(t *testing.T) {
+	cfg, hclDiags := configs.LoadConfig("testdata/program-env")
+	if hclDiags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", hclDiags.Error())
+	}
+
+	// The variables for a replacement program, like those that "envy shell"
+	// sets, must survive inherit_env = false and override the command's own.
+	runner := NewRunner(helpers.Types{})
+	env, diags := runner.CommandEnvironment(context.Background(), &CommandCall{
+		Addr:       addrs.MakeCommand("clean"),
+		Environ:    []string{"INHERITED=yes"},
+		WorkingDir: ".",
+		Program:    []string{"/bin/sh"},
+		ProgramEnv: []string{"ENVY_SHELL=clean", "B=program"},
+	}, cfg)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diagMessages(diags))
+	}
+
+	want := map[string]string{
+		"A":          "1",
+		"B":          "program",
+		"ENVY_SHELL": "clean",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("wrong environment\ngot:  %#v\nwant: %#v", env, want)
+	}
+}
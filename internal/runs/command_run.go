@@ -0,0 +1,72 @@
+package runs
+
+import (
+	"context"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+)
+
+// commandRun is the context for evaluating a particular command call, which
+// is shared by all of the operations that need the command's helpers to be
+// running.
+type commandRun struct {
+	*graphRun
+	call *CommandCall
+	root *commandExecNode
+
+	// args are the positional arguments remaining after parsing the
+	// command's declared options from the command line.
+	args []string
+}
+
+// prepareCommandRun builds the graph for the given command call, creates
+// the temporary directories for its objects and then starts all of the
+// helpers it depends on.
+//
+// If the result is non-nil then the caller must call close on it once it is
+// finished, even if the diagnostics contain errors.
+func (r *Runner) prepareCommandRun(ctx context.Context, call *CommandCall, cfg *configs.Config) (*commandRun, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	graph, root, moreDiags := graphForRunCommand(call, cfg, r.helperTypes)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	// We parse the arguments before starting any helpers so that a mistake
+	// on the command line is reported immediately.
+	argsVal, args, moreDiags := parseCommandArgs(root.Config, call.Args)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	gr, moreDiags := r.startGraphRun(ctx, graph, root, root.Config.DeclRange, cfg, &runInputs{
+		WorkingDir: call.WorkingDir,
+		Environ:    call.Environ,
+		Variables:  call.Variables,
+		Profile:    call.Profile,
+	})
+	diags = diags.Append(moreDiags)
+	if gr == nil {
+		return nil, diags
+	}
+	gr.state.SetValue(addrs.Args{}, argsVal)
+
+	return &commandRun{
+		graphRun: gr,
+		call:     call,
+		root:     root,
+		args:     args,
+	}, diags
+}
+
+// process evaluates the command's configuration using the current helper
+// results to decide what child process to launch.
+func (run *commandRun) process() (*process, nvdiags.Diagnostics) {
+	proc, diags := run.root.process(run.call, run.args, run.state, run.scope)
+	return proc, run.redact(diags)
+}
@@ -0,0 +1,84 @@
+package runs
+
+import (
+	"context"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/redact"
+)
+
+// CommandDescription describes the child process that a command call would
+// launch, with any sensitive values redacted so that it is safe to show.
+type CommandDescription struct {
+	// Layer describes the configuration layer that the command was
+	// declared in.
+	Layer string
+
+	Path string
+	Args []string
+	Dir  string
+
+	// Env contains only the environment variables set by the command's
+	// configuration, and not those inherited from envy's own environment.
+	Env map[string]string
+}
+
+// DescribeCommand evaluates the given command call in the same way as
+// RunCommand would, but then describes the child process instead of
+// launching it.
+//
+// The command's helpers are started in order to evaluate the command, and
+// are then closed again before returning.
+func (r *Runner) DescribeCommand(ctx context.Context, call *CommandCall, cfg *configs.Config) (desc *CommandDescription, diags nvdiags.Diagnostics) {
+	run, moreDiags := r.prepareCommandRun(ctx, call, cfg)
+	diags = diags.Append(moreDiags)
+	if run != nil {
+		defer func() {
+			diags = diags.Append(run.close())
+		}()
+	}
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	proc, moreDiags := run.process()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return nil, diags
+	}
+
+	desc = proc.describe()
+	desc.Layer = run.root.Config.Layer.String()
+	return desc, diags
+}
+
+// describe produces a description of the process, hiding the values that
+// derive from sensitive values.
+func (p *process) describe() *CommandDescription {
+	desc := &CommandDescription{
+		Path: p.Path,
+		Args: make([]string, len(p.Args)),
+		Dir:  p.Dir,
+		Env:  make(map[string]string, len(p.SetEnv)),
+	}
+	for i, arg := range p.Args {
+		if p.SensitiveArgs[i] {
+			arg = redact.Placeholder
+		}
+		desc.Args[i] = arg
+	}
+	if p.SensitiveArgs[0] {
+		desc.Path = redact.Placeholder
+	}
+	if p.SensitiveDir {
+		desc.Dir = redact.Placeholder
+	}
+	for k, v := range p.SetEnv {
+		if p.SensitiveEnv[k] {
+			v = redact.Placeholder
+		}
+		desc.Env[k] = v
+	}
+	return desc
+}
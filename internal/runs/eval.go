@@ -0,0 +1,162 @@
+package runs
+
+import (
+	"path/filepath"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/funcs"
+	"envy.pw/cli/internal/states"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/function"
+)
+
+// evalScope contains the information, other than the values of objects,
+// needed to evaluate expressions during a particular run.
+type evalScope struct {
+	// Config is the configuration being evaluated, which decides which
+	// directory "path.config" refers to for each object.
+	Config *configs.Config
+
+	// WorkingDir is the working directory that envy was launched in, which
+	// is the default working directory for any child processes.
+	WorkingDir string
+
+	// TempRoot is a private temporary directory for the run, under which
+	// each object has its own directory that "path.temp" refers to.
+	TempRoot string
+
+	// functions are the tables of functions available in expressions,
+	// keyed by the configuration directory that they resolve paths in.
+	functions map[string]map[string]function.Function
+}
+
+// newEvalScope creates the scope for a run. Values passed to the
+// "nonsensitive" function are recorded in the given state using
+// Declassify, so that they aren't redacted from output.
+func newEvalScope(cfg *configs.Config, workingDir, tempRoot string, state *states.State) *evalScope {
+	s := &evalScope{
+		Config:     cfg,
+		WorkingDir: workingDir,
+		TempRoot:   tempRoot,
+		functions:  map[string]map[string]function.Function{},
+	}
+	dirs := []string{cfg.BaseDir}
+	for _, layer := range cfg.Layers {
+		dirs = append(dirs, layer.Dir)
+	}
+	for _, m := range cfg.Modules {
+		if m.Dir != "" {
+			dirs = append(dirs, m.Dir)
+		}
+	}
+	for _, dir := range dirs {
+		if _, exists := s.functions[dir]; !exists {
+			table := funcs.Table(dir)
+			table["nonsensitive"] = funcs.MakeNonsensitiveFunc(state.Declassify)
+			s.functions[dir] = table
+		}
+	}
+	return s
+}
+
+// ConfigDir returns the directory that configuration-relative paths for the
+// object with the given address are relative to, which "path.config" refers
+// to in the object's expressions.
+func (s *evalScope) ConfigDir(addr addrs.Referenceable) string {
+	return s.Config.ObjectBaseDir(addr)
+}
+
+// Functions returns the functions available in the expressions of the
+// object with the given address.
+func (s *evalScope) Functions(addr addrs.Referenceable) map[string]function.Function {
+	return s.functions[s.ConfigDir(addr)]
+}
+
+// TempDir returns the path of the temporary directory belonging to the
+// object with the given address.
+func (s *evalScope) TempDir(addr addrs.Referenceable) string {
+	return filepath.Join(s.TempRoot, addr.String())
+}
+
+// EvalContext builds an HCL evaluation context that can be used to evaluate
+// expressions belonging to the object with the given address, which may
+// refer to the objects whose values are in the given state.
+//
+// Expressions in a module refer to the module's own helpers as TYPE.NAME,
+// while expressions elsewhere refer to them as module.MODULE.TYPE.NAME.
+func (s *evalScope) EvalContext(state *states.State, self addrs.Referenceable) *hcl.EvalContext {
+	var selfModule string
+	if addr, ok := self.(addrs.Helper); ok {
+		selfModule = addr.Module
+	}
+
+	vars := make(map[string]cty.Value)
+	helpers := make(map[string]map[string]cty.Value)
+	modules := make(map[string]map[string]map[string]cty.Value)
+	variables := make(map[string]cty.Value)
+	shared := make(map[string]cty.Value)
+	for addr, v := range state.Values() {
+		switch addr := addr.(type) {
+		case addrs.Variable:
+			variables[addr.Name] = v
+		case addrs.SharedObject:
+			shared[addr.Name] = v
+		case addrs.Profile:
+			vars["profile"] = v
+		case addrs.Args:
+			if _, isCommand := self.(addrs.Command); isCommand {
+				vars["args"] = v
+			}
+		case addrs.Helper:
+			if addr.Module == selfModule {
+				if helpers[addr.Type] == nil {
+					helpers[addr.Type] = make(map[string]cty.Value)
+				}
+				helpers[addr.Type][addr.Name] = v
+			}
+			if addr.Module != "" && selfModule == "" {
+				if modules[addr.Module] == nil {
+					modules[addr.Module] = make(map[string]map[string]cty.Value)
+				}
+				if modules[addr.Module][addr.Type] == nil {
+					modules[addr.Module][addr.Type] = make(map[string]cty.Value)
+				}
+				modules[addr.Module][addr.Type][addr.Name] = v
+			}
+		}
+	}
+
+	for typeName, byName := range helpers {
+		vars[typeName] = cty.ObjectVal(byName)
+	}
+	if len(modules) != 0 {
+		mods := make(map[string]cty.Value, len(modules))
+		for name, byType := range modules {
+			types := make(map[string]cty.Value, len(byType))
+			for typeName, byName := range byType {
+				types[typeName] = cty.ObjectVal(byName)
+			}
+			mods[name] = cty.ObjectVal(types)
+		}
+		vars["module"] = cty.ObjectVal(mods)
+	}
+	if len(variables) != 0 {
+		vars["var"] = cty.ObjectVal(variables)
+	}
+	if len(shared) != 0 {
+		vars["shared"] = cty.ObjectVal(shared)
+	}
+	vars["path"] = cty.ObjectVal(map[string]cty.Value{
+		string(addrs.PathWorking): cty.StringVal(s.WorkingDir),
+		string(addrs.PathConfig):  cty.StringVal(s.ConfigDir(self)),
+		string(addrs.PathTemp):    cty.StringVal(s.TempDir(self)),
+	})
+
+	return &hcl.EvalContext{
+		Variables: vars,
+		Functions: s.Functions(self),
+	}
+}
@@ -0,0 +1,188 @@
+package runs
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/redact"
+	"envy.pw/cli/internal/states"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+// graphRun is the context for evaluating the objects in a graph built from
+// some root object, such as a command, with all of the helpers that the
+// root depends on running.
+type graphRun struct {
+	state    *states.State
+	scope    *evalScope
+	tempRoot string
+
+	// helpers are the helper nodes in the graph, in dependency order.
+	helpers []*helperRunNode
+}
+
+// runInputs are the settings for a graph run that come from outside of the
+// configuration.
+type runInputs struct {
+	// WorkingDir is the directory that envy was launched in.
+	WorkingDir string
+
+	// Environ is envy's own environment, which can set input variables.
+	Environ []string
+
+	// Variables are the values given for input variables on the command
+	// line, keyed by variable name.
+	Variables map[string]string
+
+	// Profile is the name of the selected profile, or empty for
+	// configs.DefaultProfileName.
+	Profile string
+}
+
+// startGraphRun creates the temporary directories for the objects in the
+// given graph, decides the values of its input variables and then starts
+// all of its helpers.
+//
+// The root node and its source range are used only for messages.
+//
+// If the result is non-nil then the caller must call close on it once it is
+// finished, even if the diagnostics contain errors.
+func (r *Runner) startGraphRun(ctx context.Context, graph *graphs.Graph, root graphs.Node, rootRange nvdiags.SourceRange, cfg *configs.Config, in *runInputs) (*graphRun, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	rootName := graphs.NodeDebugName(root)
+
+	order, err := graph.DependencyOrder()
+	if err != nil {
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Dependency cycle",
+			fmt.Sprintf("The objects used by %s refer to one another in a cycle: %s.", rootName, err),
+			rootRange,
+		))
+		return nil, diags
+	}
+	if logging.Enabled(logging.LevelDebug) {
+		names := make([]string, len(order))
+		for i, n := range order {
+			names[i] = graphs.NodeDebugName(n)
+		}
+		logging.Debug("built graph", "root", rootName, "order", strings.Join(names, ","))
+	}
+
+	tempRoot, err := ioutil.TempDir("", "envy-run-")
+	if err != nil {
+		diags = diags.Append(nvdiags.Sourceless(
+			nvdiags.Error,
+			"Failed to create temporary directory",
+			fmt.Sprintf("Could not create a temporary directory for this run: %s.", err),
+		))
+		return nil, diags
+	}
+	logging.Trace("created temporary directory", "dir", tempRoot)
+
+	state := states.NewState()
+	run := &graphRun{
+		state:    state,
+		scope:    newEvalScope(cfg, in.WorkingDir, tempRoot, state),
+		tempRoot: tempRoot,
+	}
+
+	profile := in.Profile
+	if profile == "" {
+		profile = configs.DefaultProfileName
+	}
+	run.state.SetValue(addrs.Profile{}, cty.ObjectVal(map[string]cty.Value{
+		"name": cty.StringVal(profile),
+	}))
+
+	inputs, moreDiags := collectInputValues(in.Environ, in.Variables, cfg)
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return run, diags
+	}
+
+	for _, n := range order {
+		// Input variables don't depend on anything else, so we can decide
+		// their values as soon as we find them.
+		if n, ok := n.(*variableEvalNode); ok {
+			moreDiags := n.evaluate(inputs[n.Addr.Name], run.state, run.scope)
+			diags = diags.Append(moreDiags)
+			if moreDiags.HasErrors() {
+				return run, diags
+			}
+			continue
+		}
+		// Shared objects can refer only to input variables, which come
+		// before them in the dependency order.
+		if n, ok := n.(*sharedObjectEvalNode); ok {
+			moreDiags := n.evaluate(run.state, run.scope)
+			diags = diags.Append(moreDiags)
+			if moreDiags.HasErrors() {
+				return run, diags
+			}
+			continue
+		}
+
+		addr := graphs.NodeReferenceableAddr(n)
+		if addr != nil {
+			if err := os.Mkdir(run.scope.TempDir(addr), 0700); err != nil {
+				diags = diags.Append(nvdiags.Sourceless(
+					nvdiags.Error,
+					"Failed to create temporary directory",
+					fmt.Sprintf("Could not create a temporary directory for %s: %s.", addr, err),
+				))
+				return run, diags
+			}
+		}
+		if n, ok := n.(*helperRunNode); ok {
+			run.helpers = append(run.helpers, n)
+		}
+	}
+
+	for _, n := range run.helpers {
+		_, moreDiags := n.update(ctx, run.state, run.scope, false)
+		diags = diags.Append(run.redact(moreDiags))
+		if moreDiags.HasErrors() {
+			return run, diags
+		}
+	}
+
+	return run, diags
+}
+
+// redactor returns a redactor for the sensitive values currently recorded
+// in the run's state, which leaves alone any strings that the state records
+// as not sensitive.
+func (run *graphRun) redactor() *redact.Redactor {
+	return redact.NewRedactor(run.state.SensitiveValues()...).Except(run.state.NonsensitiveValues()...)
+}
+
+// redact hides any sensitive values currently recorded in the run's state
+// from the given diagnostics.
+//
+// Diagnostics must be redacted as soon as they are produced, because a
+// helper's result may later be replaced by a refresh.
+func (run *graphRun) redact(diags nvdiags.Diagnostics) nvdiags.Diagnostics {
+	return run.redactor().Diagnostics(diags)
+}
+
+// close shuts down all of the run's helpers, in the reverse of the order
+// they were started so that each helper outlives everything that depends on
+// it, and then deletes the run's temporary directories.
+func (run *graphRun) close() nvdiags.Diagnostics {
+	var diags nvdiags.Diagnostics
+	for i := len(run.helpers) - 1; i >= 0; i-- {
+		diags = diags.Append(run.redact(run.helpers[i].close()))
+	}
+	os.RemoveAll(run.tempRoot)
+	return diags
+}
@@ -0,0 +1,141 @@
+package runs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/nvdiags"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// VariableEnvPrefix is the prefix of the names of environment variables that
+// set values for input variables. For example, ENVY_VAR_region sets the
+// value of var.region.
+const VariableEnvPrefix = "ENVY_VAR_"
+
+// inputValue is a value given for an input variable from outside of the
+// configuration.
+//
+// Values from a variable values file are expressions, while values from the
+// command line or the environment are strings whose interpretation depends
+// on the variable's type constraint.
+type inputValue struct {
+	Expr hcl.Expression
+	Raw  string
+
+	// Source describes where the value came from, for use in messages.
+	Source string
+}
+
+// value returns the value to use for a variable with the given type
+// constraint.
+//
+// A raw string is taken literally if the type constraint is primitive, and
+// otherwise parsed as an expression so that collection values can be given.
+func (iv *inputValue) value(ty cty.Type) (cty.Value, hcl.Diagnostics) {
+	expr := iv.Expr
+	if expr == nil {
+		if ty == cty.DynamicPseudoType || ty.IsPrimitiveType() {
+			return cty.StringVal(iv.Raw), nil
+		}
+		var diags hcl.Diagnostics
+		expr, diags = hclsyntax.ParseExpression([]byte(iv.Raw), iv.Source, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			return cty.DynamicVal, diags
+		}
+	}
+	return expr.Value(nil)
+}
+
+// collectInputValues gathers the values given for the configuration's input
+// variables, keyed by variable name.
+//
+// In order of increasing precedence, the values come from the variable
+// values files in each of the configuration's directories, from environment
+// variables whose names start with VariableEnvPrefix, and from the command
+// line.
+func collectInputValues(environ []string, cliVars map[string]string, cfg *configs.Config) (map[string]*inputValue, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	ret := make(map[string]*inputValue)
+
+	dirs := []string{cfg.BaseDir}
+	if len(cfg.Layers) != 0 {
+		dirs = dirs[:0]
+		for _, layer := range cfg.Layers {
+			dirs = append(dirs, layer.Dir)
+		}
+	}
+	for _, dir := range dirs {
+		attrs, moreDiags := configs.LoadVariableValues(dir)
+		diags = diags.Append(moreDiags)
+		names := make([]string, 0, len(attrs))
+		for name := range attrs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			attr := attrs[name]
+			if _, declared := cfg.Variables[addrs.Variable{Name: name}]; !declared {
+				diags = diags.Append(nvdiags.WithSource(
+					nvdiags.Warning,
+					"Value for undeclared variable",
+					fmt.Sprintf("A value is set for variable %q, but no variable of that name is declared in the configuration.", name),
+					attr.NameRange,
+				))
+				continue
+			}
+			ret[name] = &inputValue{
+				Expr:   attr.Expr,
+				Source: attr.NameRange.Filename,
+			}
+		}
+	}
+
+	for _, env := range environ {
+		if !strings.HasPrefix(env, VariableEnvPrefix) {
+			continue
+		}
+		eq := strings.Index(env, "=")
+		if eq < 0 {
+			continue
+		}
+		name := env[len(VariableEnvPrefix):eq]
+		// Environment variables aren't specific to envy, so we silently
+		// ignore any that don't correspond to declared variables.
+		if _, declared := cfg.Variables[addrs.Variable{Name: name}]; !declared {
+			continue
+		}
+		ret[name] = &inputValue{
+			Raw:    env[eq+1:],
+			Source: fmt.Sprintf("the environment variable %s%s", VariableEnvPrefix, name),
+		}
+	}
+
+	names := make([]string, 0, len(cliVars))
+	for name := range cliVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, declared := cfg.Variables[addrs.Variable{Name: name}]; !declared {
+			diags = diags.Append(nvdiags.Sourceless(
+				nvdiags.Error,
+				"Value for undeclared variable",
+				fmt.Sprintf("A value is given for variable %q on the command line, but no variable of that name is declared in the configuration.%s", name, searchedLayersDetail(cfg)),
+			))
+			continue
+		}
+		ret[name] = &inputValue{
+			Raw:    cliVars[name],
+			Source: fmt.Sprintf("the --var-%s option", name),
+		}
+	}
+
+	return ret, diags
+}
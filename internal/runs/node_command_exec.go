@@ -1,8 +1,23 @@
 package runs
 
 import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
 	"envy.pw/cli/internal/configs"
 	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/states"
+
+	"github.com/hashicorp/hcl2/gohcl"
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
 )
 
 type commandExecNode struct {
@@ -13,3 +28,234 @@ type commandExecNode struct {
 func (n *commandExecNode) References() []configs.Reference {
 	return n.Config.AllReferences()
 }
+
+// process describes a child process to launch for a command.
+type process struct {
+	Path string
+	Args []string
+	Env  []string
+	Dir  string
+
+	// SetEnv is the subset of the environment variables in Env that were
+	// set by the command's configuration, rather than inherited.
+	SetEnv map[string]string
+
+	// Termination decides how the child process is stopped. It doesn't
+	// affect which child process is launched, and so equal ignores it.
+	Termination *configs.Termination
+
+	// Sandbox decides the restrictions placed on the child process. It
+	// comes directly from the configuration, and so is the same for every
+	// child process of a run and equal ignores it.
+	Sandbox *configs.Sandbox
+
+	// TempRoot is the run's temporary directory, which the child process
+	// must be able to read if the sandbox makes it run as another user.
+	TempRoot string
+
+	// SensitiveArgs, SensitiveEnv and SensitiveDir record which of the
+	// arguments, which of the variables in SetEnv and whether the working
+	// directory derive from sensitive values.
+	SensitiveArgs []bool
+	SensitiveEnv  map[string]bool
+	SensitiveDir  bool
+}
+
+// process evaluates the command's configuration using the values in the
+// given state to decide what child process to launch for the given call.
+//
+// The given positional arguments are those remaining from the call's
+// arguments after parsing any options declared for the command, which are
+// appended to the program given in "exec".
+func (n *commandExecNode) process(call *CommandCall, positional []string, state *states.State, scope *evalScope) (*process, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	cfg := n.Config
+	ctx := scope.EvalContext(state, n.Addr)
+
+	logging.Debug("evaluating command", "command", n.Addr)
+	start := time.Now()
+	defer func() {
+		logging.Debug("evaluated command", "command", n.Addr, "duration", time.Since(start), "errors", diags.HasErrors())
+	}()
+
+	execPrefix, moreDiags := evalStringList(cfg.Executable, ctx)
+	diags = diags.Append(moreDiags)
+	cmdLine, moreDiags := evalStringList(cfg.CommandLine, ctx)
+	diags = diags.Append(moreDiags)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	var args []string
+	var sensitiveArgs []bool
+	switch {
+	case execPrefix != nil && cmdLine != nil:
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Conflicting command arguments",
+			"A command may set either \"exec\" or \"cmdline\", but not both.",
+			cfg.DeclRange,
+		))
+		return nil, diags
+	case execPrefix != nil:
+		args = append(execPrefix, positional...)
+		sensitiveArgs = append(listElemsSensitive(cfg.Executable, len(execPrefix), state), make([]bool, len(positional))...)
+	case cmdLine != nil:
+		args = cmdLine
+		sensitiveArgs = listElemsSensitive(cfg.CommandLine, len(cmdLine), state)
+	default:
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Missing command arguments",
+			"A command must set either \"exec\" or \"cmdline\" to decide which program to run.",
+			cfg.DeclRange,
+		))
+		return nil, diags
+	}
+	if call.Program != nil {
+		args = call.Program
+		sensitiveArgs = make([]bool, len(args))
+	}
+	if len(args) == 0 {
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Empty command line",
+			"The command line for this command must include at least the program to run.",
+			cfg.DeclRange,
+		))
+		return nil, diags
+	}
+
+	inherit := true
+	if v, hclDiags := cfg.InheritEnvironment.Value(ctx); !v.IsNull() || hclDiags.HasErrors() {
+		hclDiags = gohcl.DecodeExpression(cfg.InheritEnvironment, ctx, &inherit)
+		diags = diags.Append(hclDiags)
+	}
+	var envMap map[string]string
+	if v, hclDiags := cfg.Environment.Value(ctx); !v.IsNull() || hclDiags.HasErrors() {
+		hclDiags = gohcl.DecodeExpression(cfg.Environment, ctx, &envMap)
+		diags = diags.Append(hclDiags)
+	}
+	dir := scope.WorkingDir
+	if v, hclDiags := cfg.WorkDir.Value(ctx); !v.IsNull() || hclDiags.HasErrors() {
+		hclDiags = gohcl.DecodeExpression(cfg.WorkDir, ctx, &dir)
+		diags = diags.Append(hclDiags)
+		// A relative work_dir is relative to the directory of the
+		// configuration that declared the command.
+		if !filepath.IsAbs(dir) {
+			dir = filepath.Join(scope.ConfigDir(n.Addr), dir)
+		}
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	sensitiveEnv := mapElemsSensitive(cfg.Environment, envMap, ctx, state)
+	if call.Program != nil && len(call.ProgramEnv) != 0 {
+		if envMap == nil {
+			envMap = make(map[string]string, len(call.ProgramEnv))
+		}
+		for _, kv := range call.ProgramEnv {
+			eq := strings.Index(kv, "=")
+			if eq <= 0 {
+				continue
+			}
+			envMap[kv[:eq]] = kv[eq+1:]
+			sensitiveEnv[kv[:eq]] = false
+		}
+	}
+
+	var env []string
+	if inherit {
+		for _, kv := range call.Environ {
+			eq := strings.Index(kv, "=")
+			if eq > 0 {
+				if _, override := envMap[kv[:eq]]; override {
+					continue
+				}
+			}
+			env = append(env, kv)
+		}
+	}
+	keys := make([]string, 0, len(envMap))
+	for k := range envMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		env = append(env, k+"="+envMap[k])
+	}
+
+	path := args[0]
+	if !strings.ContainsRune(path, filepath.Separator) {
+		var err error
+		path, err = exec.LookPath(path)
+		if err != nil {
+			diags = diags.Append(nvdiags.WithSource(
+				nvdiags.Error,
+				"Program not found",
+				fmt.Sprintf("Cannot find the program %q to run for this command: %s.", args[0], err),
+				cfg.DeclRange,
+			))
+			return nil, diags
+		}
+	}
+
+	return &process{
+		Path:          path,
+		Args:          args,
+		Env:           env,
+		Dir:           dir,
+		SetEnv:        envMap,
+		Termination:   cfg.Termination,
+		Sandbox:       cfg.Sandbox,
+		TempRoot:      scope.TempRoot,
+		SensitiveArgs: sensitiveArgs,
+		SensitiveEnv:  sensitiveEnv,
+		SensitiveDir:  exprSensitive(cfg.WorkDir, state),
+	}, diags
+}
+
+// evalStringList evaluates the given expression as a list of strings,
+// returning nil if the expression produces a null value.
+func evalStringList(expr hcl.Expression, ctx *hcl.EvalContext) ([]string, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	v, hclDiags := expr.Value(ctx)
+	diags = diags.Append(hclDiags)
+	if hclDiags.HasErrors() || v.IsNull() {
+		return nil, diags
+	}
+
+	v, err := convert.Convert(v, cty.List(cty.String))
+	if err == nil && !v.IsWhollyKnown() {
+		err = fmt.Errorf("value must be known")
+	}
+	if err != nil {
+		diags = diags.Append(&hcl.Diagnostic{
+			Severity:    hcl.DiagError,
+			Summary:     "Invalid command line",
+			Detail:      fmt.Sprintf("Must be a list of strings: %s.", err),
+			Subject:     expr.Range().Ptr(),
+			Expression:  expr,
+			EvalContext: ctx,
+		})
+		return nil, diags
+	}
+
+	ret := make([]string, 0, v.LengthInt())
+	for it := v.ElementIterator(); it.Next(); {
+		_, ev := it.Element()
+		if ev.IsNull() {
+			diags = diags.Append(&hcl.Diagnostic{
+				Severity:    hcl.DiagError,
+				Summary:     "Invalid command line",
+				Detail:      "Command line arguments must not be null.",
+				Subject:     expr.Range().Ptr(),
+				Expression:  expr,
+				EvalContext: ctx,
+			})
+			return nil, diags
+		}
+		ret = append(ret, ev.AsString())
+	}
+	return ret, diags
+}
@@ -1,20 +1,38 @@
 package runs
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"envy.pw/cli/internal/addrs"
 	"envy.pw/cli/internal/configs"
 	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/logging"
 	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/states"
+
+	"github.com/hashicorp/hcl2/hcldec"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type helperRunNode struct {
 	graphs.HelperNode
 	Config *configs.Helper
+	Type   helpers.Type
+
+	instance   helpers.Instance
+	lastConfig cty.Value
+	failedAt   time.Time
 }
 
-func makeHelperRunNode(addr addrs.Helper, rng nvdiags.SourceRange, cfg *configs.Config) (*helperRunNode, nvdiags.Diagnostics) {
+// refreshRetryDelay is the minimum time we'll wait before retrying after
+// a helper fails to refresh, so that a persistent failure doesn't cause us
+// to retry in a tight loop.
+const refreshRetryDelay = 30 * time.Second
+
+func makeHelperRunNode(addr addrs.Helper, rng nvdiags.SourceRange, cfg *configs.Config, types helpers.Types) (*helperRunNode, nvdiags.Diagnostics) {
 	var diags nvdiags.Diagnostics
 
 	hc, exists := cfg.Helpers[addr]
@@ -22,16 +40,135 @@ func makeHelperRunNode(addr addrs.Helper, rng nvdiags.SourceRange, cfg *configs.
 		diags = diags.Append(nvdiags.WithSource(
 			nvdiags.Error,
 			"Reference to undeclared helper",
-			fmt.Sprintf("No helper %q %q is declared in the configuration.", addr.Type, addr.Name),
+			undeclaredHelperDetail(addr, cfg),
 			rng,
 		))
 		return nil, diags
 	}
 
+	typ, exists := types[addr.Type]
+	if !exists {
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Unsupported helper type",
+			fmt.Sprintf("This version of Envy does not support helper type %q.", addr.Type),
+			hc.DeclRange,
+		))
+		return nil, diags
+	}
+
 	return &helperRunNode{
 		HelperNode: graphs.HelperNode{
 			Addr: addr,
 		},
 		Config: hc,
+		Type:   typ,
 	}, diags
 }
+
+func undeclaredHelperDetail(addr addrs.Helper, cfg *configs.Config) string {
+	if addr.Module == "" {
+		return fmt.Sprintf("No helper %q %q is declared in the configuration.%s", addr.Type, addr.Name, searchedLayersDetail(cfg))
+	}
+	if _, exists := cfg.Modules[addr.Module]; !exists {
+		return fmt.Sprintf("No module %q is declared in the configuration.%s", addr.Module, searchedLayersDetail(cfg))
+	}
+	return fmt.Sprintf("No helper %q %q is declared in module %q.", addr.Type, addr.Name, addr.Module)
+}
+
+func (n *helperRunNode) References() []configs.Reference {
+	return n.Config.AllReferences(n.Type.ConfigSpec())
+}
+
+// update evaluates the helper's configuration using the values already in
+// the given state, passes it to the helper instance (creating it first if
+// necessary) and records the result in the state.
+//
+// If the instance already exists and the configuration hasn't changed since
+// the last call then update does nothing, unless force is set. The result
+// is true if the helper's result value changed.
+func (n *helperRunNode) update(ctx context.Context, state *states.State, scope *evalScope, force bool) (bool, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	config, hclDiags := hcldec.Decode(n.Config.Body, n.Type.ConfigSpec(), scope.EvalContext(state, n.Addr))
+	diags = diags.Append(hclDiags)
+	if hclDiags.HasErrors() {
+		return false, diags
+	}
+
+	if n.instance == nil {
+		n.instance = n.Type.NewInstance(helpers.InstanceMeta{
+			Addr:      n.Addr,
+			TempDir:   scope.TempDir(n.Addr),
+			ConfigDir: scope.ConfigDir(n.Addr),
+			Functions: scope.Functions(n.Addr),
+		})
+	} else if !force && config.RawEquals(n.lastConfig) {
+		logging.Trace("helper configuration unchanged", "helper", n.Addr)
+		return false, diags
+	}
+	n.lastConfig = config
+
+	logging.Debug("updating helper", "helper", n.Addr, "forced", force)
+	start := time.Now()
+	result, err := n.instance.Update(ctx, config)
+	if err != nil {
+		n.failedAt = time.Now()
+		// The error message might include sensitive values, so we leave
+		// it for the diagnostic, which will be redacted.
+		logging.Debug("helper failed", "helper", n.Addr, "duration", time.Since(start))
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Helper failed",
+			fmt.Sprintf("The helper %s, from %s, failed: %s.", n.Addr, n.Config.Origin(), err),
+			n.Config.DeclRange,
+		))
+		return false, diags
+	}
+
+	changed := !state.HasValue(n.Addr) || !result.RawEquals(state.Value(n.Addr))
+	state.SetValue(n.Addr, result)
+	// Helpers typically produce credentials, so we conservatively treat
+	// their results as sensitive, except for any attributes that the helper
+	// type declares safe to show. The configuration can use the
+	// "nonsensitive" function to override this for specific expressions.
+	var nonsensitive []string
+	if t, ok := n.Type.(helpers.NonsensitiveResults); ok {
+		nonsensitive = t.NonsensitiveAttrs()
+	}
+	state.MarkSensitive(n.Addr, nonsensitive...)
+	logging.Debug("helper updated", "helper", n.Addr, "duration", time.Since(start), "changed", changed)
+	return changed, diags
+}
+
+// nextRefresh returns the time when the helper instance has asked to be
+// refreshed, or the zero time if it doesn't need refreshing.
+func (n *helperRunNode) nextRefresh() time.Time {
+	r, ok := n.instance.(helpers.Refresher)
+	if !ok {
+		return time.Time{}
+	}
+	next := r.NextRefresh()
+	if retry := n.failedAt.Add(refreshRetryDelay); !next.IsZero() && next.Before(retry) {
+		next = retry
+	}
+	return next
+}
+
+// close releases the helper instance, if it was created.
+func (n *helperRunNode) close() nvdiags.Diagnostics {
+	var diags nvdiags.Diagnostics
+	if n.instance == nil {
+		return diags
+	}
+	if err := n.instance.Close(); err != nil {
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Warning,
+			"Failed to clean up helper",
+			fmt.Sprintf("The helper %s failed to clean up after itself: %s.", n.Addr, err),
+			n.Config.DeclRange,
+		))
+	}
+	n.instance = nil
+	return diags
+}
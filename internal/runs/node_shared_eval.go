@@ -0,0 +1,69 @@
+package runs
+
+import (
+	"fmt"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/states"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
+type sharedObjectEvalNode struct {
+	graphs.SharedObjectNode
+	Config *configs.SharedObject
+}
+
+func makeSharedObjectEvalNode(addr addrs.SharedObject, rng nvdiags.SourceRange, cfg *configs.Config) (*sharedObjectEvalNode, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	so, exists := cfg.SharedObjects[addr]
+	if !exists {
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Reference to undeclared shared object",
+			fmt.Sprintf("No shared object %q is declared in the configuration.%s", addr.Name, searchedLayersDetail(cfg)),
+			rng,
+		))
+		return nil, diags
+	}
+
+	return &sharedObjectEvalNode{
+		SharedObjectNode: graphs.SharedObjectNode{
+			Addr: addr,
+		},
+		Config: so,
+	}, diags
+}
+
+func (n *sharedObjectEvalNode) References() []configs.Reference {
+	return n.Config.AllReferences()
+}
+
+// evaluate evaluates the shared object's attributes using the values already
+// in the given state, and then records the resulting object in the state.
+//
+// Shared objects are evaluated before any helpers start, so their
+// attributes can refer only to input variables, paths and the profile.
+func (n *sharedObjectEvalNode) evaluate(state *states.State, scope *evalScope) nvdiags.Diagnostics {
+	var diags nvdiags.Diagnostics
+
+	ctx := scope.EvalContext(state, n.Addr)
+	attrs := make(map[string]cty.Value, len(n.Config.Attributes))
+	for name, attr := range n.Config.Attributes {
+		val, hclDiags := attr.Expr.Value(ctx)
+		diags = diags.Append(hclDiags)
+		attrs[name] = val
+	}
+	if diags.HasErrors() {
+		return diags
+	}
+
+	logging.Debug("evaluated shared object", "shared", n.Addr)
+	state.SetValue(n.Addr, cty.ObjectVal(attrs))
+	return diags
+}
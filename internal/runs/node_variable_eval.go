@@ -0,0 +1,112 @@
+package runs
+
+import (
+	"fmt"
+
+	"envy.pw/cli/internal/addrs"
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/states"
+
+	"github.com/hashicorp/hcl2/ext/typeexpr"
+	"github.com/zclconf/go-cty/cty"
+	"github.com/zclconf/go-cty/cty/convert"
+)
+
+type variableEvalNode struct {
+	graphs.VariableNode
+	Config *configs.Variable
+}
+
+func makeVariableEvalNode(addr addrs.Variable, rng nvdiags.SourceRange, cfg *configs.Config) (*variableEvalNode, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+
+	vc, exists := cfg.Variables[addr]
+	if !exists {
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Reference to undeclared input variable",
+			fmt.Sprintf("No variable %q is declared in the configuration.%s", addr.Name, searchedLayersDetail(cfg)),
+			rng,
+		))
+		return nil, diags
+	}
+
+	return &variableEvalNode{
+		VariableNode: graphs.VariableNode{
+			Addr: addr,
+		},
+		Config: vc,
+	}, diags
+}
+
+// evaluate decides the variable's value, using the given input value if
+// any or otherwise the variable's default, checks it against the variable's
+// type constraint and validation rules, and then records it in the given
+// state.
+func (n *variableEvalNode) evaluate(input *inputValue, state *states.State, scope *evalScope) nvdiags.Diagnostics {
+	var diags nvdiags.Diagnostics
+	vc := n.Config
+
+	switch {
+	case input != nil:
+		raw, hclDiags := input.value(vc.Type)
+		diags = diags.Append(hclDiags)
+		if hclDiags.HasErrors() {
+			return diags
+		}
+		val, err := convert.Convert(raw, vc.Type)
+		if err != nil {
+			diags = diags.Append(nvdiags.WithSource(
+				nvdiags.Error,
+				"Invalid value for variable",
+				fmt.Sprintf("The value for variable %q from %s is not a valid %s: %s.", vc.Name, input.Source, typeexpr.TypeString(vc.Type), err),
+				vc.DeclRange,
+			))
+			return diags
+		}
+		logging.Debug("using input variable value", "var", n.Addr, "from", input.Source)
+		state.SetValue(n.Addr, val)
+	case !vc.Required():
+		logging.Debug("using input variable default", "var", n.Addr)
+		state.SetValue(n.Addr, vc.Default)
+	default:
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"No value for required variable",
+			fmt.Sprintf("The variable %q has no default value, so a value must be given using the --var-%s option, the environment variable %s%s, or a %s file.", vc.Name, vc.Name, VariableEnvPrefix, vc.Name, configs.VariableValuesFileName),
+			vc.DeclRange,
+		))
+		return diags
+	}
+
+	ctx := scope.EvalContext(state, n.Addr)
+	for _, vv := range vc.Validations {
+		result, hclDiags := vv.Condition.Value(ctx)
+		diags = diags.Append(hclDiags)
+		if hclDiags.HasErrors() {
+			continue
+		}
+		result, err := convert.Convert(result, cty.Bool)
+		if err != nil || result.IsNull() {
+			diags = diags.Append(nvdiags.WithSource(
+				nvdiags.Error,
+				"Invalid variable validation result",
+				"The validation condition must return either true or false.",
+				vv.Condition.Range(),
+			))
+			continue
+		}
+		if result.False() {
+			diags = diags.Append(nvdiags.WithSource(
+				nvdiags.Error,
+				"Invalid value for variable",
+				vv.ErrorMessage,
+				vv.Condition.Range(),
+			))
+		}
+	}
+	return diags
+}
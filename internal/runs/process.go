@@ -0,0 +1,217 @@
+package runs
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/logging"
+	"envy.pw/cli/internal/redact"
+)
+
+// child represents a running child process that was launched from a process.
+type child struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	term *configs.Termination
+
+	// started is when the child process was launched.
+	started time.Time
+
+	// group is true if the child is the leader of its own process group,
+	// in which case signals are sent to the whole group. foreground is
+	// true if that group was also made the terminal's foreground group.
+	group      bool
+	foreground bool
+}
+
+// start launches the process and returns immediately, without waiting for
+// it to exit.
+//
+// If tty is non-nil then the process is attached to that pseudo-terminal
+// instead of to envy's own standard input and output.
+func (p *process) start(tty *ptyTerminal) (*child, error) {
+	cmd := &exec.Cmd{
+		Path:   p.Path,
+		Args:   p.Args,
+		Env:    p.Env,
+		Dir:    p.Dir,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	term := p.Termination
+	if term == nil {
+		term = configs.DefaultTermination()
+	}
+	group := term.ProcessGroup
+	var foreground bool
+	switch {
+	case tty != nil:
+		tty.prepareChild()
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = tty.slave, tty.slave, tty.slave
+		cmd.SysProcAttr = ptyProcAttr()
+		group = true // a session leader is also a process group leader
+	case p.Sandbox != nil && p.Sandbox.NewSession:
+		cmd.SysProcAttr = newSessionAttr()
+		group = cmd.SysProcAttr != nil
+	case group:
+		cmd.SysProcAttr, foreground = processGroupAttr()
+	}
+
+	cred, err := p.credential()
+	if err != nil {
+		return nil, err
+	}
+	if cred != nil {
+		cmd.SysProcAttr = credentialAttr(cmd.SysProcAttr, cred)
+		if err := p.shareTempRoot(); err != nil {
+			return nil, fmt.Errorf("cannot give the sandbox user access to the temporary directory: %s", err)
+		}
+	}
+	if setup := p.childSetup(); setup != nil {
+		// A copy of envy runs in place of the program to carry out the
+		// setup, which then executes the program with the same arguments.
+		cmd.Path, cmd.Env, err = withChildSetup(setup, cmd.Env)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+	program := filepath.Base(p.Path)
+	if len(p.SensitiveArgs) > 0 && p.SensitiveArgs[0] {
+		program = redact.Placeholder
+	}
+	logging.Info("started child process", "pid", pid, "program", program)
+
+	c := &child{
+		cmd:        cmd,
+		done:       make(chan struct{}),
+		term:       term,
+		started:    time.Now(),
+		group:      group,
+		foreground: foreground,
+	}
+	go func() {
+		cmd.Wait()
+		logging.Info("child process exited", "pid", pid, "status", c.ExitStatus())
+		if c.foreground {
+			reclaimForeground()
+		}
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// equal returns true if the receiver and the other given process would
+// launch identical child processes.
+func (p *process) equal(other *process) bool {
+	return p.Path == other.Path && p.Dir == other.Dir && stringsEqual(p.Args, other.Args) && stringsEqual(p.Env, other.Env)
+}
+
+// Done returns a channel that is closed once the child process has exited.
+func (c *child) Done() <-chan struct{} {
+	return c.done
+}
+
+// Signal sends the given signal to the child process, or to its whole
+// process group if it has one.
+func (c *child) Signal(sig os.Signal) error {
+	logging.Debug("signalling child process", "pid", c.cmd.Process.Pid, "signal", sig, "group", c.group)
+	if c.group {
+		return signalGroup(c.cmd.Process.Pid, sig)
+	}
+	return c.cmd.Process.Signal(sig)
+}
+
+// Terminate asks the child process to exit using the signal from its
+// termination settings and then blocks until it does, killing it if it
+// hasn't exited by the end of the grace period.
+//
+// If the child has its own process group then any other processes still in
+// the group once the child has exited are killed, so that nothing the child
+// launched outlives it.
+func (c *child) Terminate() {
+	select {
+	case <-c.done:
+		return // already exited
+	default:
+	}
+	pid := c.cmd.Process.Pid
+	logging.Info("terminating child process", "pid", pid, "signal", c.term.Signal, "grace_period", c.term.GracePeriod)
+	if sig, ok := signalByName[c.term.Signal]; ok {
+		c.Signal(sig)
+	}
+
+	timer := time.NewTimer(c.term.GracePeriod)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+	case <-timer.C:
+		logging.Info("child process did not exit within its grace period, so killing it", "pid", pid)
+		c.Signal(os.Kill)
+		<-c.done
+	}
+
+	if c.group {
+		// This does nothing if the child's group has no other members,
+		// which is the usual case for a well-behaved child.
+		signalGroup(pid, os.Kill)
+	}
+}
+
+// ExitStatus returns the status that envy should itself exit with to reflect
+// the exit of the child process.
+//
+// ExitStatus may be called only after the channel returned by Done has been
+// closed.
+func (c *child) ExitStatus() int {
+	ps := c.cmd.ProcessState
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		// Conventional shell encoding of termination by signal.
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
+
+// interruptedByTerminal returns true if the child exited because of a signal
+// that a terminal sends when the user asks to interrupt or quit, either by
+// being killed by it or by catching it and then exiting with the status
+// that conventionally reports it.
+func (c *child) interruptedByTerminal() bool {
+	switch c.ExitStatus() {
+	case 128 + int(syscall.SIGINT), 128 + int(syscall.SIGQUIT):
+		return true
+	}
+	return false
+}
+
+// receivesTerminalInterrupts returns true if an interrupt that envy receives
+// was probably also delivered directly to the child, because it shares envy's
+// process group and that group is in the foreground of envy's terminal.
+//
+// An interrupt sent to envy alone, such as by a supervisor, can't be told
+// apart from one the terminal sent to the whole group, so an interrupt is
+// assumed to come from the terminal whenever it could have.
+func (c *child) receivesTerminalInterrupts() bool {
+	return !c.group && inTerminalForeground()
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,106 @@
+//go:build !windows
+// +build !windows
+
+package runs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"unsafe"
+
+	"envy.pw/cli/internal/logging"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// signalByName maps the signal names used in the configuration, as listed
+// in configs.SignalNames, to the signals they represent.
+var signalByName = map[string]os.Signal{
+	"SIGHUP":   syscall.SIGHUP,
+	"SIGINT":   syscall.SIGINT,
+	"SIGQUIT":  syscall.SIGQUIT,
+	"SIGTERM":  syscall.SIGTERM,
+	"SIGUSR1":  syscall.SIGUSR1,
+	"SIGUSR2":  syscall.SIGUSR2,
+	"SIGWINCH": syscall.SIGWINCH,
+}
+
+// processGroupAttr returns the attributes for starting a child process in
+// its own process group. If envy's standard input is a terminal then the
+// group is also placed in the foreground, so that the child can still read
+// from the terminal and receives the signals it generates, in which case
+// the second result is true.
+func processGroupAttr() (*syscall.SysProcAttr, bool) {
+	fd := int(os.Stdin.Fd())
+	if terminal.IsTerminal(fd) {
+		return &syscall.SysProcAttr{
+			Foreground: true,
+			Ctty:       fd,
+		}, true
+	}
+	return &syscall.SysProcAttr{
+		Setpgid: true,
+	}, false
+}
+
+// newSessionAttr returns the attributes for starting a child process as the
+// leader of a new session, which detaches it from envy's terminal.
+func newSessionAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setsid: true,
+	}
+}
+
+// credentialAttr adds the given user and group to the given attributes for
+// starting a child process, which may be nil.
+func credentialAttr(attr *syscall.SysProcAttr, cred *childCredential) *syscall.SysProcAttr {
+	if attr == nil {
+		attr = &syscall.SysProcAttr{}
+	}
+	attr.Credential = &syscall.Credential{
+		Uid:    cred.UID,
+		Gid:    cred.GID,
+		Groups: cred.Groups,
+	}
+	return attr
+}
+
+// signalGroup sends the given signal to all of the processes in the process
+// group whose leader has the given process ID.
+func signalGroup(pid int, sig os.Signal) error {
+	err := syscall.Kill(-pid, sig.(syscall.Signal))
+	if err == syscall.ESRCH {
+		return nil // the group has already exited
+	}
+	return err
+}
+
+// reclaimForeground makes envy's own process group the foreground group of
+// its terminal again, after a child process that was in the foreground has
+// exited.
+func reclaimForeground() {
+	// Changing the foreground group from a background group raises
+	// SIGTTOU, which would otherwise stop envy.
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	pgrp := syscall.Getpgrp()
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	if errno != 0 {
+		logging.Info("failed to reclaim the terminal", "error", errno)
+	}
+}
+
+// inTerminalForeground returns true if envy's standard input is a terminal
+// whose foreground process group is envy's own, in which case the interrupts
+// that the terminal generates are delivered to every process in envy's
+// group rather than only to envy.
+func inTerminalForeground() bool {
+	var pgrp int32
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp)))
+	if errno != 0 {
+		return false // not a terminal
+	}
+	return int(pgrp) == syscall.Getpgrp()
+}
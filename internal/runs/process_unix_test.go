@@ -0,0 +1,62 @@
+//go:build !windows
+// +build !windows
+
+package runs
+
+import (
+	"os"
+	"testing"
+)
+
+func TestChildInterruptedByTerminal(t *testing.T) {
+	tests := []struct {
+		script string
+		want   bool
+	}{
+		{"exit 0", false},
+		{"exit 1", false},
+		{"kill -INT $$", true},
+		{"kill -QUIT $$", true},
+		{"exit 130", true},
+		{"kill -TERM $$", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.script, func(t *testing.T) {
+			p := &process{
+				Path: "/bin/sh",
+				Args: []string{"sh", "-c", test.script},
+				Env:  os.Environ(),
+			}
+			c, err := p.start(nil)
+			if err != nil {
+				t.Fatalf("failed to start child: %s", err)
+			}
+			<-c.Done()
+			if got := c.interruptedByTerminal(); got != test.want {
+				t.Errorf("wrong result %t for exit status %d; want %t", got, c.ExitStatus(), test.want)
+			}
+		})
+	}
+}
+
+func TestChildReceivesTerminalInterrupts(t *testing.T) {
+	// Without a terminal, nothing but envy itself can be sent an
+	// interrupt, so it must never assume the child received one too.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+
+	for _, group := range []bool{false, true} {
+		c := &child{group: group}
+		if c.receivesTerminalInterrupts() {
+			t.Errorf("child with group=%t receives terminal interrupts without a terminal", group)
+		}
+	}
+}
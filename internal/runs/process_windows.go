@@ -0,0 +1,56 @@
+package runs
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// signalByName maps the signal names used in the configuration, as listed
+// in configs.SignalNames, to the signals they represent. Windows supports
+// only a subset of them.
+var signalByName = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// processGroupAttr returns the attributes for starting a child process in
+// its own process group, which is not supported on Windows.
+func processGroupAttr() (*syscall.SysProcAttr, bool) {
+	return nil, false
+}
+
+// newSessionAttr returns the attributes for starting a child process in a
+// new session, which is not supported on Windows.
+func newSessionAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// credentialAttr returns the given attributes unchanged, because envy never
+// runs as root on Windows and so always ignores the sandbox user and group.
+func credentialAttr(attr *syscall.SysProcAttr, cred *childCredential) *syscall.SysProcAttr {
+	return attr
+}
+
+// signalGroup sends the given signal to the process with the given process
+// ID, because Windows has no process groups that we could signal instead.
+func signalGroup(pid int, sig os.Signal) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return nil // the process has already exited
+	}
+	return p.Signal(sig)
+}
+
+// reclaimForeground does nothing on Windows, where child processes are
+// never placed in the foreground.
+func reclaimForeground() {}
+
+// inTerminalForeground returns true if envy's standard input is a console,
+// whose interrupts Windows delivers to every process attached to it.
+func inTerminalForeground() bool {
+	return terminal.IsTerminal(int(os.Stdin.Fd()))
+}
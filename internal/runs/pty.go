@@ -0,0 +1,143 @@
+package runs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"envy.pw/cli/internal/logging"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ptyDrainTimeout is how long we wait for the remaining output of the child
+// processes after the last one exits, in case some process they launched
+// keeps the pseudo-terminal open.
+const ptyDrainTimeout = time.Second
+
+// ptyTerminal is a pseudo-terminal that envy owns on behalf of a command's
+// child processes, relaying between it and envy's own terminal.
+//
+// A single ptyTerminal serves all of the child processes of a run, so that
+// the user's terminal is undisturbed when a child is restarted. Because envy
+// sits between the user and the child, it can also write its own messages
+// to the user's terminal without confusing the child.
+type ptyTerminal struct {
+	master, slave *os.File
+
+	// initial is the terminal state that each child process starts with.
+	initial *terminal.State
+
+	// restoreStdin is the state of envy's own terminal before it was put
+	// into raw mode, or nil if envy's standard input isn't a terminal.
+	restoreStdin *terminal.State
+
+	// outputLock serializes writes to envy's standard output, which both
+	// the child's output and envy's messages go to.
+	outputLock sync.Mutex
+	outputDone chan struct{}
+}
+
+// openPTYTerminal allocates a pseudo-terminal and starts relaying between it
+// and envy's standard input and output.
+//
+// If envy's standard input is a terminal then the pseudo-terminal starts
+// with the same settings and size, and envy's terminal is put into raw mode
+// so that everything the user types reaches the child unaltered.
+func openPTYTerminal() (*ptyTerminal, error) {
+	master, slave, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	t := &ptyTerminal{
+		master:     master,
+		slave:      slave,
+		outputDone: make(chan struct{}),
+	}
+
+	stdin := int(os.Stdin.Fd())
+	if terminal.IsTerminal(stdin) {
+		if st, err := terminal.GetState(stdin); err == nil {
+			terminal.Restore(int(slave.Fd()), st)
+		}
+		t.resize()
+		t.restoreStdin, err = terminal.MakeRaw(stdin)
+		if err != nil {
+			master.Close()
+			slave.Close()
+			return nil, fmt.Errorf("cannot put terminal into raw mode: %s", err)
+		}
+	}
+	t.initial, _ = terminal.GetState(int(slave.Fd()))
+
+	go t.relayOutput()
+	go func() {
+		// This ends when the first read after the master is closed fails,
+		// or when envy exits.
+		io.Copy(master, os.Stdin)
+	}()
+	return t, nil
+}
+
+// relayOutput copies the output of the child processes to envy's standard
+// output until the pseudo-terminal is closed.
+func (t *ptyTerminal) relayOutput() {
+	defer close(t.outputDone)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := t.master.Read(buf)
+		if n > 0 {
+			t.outputLock.Lock()
+			os.Stdout.Write(buf[:n])
+			t.outputLock.Unlock()
+		}
+		if err != nil {
+			// Reading fails once no process has the terminal open, which
+			// for us means that close has been called.
+			return
+		}
+	}
+}
+
+// prepareChild resets the pseudo-terminal to its initial settings, undoing
+// any changes made by an earlier child, such as disabling echo.
+func (t *ptyTerminal) prepareChild() {
+	if t.initial != nil {
+		terminal.Restore(int(t.slave.Fd()), t.initial)
+	}
+}
+
+// resize copies the size of envy's own terminal to the pseudo-terminal,
+// which in turn notifies the child process with SIGWINCH.
+func (t *ptyTerminal) resize() {
+	if err := copyWinsize(t.master, os.Stdin); err != nil {
+		logging.Debug("failed to resize terminal", "error", err)
+	}
+}
+
+// banner writes a message from envy to the user's terminal, on a line of
+// its own between the child's output.
+func (t *ptyTerminal) banner(msg string) {
+	t.outputLock.Lock()
+	defer t.outputLock.Unlock()
+	// The terminal is in raw mode, so we need explicit carriage returns.
+	fmt.Fprintf(os.Stdout, "\r\n\x1b[7m envy: %s \x1b[0m\r\n", msg)
+}
+
+// close waits for the remaining output of the child processes and then
+// releases the pseudo-terminal, returning envy's own terminal to the mode it
+// was in before.
+func (t *ptyTerminal) close() {
+	t.slave.Close()
+	select {
+	case <-t.outputDone:
+	case <-time.After(ptyDrainTimeout):
+		logging.Debug("terminal is still open after the command exited")
+	}
+	t.master.Close()
+	if t.restoreStdin != nil {
+		terminal.Restore(int(os.Stdin.Fd()), t.restoreStdin)
+	}
+}
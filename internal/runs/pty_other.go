@@ -0,0 +1,25 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package runs
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// openPTY always fails, because envy supports pseudo-terminals only on Linux
+// and macOS.
+func openPTY() (master, slave *os.File, err error) {
+	return nil, nil, fmt.Errorf("pseudo-terminals are not supported on %s", runtime.GOOS)
+}
+
+func ptyProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+func copyWinsize(dst, src *os.File) error {
+	return fmt.Errorf("pseudo-terminals are not supported on %s", runtime.GOOS)
+}
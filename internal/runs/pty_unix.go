@@ -0,0 +1,40 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package runs
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// ptyProcAttr returns the attributes for starting a child process attached
+// to a pseudo-terminal, which makes it the leader of a new session with the
+// pseudo-terminal as its controlling terminal.
+func ptyProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+		Ctty:    0, // the child's standard input
+	}
+}
+
+// copyWinsize sets the window size of the terminal dst to that of the
+// terminal src.
+func copyWinsize(dst, src *os.File) error {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if err := ioctl(src.Fd(), syscall.TIOCGWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+		return err
+	}
+	return ioctl(dst.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
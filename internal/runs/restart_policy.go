@@ -0,0 +1,70 @@
+package runs
+
+import (
+	"math/rand"
+	"time"
+
+	"envy.pw/cli/internal/configs"
+)
+
+// restartTracker applies a command's restart policy to the exits of its
+// child process, keeping count of the consecutive restarts.
+type restartTracker struct {
+	policy *configs.RestartPolicy
+	rand   *rand.Rand
+
+	// retries is the number of consecutive restarts since the policy was
+	// last reset.
+	retries int
+}
+
+func newRestartTracker(policy *configs.RestartPolicy) *restartTracker {
+	if policy == nil {
+		policy = configs.DefaultRestartPolicy()
+	}
+	return &restartTracker{
+		policy: policy,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// next decides whether to restart a child process that exited by itself with
+// the given status after running for the given duration, and if so returns
+// how long to wait before restarting it.
+//
+// If the process should not be restarted, the second result is false and
+// the third result reports whether that's because the policy ran out of
+// retries, rather than because its mode doesn't call for a restart.
+func (t *restartTracker) next(status int, ran time.Duration) (delay time.Duration, restart, gaveUp bool) {
+	p := t.policy
+	switch {
+	case p.Mode == configs.RestartNever:
+		return 0, false, false
+	case p.Mode == configs.RestartOnFailure && status == 0:
+		return 0, false, false
+	}
+
+	if ran >= p.ResetAfter {
+		t.retries = 0
+	}
+	if p.MaxRetries > 0 && t.retries >= p.MaxRetries {
+		return 0, false, true
+	}
+
+	backoff := p.Backoff
+	for i := 0; i < t.retries && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	t.retries++
+
+	// We choose a random delay between half of the backoff and all of it,
+	// so that processes that failed together don't restart in lockstep.
+	half := backoff / 2
+	if half > 0 {
+		delay = half + time.Duration(t.rand.Int63n(int64(backoff-half)+1))
+	}
+	return delay, true, false
+}
@@ -0,0 +1,120 @@
+package runs
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"envy.pw/cli/internal/configs"
+)
+
+func TestRestartTrackerNext(t *testing.T) {
+	type exit struct {
+		status int
+		ran    time.Duration
+
+		// The delay must be between min and max inclusive.
+		min, max time.Duration
+		restart  bool
+		gaveUp   bool
+	}
+	policy := func(mode configs.RestartMode, maxRetries int) *configs.RestartPolicy {
+		return &configs.RestartPolicy{
+			Mode:       mode,
+			MaxRetries: maxRetries,
+			Backoff:    time.Second,
+			MaxBackoff: 5 * time.Second,
+			ResetAfter: time.Minute,
+		}
+	}
+	tests := []struct {
+		name   string
+		policy *configs.RestartPolicy
+		exits  []exit
+	}{
+		{
+			"never",
+			policy(configs.RestartNever, 0),
+			[]exit{
+				{status: 1},
+				{status: 0},
+			},
+		},
+		{
+			"on failure",
+			policy(configs.RestartOnFailure, 0),
+			[]exit{
+				{status: 1, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 0},
+			},
+		},
+		{
+			"always",
+			policy(configs.RestartAlways, 0),
+			[]exit{
+				{status: 0, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 1, min: time.Second, max: 2 * time.Second, restart: true},
+			},
+		},
+		{
+			"backoff cap",
+			policy(configs.RestartAlways, 0),
+			[]exit{
+				{status: 1, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 1, min: time.Second, max: 2 * time.Second, restart: true},
+				{status: 1, min: 2 * time.Second, max: 4 * time.Second, restart: true},
+				{status: 1, min: 2500 * time.Millisecond, max: 5 * time.Second, restart: true},
+				{status: 1, min: 2500 * time.Millisecond, max: 5 * time.Second, restart: true},
+			},
+		},
+		{
+			"reset after",
+			policy(configs.RestartAlways, 2),
+			[]exit{
+				{status: 1, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 1, min: time.Second, max: 2 * time.Second, restart: true},
+				{status: 1, ran: time.Minute, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 1, ran: 59 * time.Second, min: time.Second, max: 2 * time.Second, restart: true},
+				{status: 1, gaveUp: true},
+			},
+		},
+		{
+			"max retries",
+			policy(configs.RestartOnFailure, 2),
+			[]exit{
+				{status: 1, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 1, min: time.Second, max: 2 * time.Second, restart: true},
+				{status: 1, gaveUp: true},
+				{status: 1, gaveUp: true},
+			},
+		},
+		{
+			"max retries success",
+			policy(configs.RestartOnFailure, 1),
+			[]exit{
+				{status: 1, min: 500 * time.Millisecond, max: time.Second, restart: true},
+				{status: 0},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// The delays are random, so we check them against several
+			// sequences of random numbers.
+			for seed := int64(0); seed < 20; seed++ {
+				tracker := newRestartTracker(test.policy)
+				tracker.rand = rand.New(rand.NewSource(seed))
+				for i, want := range test.exits {
+					delay, restart, gaveUp := tracker.next(want.status, want.ran)
+					if restart != want.restart || gaveUp != want.gaveUp {
+						t.Fatalf("seed %d, exit %d: got restart %t, gaveUp %t; want %t, %t", seed, i, restart, gaveUp, want.restart, want.gaveUp)
+					}
+					if delay < want.min || delay > want.max {
+						t.Fatalf("seed %d, exit %d: delay %s is not between %s and %s", seed, i, delay, want.min, want.max)
+					}
+				}
+			}
+		})
+	}
+}
@@ -3,11 +3,19 @@ package runs
 import (
 	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
 
 	"envy.pw/cli/internal/addrs"
 	"envy.pw/cli/internal/configs"
 	"envy.pw/cli/internal/graphs"
+	"envy.pw/cli/internal/helpers"
+	"envy.pw/cli/internal/logging"
 	"envy.pw/cli/internal/nvdiags"
+	"envy.pw/cli/internal/redact"
+	"envy.pw/cli/internal/states"
 )
 
 // CommandCall represents a call of a command defined in the configuration.
@@ -15,6 +23,36 @@ type CommandCall struct {
 	Addr    addrs.Command
 	Args    []string
 	Environ []string
+
+	// Variables are the values given for input variables on the command
+	// line, keyed by variable name.
+	Variables map[string]string
+
+	// Profile is the name of the profile selected for the call, which the
+	// caller must already have applied to the configuration using
+	// configs.Config.WithProfile. If empty, configs.DefaultProfileName is
+	// used.
+	Profile string
+
+	// WorkingDir is the directory that the command should run in if its
+	// configuration doesn't specify otherwise.
+	WorkingDir string
+
+	// Program, if set, replaces the program and arguments that the
+	// command's configuration would launch, while keeping all of the other
+	// settings for the child process, such as its environment. The
+	// configured program is still evaluated, so that the call fails in the
+	// same cases as when running the command normally.
+	//
+	// This is used to launch an interactive shell with the environment of
+	// a command.
+	Program []string
+
+	// ProgramEnv are environment variables, in the same form as Environ,
+	// that Program needs. They're set along with the command's own "env"
+	// variables, overriding them, so that the command's inherit_env setting
+	// doesn't remove them. They're ignored if Program isn't set.
+	ProgramEnv []string
 }
 
 // RunCommand creates all of the necessary context to run the given command
@@ -23,18 +61,344 @@ type CommandCall struct {
 // This function blocks until the command has terminated and all of its
 // associated helpers are cleaned up.
 func (r *Runner) RunCommand(ctx context.Context, call *CommandCall, cfg *configs.Config) (status int, diags nvdiags.Diagnostics) {
-	graph, moreDiags := graphForRunCommand(call, cfg)
+	start := time.Now()
+	run, moreDiags := r.prepareCommandRun(ctx, call, cfg)
 	diags = diags.Append(moreDiags)
+	if run != nil {
+		defer func() {
+			diags = diags.Append(run.close())
+		}()
+	}
+	// pids are the process IDs of the child processes we've launched, for
+	// the audit log, which we write before closing the run so that the
+	// helper instances can still report on themselves.
+	var pids []int
+	defer func() {
+		diags = diags.Append(r.recordRun(call, run, "", start, pids, status))
+	}()
 	if moreDiags.HasErrors() {
 		return 126, diags
 	}
+	proc, moreDiags := run.process()
+	diags = diags.Append(moreDiags)
+	if moreDiags.HasErrors() {
+		return 126, diags
+	}
+
+	var tty *ptyTerminal
+	if run.root.Config.PTY {
+		var err error
+		tty, err = openPTYTerminal()
+		if err != nil {
+			diags = diags.Append(nvdiags.WithSource(
+				nvdiags.Error,
+				"Failed to allocate terminal",
+				fmt.Sprintf("Could not create a pseudo-terminal for the command: %s.", err),
+				run.root.Config.DeclRange,
+			))
+			return 126, diags
+		}
+		defer tty.close()
+	}
+
+	child, err := proc.start(tty)
+	if err != nil {
+		diags = diags.Append(run.launchError(proc, err))
+		return 126, diags
+	}
+	pids = append(pids, child.cmd.Process.Pid)
+
+	term := run.root.Config.Termination
+	sigs := make(chan os.Signal, 1)
+	notify := notifySignals(term)
+	if winch, ok := signalByName["SIGWINCH"]; ok && tty != nil {
+		notify = append(notify, winch)
+	}
+	signal.Notify(sigs, notify...)
+	defer signal.Stop(sigs)
+
+	restarts := newRestartTracker(run.root.Config.RestartPolicy)
+	// restartTimer is set while the child has exited and we're waiting to
+	// restart it in accordance with the restart policy.
+	var restartTimer *time.Timer
+	// interrupted records that the child has received an interrupt from
+	// the terminal, so its exit is not a failure to restart after.
+	interrupted := false
+
+	for {
+		var refreshCh <-chan time.Time
+		var timer *time.Timer
+		if next := nextHelperRefresh(run.helpers); !next.IsZero() {
+			logging.Debug("scheduled helper refresh", "at", next)
+			timer = time.NewTimer(time.Until(next))
+			refreshCh = timer.C
+		}
+		doneCh := child.Done()
+		var restartCh <-chan time.Time
+		if restartTimer != nil {
+			doneCh = nil // the child has already exited
+			restartCh = restartTimer.C
+		}
+
+		action := configs.ProcessIgnore
+		// banner, if set, describes why we're taking the action, for
+		// commands running under a pseudo-terminal.
+		banner := ""
+		select {
+		case <-doneCh:
+			status := child.ExitStatus()
+			// In its own process group or under a pseudo-terminal, the
+			// child receives the terminal's interrupts instead of envy, so
+			// we can only tell from how it exited that the user stopped
+			// it, which must not count as a failure to restart after.
+			if interrupted || child.interruptedByTerminal() {
+				return status, diags
+			}
+			delay, restart, gaveUp := restarts.next(status, time.Since(child.started))
+			if gaveUp {
+				logging.Info("restart policy gave up", "status", status, "retries", restarts.retries)
+				diags = diags.Append(nvdiags.WithSource(
+					nvdiags.Warning,
+					"Command kept exiting",
+					fmt.Sprintf("The command exited with status %d after being restarted %d times, which is the limit set by its restart policy.", status, restarts.retries),
+					run.root.Config.RestartPolicy.DeclRange,
+				))
+			}
+			if !restart {
+				return status, diags
+			}
+			logging.Info("restarting child process", "status", status, "attempt", restarts.retries, "delay", delay)
+			restartTimer = time.NewTimer(delay)
+		case <-restartCh:
+			restartTimer = nil
+			action = configs.ProcessRestart
+		case <-ctx.Done():
+			child.Terminate()
+			return child.ExitStatus(), diags
+		case sig := <-sigs:
+			name := signalName(sig)
+			logging.Debug("received signal", "signal", name)
+			switch {
+			case name == "SIGWINCH" && tty != nil:
+				// Resizing the pseudo-terminal notifies the child.
+				tty.resize()
+			case term.Forwards(name):
+				child.Signal(sig)
+			case sig == os.Interrupt && child.receivesTerminalInterrupts():
+				// An interrupt from the terminal is delivered to the whole
+				// foreground process group, so the child has received it
+				// directly. We just need to make sure that envy itself
+				// survives long enough to clean up after the child exits.
+				// A child in a group or session of its own, or one started
+				// without a terminal, must be terminated like for any
+				// other signal, since nothing else will stop it.
+				interrupted = true
+				if restartTimer != nil {
+					return child.ExitStatus(), diags
+				}
+			default:
+				child.Terminate()
+				return child.ExitStatus(), diags
+			}
+		case <-refreshCh:
+			logging.Debug("refreshing helpers")
+			changed, moreDiags := refreshHelpers(ctx, run.helpers, run.state, run.scope)
+			moreDiags = run.redact(moreDiags)
+			diags = diags.Append(moreDiags)
+			if moreDiags.HasErrors() {
+				action = run.root.Config.OnError
+				banner = "credential refresh failed"
+				logging.Info("helper refresh failed", "action", action)
+				break
+			}
+			if !changed {
+				logging.Debug("helper results unchanged")
+				break
+			}
+			// The refresh may have written new files that a child running
+			// as the sandbox user couldn't otherwise read.
+			if err := proc.shareTempRoot(); err != nil {
+				diags = diags.Append(nvdiags.Sourceless(
+					nvdiags.Warning,
+					"Failed to share temporary directory",
+					fmt.Sprintf("Could not give the sandbox user access to the refreshed helper files: %s.", err),
+				))
+			}
+			newProc, moreDiags := run.process()
+			diags = diags.Append(moreDiags)
+			banner = "credentials refreshed"
+			switch {
+			case moreDiags.HasErrors():
+				action = run.root.Config.OnError
+				banner = "credential refresh failed"
+				logging.Info("command evaluation failed after refresh", "action", action)
+			case !newProc.equal(proc):
+				proc = newProc
+				action = run.root.Config.OnUpdate
+				if action == configs.ProcessSignal {
+					// A signal can't deliver new arguments or environment
+					// variables, so the child must be restarted to get
+					// them rather than keep running with stale ones.
+					action = configs.ProcessRestart
+					logging.Info("restarting instead of signalling, because the arguments or environment changed")
+				}
+				logging.Info("child process configuration changed", "action", action)
+			case run.root.Config.OnUpdate == configs.ProcessSignal:
+				// Helpers re-render their files as part of the refresh, so
+				// by now the child can reload them.
+				action = configs.ProcessSignal
+				logging.Info("helper results changed", "action", action)
+			default:
+				logging.Debug("helper results changed, but the child process is unaffected")
+			}
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if restartTimer != nil {
+			// The child has exited and is waiting to be restarted.
+			switch action {
+			case configs.ProcessRestart:
+				// The new helper results might fix whatever made the child
+				// exit, so there's no reason to wait out the backoff.
+				restartTimer.Stop()
+				restartTimer = nil
+			case configs.ProcessSignal:
+				// The restarted child will see the new results anyway.
+				action = configs.ProcessIgnore
+			}
+		}
+
+		if tty != nil && banner != "" {
+			switch action {
+			case configs.ProcessRestart:
+				tty.banner(banner + ", restarting")
+			case configs.ProcessTerminate:
+				tty.banner(banner + ", terminating")
+			case configs.ProcessSignal:
+				tty.banner(banner)
+			}
+		}
+
+		switch action {
+		case configs.ProcessRestart:
+			// Terminate does nothing if the child has already exited, as
+			// when we're restarting it under the restart policy.
+			child.Terminate()
+			interrupted = false
+			child, err = proc.start(tty)
+			if err != nil {
+				diags = diags.Append(run.launchError(proc, err))
+				return 126, diags
+			}
+			pids = append(pids, child.cmd.Process.Pid)
+		case configs.ProcessTerminate:
+			child.Terminate()
+			return child.ExitStatus(), diags
+		case configs.ProcessSignal:
+			name := run.root.Config.UpdateSignal
+			if sig, ok := signalByName[name]; ok {
+				child.Signal(sig)
+			} else {
+				logging.Info("signal is not supported on this platform", "signal", name)
+			}
+		}
+	}
+}
+
+// notifySignals returns the signals that envy must handle while running a
+// child process with the given termination settings: those that ask envy
+// to exit, which it handles by terminating the child first, and any others
+// that it forwards to the child.
+func notifySignals(term *configs.Termination) []os.Signal {
+	names := append([]string{"SIGINT", "SIGTERM", "SIGHUP"}, term.ForwardSignals...)
+	var sigs []os.Signal
+	for _, name := range names {
+		if sig, ok := signalByName[name]; ok {
+			sigs = append(sigs, sig)
+		}
+	}
+	return sigs
+}
+
+// signalName returns the name that the configuration uses for the given
+// signal, as listed in configs.SignalNames.
+func signalName(sig os.Signal) string {
+	for name, candidate := range signalByName {
+		if candidate == sig {
+			return name
+		}
+	}
+	return sig.String()
+}
 
-	fmt.Printf("TODO: execute run with graph:\n%s\n", graph.DebugString())
+// nextHelperRefresh returns the earliest time that any of the given helpers
+// has asked to be refreshed, or the zero time if none need refreshing.
+func nextHelperRefresh(nodes []*helperRunNode) time.Time {
+	var ret time.Time
+	for _, n := range nodes {
+		next := n.nextRefresh()
+		if !next.IsZero() && (ret.IsZero() || next.Before(ret)) {
+			ret = next
+		}
+	}
+	return ret
+}
 
-	return 0, diags
+// refreshHelpers refreshes any of the given helpers that are due for a
+// refresh, along with any helpers whose configuration changed as a result.
+//
+// The given nodes must be in dependency order. The result is true if any
+// helper's result value changed.
+func refreshHelpers(ctx context.Context, nodes []*helperRunNode, state *states.State, scope *evalScope) (bool, nvdiags.Diagnostics) {
+	var diags nvdiags.Diagnostics
+	now := time.Now()
+	anyChanged := false
+	for _, n := range nodes {
+		next := n.nextRefresh()
+		due := !next.IsZero() && !next.After(now)
+		changed, moreDiags := n.update(ctx, state, scope, due)
+		diags = diags.Append(moreDiags)
+		anyChanged = anyChanged || changed
+	}
+	return anyChanged, diags
 }
 
-func graphForRunCommand(call *CommandCall, cfg *configs.Config) (*graphs.Graph, nvdiags.Diagnostics) {
+// launchError returns the diagnostics for a failure to start the given
+// process, which hide the program's path if it derives from sensitive
+// values.
+func (run *commandRun) launchError(proc *process, err error) nvdiags.Diagnostics {
+	path := proc.Path
+	if proc.SensitiveArgs[0] {
+		path = redact.Placeholder
+	}
+	return run.redact(nvdiags.Diagnostics{
+		nvdiags.WithSource(
+			nvdiags.Error,
+			"Failed to launch command",
+			fmt.Sprintf("Could not start %s: %s.", path, err),
+			run.root.Config.DeclRange,
+		),
+	})
+}
+
+// searchedLayersDetail returns a paragraph to append to the detail of a
+// diagnostic about a missing object, listing where envy looked for it, or an
+// empty string if the configuration has only one layer.
+func searchedLayersDetail(cfg *configs.Config) string {
+	if len(cfg.Layers) < 2 {
+		return ""
+	}
+	var buf strings.Builder
+	buf.WriteString("\n\nEnvy searched the following configuration directories:")
+	for _, layer := range cfg.Layers {
+		fmt.Fprintf(&buf, "\n  - %s", layer)
+	}
+	return buf.String()
+}
+
+func graphForRunCommand(call *CommandCall, cfg *configs.Config, types helpers.Types) (*graphs.Graph, *commandExecNode, nvdiags.Diagnostics) {
 	var diags nvdiags.Diagnostics
 	g := graphs.NewGraph()
 
@@ -43,9 +407,9 @@ func graphForRunCommand(call *CommandCall, cfg *configs.Config) (*graphs.Graph,
 		diags = diags.Append(nvdiags.Sourceless(
 			nvdiags.Error,
 			"Command not found",
-			fmt.Sprintf("There is no command named %q defined in the configuration.", call.Addr.Name),
+			fmt.Sprintf("There is no command named %q defined in the configuration.%s", call.Addr.Name, searchedLayersDetail(cfg)),
 		))
-		return g, diags
+		return g, nil, diags
 	}
 
 	root := &commandExecNode{
@@ -54,16 +418,91 @@ func graphForRunCommand(call *CommandCall, cfg *configs.Config) (*graphs.Graph,
 		},
 		Config: cc,
 	}
-	moreDiags := g.AddWithReferents(root, func(referrer addrs.Referenceable, ref configs.Reference) (graphs.Node, nvdiags.Diagnostics) {
+	moreDiags := g.AddWithReferents(root, referentNodeFactory(cfg, types), policyCheck(cfg, call.Addr))
+	diags = diags.Append(moreDiags)
+
+	return g, root, diags
+}
+
+// policyCheck returns a function for use with graphs.AddWithReferents that
+// checks each reference against the configuration's policies, on behalf of
+// the object with the given address that is being run.
+func policyCheck(cfg *configs.Config, root addrs.Referenceable) graphs.ReferenceCheck {
+	return func(referrer addrs.Referenceable, ref configs.Reference) nvdiags.Diagnostics {
+		var diags nvdiags.Diagnostics
+		policy := cfg.CheckReference(root, referrer, ref.Addr)
+		if policy == nil {
+			return diags
+		}
+
+		var detail string
+		switch policy.Effect {
+		case configs.PolicyDeny:
+			detail = fmt.Sprintf("The policy %q declared at %s does not allow %s to refer to %s", policy.Name, policy.DeclRange, referrer, ref.Addr)
+		default:
+			detail = fmt.Sprintf("The policy %q declared at %s allows only certain objects to refer to %s, and %s is not one of them", policy.Name, policy.DeclRange, ref.Addr, referrer)
+		}
+		if referrer != root {
+			detail += fmt.Sprintf(" when running %s", root)
+		}
+		diags = diags.Append(nvdiags.WithSource(
+			nvdiags.Error,
+			"Reference not allowed by policy",
+			detail+".",
+			ref.SourceRange,
+		))
+		return diags
+	}
+}
+
+// referentNodeFactory returns a function for use with graphs.AddWithReferents
+// that creates the nodes for the objects referred to by the objects already
+// in a graph.
+func referentNodeFactory(cfg *configs.Config, types helpers.Types) func(addrs.Referenceable, configs.Reference) (graphs.Node, nvdiags.Diagnostics) {
+	return func(referrer addrs.Referenceable, ref configs.Reference) (graphs.Node, nvdiags.Diagnostics) {
 		var diags nvdiags.Diagnostics
 		switch addr := ref.Addr.(type) {
 
 		case addrs.Helper:
-			return makeHelperRunNode(addr, ref.SourceRange, cfg)
+			// Shared objects are evaluated before any helpers start, so
+			// they can't depend on helper results.
+			if _, isShared := referrer.(addrs.SharedObject); isShared {
+				diags = diags.Append(nvdiags.WithSource(
+					nvdiags.Error,
+					"Invalid reference",
+					fmt.Sprintf("Shared objects are evaluated before any helpers start, so %s cannot refer to %s.", referrer, addr),
+					ref.SourceRange,
+				))
+				return nil, diags
+			}
+			return makeHelperRunNode(addr, ref.SourceRange, cfg, types)
+
+		case addrs.Variable:
+			return makeVariableEvalNode(addr, ref.SourceRange, cfg)
+
+		case addrs.SharedObject:
+			return makeSharedObjectEvalNode(addr, ref.SourceRange, cfg)
 
 		case addrs.Path:
 			return nil, nil // No node required for a path
 
+		case addrs.Profile:
+			return nil, nil // No node required for the profile
+
+		case addrs.Args:
+			// The arguments belong to the command being run, and so only
+			// the command itself can refer to them.
+			if _, isCommand := referrer.(addrs.Command); !isCommand {
+				diags = diags.Append(nvdiags.WithSource(
+					nvdiags.Error,
+					"Invalid reference",
+					fmt.Sprintf("Only commands can refer to their arguments, so %s cannot refer to args.", referrer),
+					ref.SourceRange,
+				))
+				return nil, diags
+			}
+			return nil, nil // No node required for the arguments
+
 		default:
 			// This default error message is not actionable and lacks
 			// explanation, so we should try to catch most error cases
@@ -76,8 +515,5 @@ func graphForRunCommand(call *CommandCall, cfg *configs.Config) (*graphs.Graph,
 			))
 			return nil, diags
 		}
-	})
-	diags = diags.Append(moreDiags)
-
-	return g, diags
+	}
 }
@@ -1,10 +1,23 @@
 package runs
 
+import (
+	"envy.pw/cli/internal/audit"
+	"envy.pw/cli/internal/helpers"
+)
+
 // Runner is the main type in this package, used to run either individual
 // commands or a persistent background agent.
 type Runner struct {
+	helperTypes helpers.Types
+
+	// auditLog is where the runner records each command it runs, or nil
+	// if it keeps no audit log.
+	auditLog *audit.Log
 }
 
-func NewRunner() *Runner {
-	return &Runner{}
+// NewRunner creates a runner that can use helpers of the given types.
+func NewRunner(helperTypes helpers.Types) *Runner {
+	return &Runner{
+		helperTypes: helperTypes,
+	}
 }
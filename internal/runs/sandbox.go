@@ -0,0 +1,206 @@
+package runs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"envy.pw/cli/internal/logging"
+)
+
+// childSetupEnvVar is the environment variable through which envy passes
+// the sandbox settings for a child process to the copy of itself that it
+// launches in place of the child's program.
+//
+// Go gives us no way to run code in a child process between fork and exec,
+// so that copy of envy does the setup instead and then replaces itself with
+// the program, which therefore inherits the restrictions without envy ever
+// placing them on itself.
+const childSetupEnvVar = "ENVY_CHILD_SETUP"
+
+// childSetup is the setup that a copy of envy carries out before replacing
+// itself with a child process's program. It must not contain anything
+// sensitive, because other processes of the same user can read it from the
+// copy's environment until the program replaces it.
+//
+// The sandbox user and group aren't included, because os/exec switches to
+// them itself through SysProcAttr.Credential, which also works on systems
+// where a multi-threaded Go program can't call setuid.
+type childSetup struct {
+	// Path is the program to execute once the setup is done.
+	Path string `json:"path"`
+
+	Limits     map[string]uint64 `json:"limits,omitempty"`
+	Umask      *uint32           `json:"umask,omitempty"`
+	NoNewPrivs bool              `json:"no_new_privs,omitempty"`
+}
+
+// childCredential is the user and group that a child process runs as.
+type childCredential struct {
+	UID    uint32
+	GID    uint32
+	Groups []uint32
+}
+
+// childSetup returns the setup that must happen in the child process before
+// it executes the program, or nil if there is none.
+func (p *process) childSetup() *childSetup {
+	sb := p.Sandbox
+	if sb == nil {
+		return nil
+	}
+	setup := &childSetup{
+		Path:       p.Path,
+		Limits:     sb.Limits,
+		NoNewPrivs: sb.NoNewPrivs,
+	}
+	if sb.Umask != nil {
+		mask := uint32(*sb.Umask)
+		setup.Umask = &mask
+	}
+	if len(setup.Limits) == 0 && setup.Umask == nil && !setup.NoNewPrivs {
+		return nil
+	}
+	return setup
+}
+
+// credential returns the user and group that the child process must run as,
+// or nil if it runs as the same user as envy.
+func (p *process) credential() (*childCredential, error) {
+	sb := p.Sandbox
+	if sb == nil || (sb.User == "" && sb.Group == "") {
+		return nil, nil
+	}
+	if os.Geteuid() != 0 {
+		logging.Info("ignoring the sandbox user and group because envy isn't running as root")
+		return nil, nil
+	}
+	return lookupCredential(sb.User, sb.Group)
+}
+
+// shareTempRoot makes the user and group that the child process runs as,
+// if any, the owners of the run's temporary directory and everything in
+// it, so that the program can read the files that helpers write there.
+//
+// Helpers write new files when they refresh, so this must be repeated
+// whenever their results change.
+func (p *process) shareTempRoot() error {
+	cred, err := p.credential()
+	if err != nil || cred == nil || p.TempRoot == "" {
+		return err
+	}
+	return filepath.Walk(p.TempRoot, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, int(cred.UID), int(cred.GID))
+	})
+}
+
+// lookupCredential finds the IDs of the given user and group, either of
+// which may be empty to use the primary group of the user or to keep envy's
+// own user, respectively.
+func lookupCredential(userName, groupName string) (*childCredential, error) {
+	cred := &childCredential{
+		UID: uint32(os.Geteuid()),
+		GID: uint32(os.Getegid()),
+	}
+	if userName != "" {
+		u, err := user.Lookup(userName)
+		if err != nil {
+			u, err = user.LookupId(userName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot find user %q", userName)
+		}
+		cred.UID, err = parseID(u.Uid)
+		if err != nil {
+			return nil, fmt.Errorf("user %q has unsupported user ID %q", userName, u.Uid)
+		}
+		cred.GID, err = parseID(u.Gid)
+		if err != nil {
+			return nil, fmt.Errorf("user %q has unsupported group ID %q", userName, u.Gid)
+		}
+	}
+	if groupName != "" {
+		g, err := user.LookupGroup(groupName)
+		if err != nil {
+			g, err = user.LookupGroupId(groupName)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot find group %q", groupName)
+		}
+		cred.GID, err = parseID(g.Gid)
+		if err != nil {
+			return nil, fmt.Errorf("group %q has unsupported group ID %q", groupName, g.Gid)
+		}
+	}
+	// The child gets no supplementary groups, so that it doesn't keep any
+	// of root's.
+	cred.Groups = []uint32{cred.GID}
+	return cred, nil
+}
+
+func parseID(s string) (uint32, error) {
+	id, err := strconv.ParseUint(s, 10, 32)
+	return uint32(id), err
+}
+
+// RunChildSetup checks whether the current process is a copy of envy that
+// was launched to set up a sandboxed child process, and if so carries out
+// the setup and replaces the current process with the child's program.
+//
+// RunChildSetup must be called at the start of main, before doing anything
+// else. It returns only if the current process isn't such a copy, and
+// otherwise exits if the setup fails.
+func RunChildSetup() {
+	raw, ok := os.LookupEnv(childSetupEnvVar)
+	if !ok {
+		return
+	}
+
+	var setup childSetup
+	if err := json.Unmarshal([]byte(raw), &setup); err != nil {
+		fmt.Fprintf(os.Stderr, "envy: invalid %s: %s\n", childSetupEnvVar, err)
+		os.Exit(126)
+	}
+
+	// The program must not see the setup, or else it would repeat it if it
+	// happened to run envy itself.
+	env := make([]string, 0, len(os.Environ()))
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, childSetupEnvVar+"=") {
+			env = append(env, kv)
+		}
+	}
+
+	err := execChild(&setup, os.Args, env)
+	fmt.Fprintf(os.Stderr, "envy: cannot set up child process: %s\n", err)
+	os.Exit(126)
+}
+
+// withChildSetup prepares the given environment for launching a copy of
+// envy that carries out the given setup, returning the path to envy and the
+// environment to launch it with.
+func withChildSetup(setup *childSetup, env []string) (string, []string, error) {
+	if !childSetupSupported {
+		return "", nil, fmt.Errorf("sandbox settings other than new_session, user and group are supported only on Linux")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return "", nil, fmt.Errorf("cannot find the envy executable to set up the child process: %s", err)
+	}
+	raw, err := json.Marshal(setup)
+	if err != nil {
+		return "", nil, err
+	}
+	if env == nil {
+		env = os.Environ()
+	}
+	env = append(env[:len(env):len(env)], childSetupEnvVar+"="+string(raw))
+	return exe, env, nil
+}
@@ -0,0 +1,56 @@
+package runs
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+const childSetupSupported = true
+
+// limitResources maps the resource limit names used in the configuration, as
+// listed in configs.LimitNames, to the resources they limit.
+var limitResources = map[string]int{
+	"core_size":  syscall.RLIMIT_CORE,
+	"cpu_time":   syscall.RLIMIT_CPU,
+	"file_size":  syscall.RLIMIT_FSIZE,
+	"memory":     syscall.RLIMIT_AS,
+	"open_files": syscall.RLIMIT_NOFILE,
+	"stack_size": syscall.RLIMIT_STACK,
+}
+
+// prSetNoNewPrivs is PR_SET_NO_NEW_PRIVS from linux/prctl.h.
+const prSetNoNewPrivs = 38
+
+// execChild carries out the given setup on the current process and then
+// replaces it with the setup's program, returning only if that fails.
+func execChild(setup *childSetup, argv, env []string) error {
+	// The no_new_privs flag belongs to a single thread, so the thread that
+	// sets it must also be the one that executes the program.
+	runtime.LockOSThread()
+
+	// The process already runs as the sandbox user, if any, so it can only
+	// raise a hard limit if that user could.
+	for name, limit := range setup.Limits {
+		resource, ok := limitResources[name]
+		if !ok {
+			return fmt.Errorf("unsupported resource limit %q", name)
+		}
+		rlim := &syscall.Rlimit{Cur: limit, Max: limit}
+		if err := syscall.Setrlimit(resource, rlim); err != nil {
+			return fmt.Errorf("cannot set %s limit: %s", name, err)
+		}
+	}
+
+	if setup.Umask != nil {
+		syscall.Umask(int(*setup.Umask))
+	}
+
+	if setup.NoNewPrivs {
+		if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+			return fmt.Errorf("cannot set no_new_privs: %s", errno)
+		}
+	}
+
+	return syscall.Exec(setup.Path, argv, env)
+}
@@ -0,0 +1,101 @@
+package runs
+
+import (
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"envy.pw/cli/internal/configs"
+)
+
+// runSandboxed runs the given shell script as a child process with the
+// given sandbox settings, returning what it wrote to the file named by its
+// first argument, which is in the given temporary directory.
+func runSandboxed(t *testing.T, tempRoot string, sb *configs.Sandbox, script string) string {
+	t.Helper()
+	out := filepath.Join(tempRoot, "out")
+	p := &process{
+		Path:     "/bin/sh",
+		Args:     []string{"sh", "-c", script, "sh", out},
+		Env:      os.Environ(),
+		Dir:      tempRoot,
+		Sandbox:  sb,
+		TempRoot: tempRoot,
+	}
+	c, err := p.start(nil)
+	if err != nil {
+		t.Fatalf("failed to start child: %s", err)
+	}
+	<-c.Done()
+	if status := c.ExitStatus(); status != 0 {
+		t.Fatalf("child exited with status %d", status)
+	}
+	got, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read child output: %s", err)
+	}
+	return string(got)
+}
+
+func TestChildSetup(t *testing.T) {
+	tempRoot, err := ioutil.TempDir("", "envy-run-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempRoot)
+
+	umask := os.FileMode(0027)
+	sb := &configs.Sandbox{
+		Limits:     map[string]uint64{"open_files": 64},
+		Umask:      &umask,
+		NoNewPrivs: true,
+	}
+	if p := (&process{Path: "/bin/sh", Sandbox: sb}); p.childSetup() == nil {
+		t.Fatalf("no child setup for sandbox %#v", sb)
+	}
+
+	got := runSandboxed(t, tempRoot, sb, `{ umask; ulimit -n; grep NoNewPrivs /proc/self/status; printenv `+childSetupEnvVar+` || echo unset; } >"$1"`)
+	fields := strings.Fields(got)
+	want := []string{"0027", "64", "NoNewPrivs:", "1", "unset"}
+	if strings.Join(fields, " ") != strings.Join(want, " ") {
+		t.Errorf("wrong child state %q; want %q", fields, want)
+	}
+}
+
+func TestChildCredential(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching user requires running as root")
+	}
+	nobody, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("there is no \"nobody\" user to switch to")
+	}
+
+	tempRoot, err := ioutil.TempDir("", "envy-run-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempRoot)
+	// A file written by a helper, which the child must be able to read.
+	helperDir := filepath.Join(tempRoot, "helper.template.a")
+	if err := os.Mkdir(helperDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(helperDir, "rendered"), []byte("rendered"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	sb := &configs.Sandbox{User: "nobody"}
+	if p := (&process{Path: "/bin/sh", Sandbox: sb}); p.childSetup() != nil {
+		t.Errorf("switching user alone must not need a child setup")
+	}
+
+	got := runSandboxed(t, tempRoot, sb, `{ id -u; cat helper.template.a/rendered; } >"$1"`)
+	want := nobody.Uid + "\nrendered"
+	if strings.TrimSpace(got) != want {
+		t.Errorf("wrong child output %q; want %q", got, want)
+	}
+}
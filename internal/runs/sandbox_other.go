@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package runs
+
+import (
+	"fmt"
+	"runtime"
+)
+
+const childSetupSupported = false
+
+// execChild always fails, because envy supports setting up child processes
+// only on Linux.
+func execChild(setup *childSetup, argv, env []string) error {
+	return fmt.Errorf("setting up child processes is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,13 @@
+package runs
+
+import (
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// The sandbox tests launch copies of the test binary to set up child
+	// processes, in the same way that envy launches copies of itself.
+	RunChildSetup()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,108 @@
+package runs
+
+import (
+	"envy.pw/cli/internal/configs"
+	"envy.pw/cli/internal/states"
+
+	"github.com/hashicorp/hcl2/hcl"
+	"github.com/hashicorp/hcl2/hcl/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// exprSensitive returns true if the given expression refers to any object
+// whose value is marked as sensitive in the given state, other than to one
+// of its nonsensitive attributes or inside a call to the "nonsensitive"
+// function.
+func exprSensitive(expr hcl.Expression, state *states.State) bool {
+	if expr == nil {
+		return false
+	}
+
+	var exempt []hcl.Range
+	if syntaxExpr, ok := expr.(hclsyntax.Expression); ok {
+		hclsyntax.VisitAll(syntaxExpr, func(node hclsyntax.Node) hcl.Diagnostics {
+			if call, ok := node.(*hclsyntax.FunctionCallExpr); ok && call.Name == "nonsensitive" {
+				exempt = append(exempt, call.Range())
+			}
+			return nil
+		})
+	}
+
+Traversals:
+	for _, traversal := range expr.Variables() {
+		rng := traversal.SourceRange()
+		for _, exemptRng := range exempt {
+			if rng.Filename == exemptRng.Filename && exemptRng.ContainsOffset(rng.Start.Byte) && exemptRng.ContainsOffset(rng.End.Byte-1) {
+				continue Traversals
+			}
+		}
+		ref, remain, diags := configs.DecodeReference(traversal)
+		if diags.HasErrors() {
+			continue
+		}
+		if !state.Sensitive(ref.Addr) {
+			continue
+		}
+		if len(remain) != 0 {
+			if attr, ok := remain[0].(hcl.TraverseAttr); ok && !state.AttrSensitive(ref.Addr, attr.Name) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// listElemsSensitive returns a slice of length n recording which of the n
+// elements produced by the given list expression are sensitive.
+//
+// If the expression is a tuple constructor then each element is considered
+// separately. Otherwise, all elements are sensitive if any part of the
+// expression is.
+func listElemsSensitive(expr hcl.Expression, n int, state *states.State) []bool {
+	ret := make([]bool, n)
+	if tuple, ok := expr.(*hclsyntax.TupleConsExpr); ok && len(tuple.Exprs) == n {
+		for i, elemExpr := range tuple.Exprs {
+			ret[i] = exprSensitive(elemExpr, state)
+		}
+		return ret
+	}
+	if exprSensitive(expr, state) {
+		for i := range ret {
+			ret[i] = true
+		}
+	}
+	return ret
+}
+
+// mapElemsSensitive returns which of the keys in the given map, which was
+// produced by the given expression, have sensitive values.
+//
+// If the expression is an object constructor then each item is considered
+// separately. Otherwise, all elements are sensitive if any part of the
+// expression is.
+func mapElemsSensitive(expr hcl.Expression, m map[string]string, ctx *hcl.EvalContext, state *states.State) map[string]bool {
+	ret := make(map[string]bool, len(m))
+	if obj, ok := expr.(*hclsyntax.ObjectConsExpr); ok {
+		for _, item := range obj.Items {
+			k, diags := item.KeyExpr.Value(ctx)
+			if diags.HasErrors() || !k.IsKnown() || k.IsNull() || k.Type() != cty.String {
+				continue
+			}
+			ret[k.AsString()] = exprSensitive(item.ValueExpr, state)
+		}
+		// If the analysis above didn't cover every key, for whatever
+		// reason, then we'll conservatively treat the rest as sensitive.
+		for k := range m {
+			if _, ok := ret[k]; !ok {
+				ret[k] = true
+			}
+		}
+		return ret
+	}
+	all := exprSensitive(expr, state)
+	for k := range m {
+		ret[k] = all
+	}
+	return ret
+}
@@ -0,0 +1,5 @@
+auto_env {
+  env = {
+    GREETING = "hello"
+  }
+}
@@ -0,0 +1,8 @@
+command "clean" {
+  exec        = ["/bin/true"]
+  inherit_env = false
+  env = {
+    A = "1"
+    B = "2"
+  }
+}
@@ -0,0 +1,22 @@
+variable "account" {
+  default = "dev"
+}
+
+shared "settings" {
+  region  = "us-east-1"
+  account = var.account
+}
+
+command "show" {
+  exec = ["/bin/echo", shared.settings.region]
+  env = {
+    REGION  = shared.settings.region
+    ACCOUNT = shared.settings.account
+  }
+}
+
+profile "prod" {
+  shared "settings" {
+    region = "eu-west-1"
+  }
+}
@@ -0,0 +1,7 @@
+// Package secrets implements an encrypted, file-based store of named secret
+// values that Envy manages on behalf of the user.
+//
+// The store is a single file whose contents are encrypted using NaCl
+// secretbox, with the key either derived from a passphrase using scrypt or
+// read directly from a key file.
+package secrets // import "envy.pw/cli/internal/secrets"
@@ -0,0 +1,95 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Key represents the means by which a store is unlocked.
+//
+// Use either PassphraseKey or ReadKeyFile to obtain a Key.
+type Key interface {
+	// kdf returns the name of the key derivation function this key uses,
+	// which is recorded in the store file so that we can give a helpful
+	// error message if the user tries to unlock with the wrong kind of key.
+	kdf() string
+
+	// deriveKey produces the symmetric key to use for the given salt.
+	deriveKey(salt []byte) (*[keySize]byte, error)
+}
+
+const keySize = 32
+
+// Parameters for scrypt, as recommended for interactive logins in the
+// scrypt documentation.
+const (
+	scryptN = 32768
+	scryptR = 8
+	scryptP = 1
+)
+
+// PassphraseKey returns a Key that derives its symmetric key from the given
+// passphrase using scrypt.
+func PassphraseKey(passphrase string) Key {
+	return passphraseKey(passphrase)
+}
+
+// The names of the key derivation functions recorded in store files.
+const (
+	kdfScrypt = "scrypt" // for a passphrase
+	kdfNone   = "none"   // for a key file
+)
+
+type passphraseKey string
+
+func (k passphraseKey) kdf() string {
+	return kdfScrypt
+}
+
+func (k passphraseKey) deriveKey(salt []byte) (*[keySize]byte, error) {
+	raw, err := scrypt.Key([]byte(k), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	var ret [keySize]byte
+	copy(ret[:], raw)
+	return &ret, nil
+}
+
+// ReadKeyFile reads a key file from the given path and returns a Key that
+// uses the key it contains.
+//
+// A key file contains 32 random bytes encoded as base64, such as can be
+// produced by running "head -c32 /dev/urandom | base64".
+func ReadKeyFile(path string) (Key, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(src)))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s does not contain valid base64: %s", path, err)
+	}
+	if len(raw) != keySize {
+		return nil, fmt.Errorf("key file %s must contain exactly %d bytes of key material, but has %d", path, keySize, len(raw))
+	}
+	var ret fileKey
+	copy(ret[:], raw)
+	return &ret, nil
+}
+
+type fileKey [keySize]byte
+
+func (k *fileKey) kdf() string {
+	return kdfNone
+}
+
+func (k *fileKey) deriveKey(salt []byte) (*[keySize]byte, error) {
+	// A key file already contains a full-entropy key, so we use it directly.
+	ret := [keySize]byte(*k)
+	return &ret, nil
+}
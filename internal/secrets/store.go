@@ -0,0 +1,199 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+// ErrWrongKey is returned by Open if the given key cannot decrypt the
+// store.
+var ErrWrongKey = errors.New("incorrect passphrase or key for secret store")
+
+// Store is an in-memory representation of an encrypted secret store file.
+//
+// Changes made to a Store are not persisted until Save is called.
+type Store struct {
+	path   string
+	kdf    string
+	salt   []byte
+	key    *[keySize]byte
+	values map[string]string
+}
+
+// storeFile is the structure of the JSON document used to persist a store.
+// The secret values themselves are all inside the encrypted Box.
+type storeFile struct {
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Box     []byte `json:"box"`
+}
+
+const storeFileVersion = 1
+
+// Open reads and decrypts the store at the given path using the given key.
+//
+// If there is no file at the given path, Open returns an empty store that
+// will be encrypted with the given key when it is first saved.
+func Open(path string, key Key) (*Store, error) {
+	src, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		salt := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+			return nil, fmt.Errorf("failed to generate salt: %s", err)
+		}
+		k, err := key.deriveKey(salt)
+		if err != nil {
+			return nil, err
+		}
+		return &Store{
+			path:   path,
+			kdf:    key.kdf(),
+			salt:   salt,
+			key:    k,
+			values: map[string]string{},
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw storeFile
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, fmt.Errorf("invalid secret store file %s: %s", path, err)
+	}
+	if raw.Version != storeFileVersion {
+		return nil, fmt.Errorf("secret store file %s has unsupported format version %d", path, raw.Version)
+	}
+	if raw.KDF != key.kdf() {
+		switch raw.KDF {
+		case kdfScrypt:
+			return nil, fmt.Errorf("secret store %s must be unlocked with a passphrase, not a key file", path)
+		case kdfNone:
+			return nil, fmt.Errorf("secret store %s must be unlocked with a key file, not a passphrase", path)
+		default:
+			return nil, fmt.Errorf("secret store file %s uses unsupported key derivation function %q", path, raw.KDF)
+		}
+	}
+	if len(raw.Nonce) != 24 {
+		return nil, fmt.Errorf("invalid secret store file %s: incorrect nonce length", path)
+	}
+
+	k, err := key.deriveKey(raw.Salt)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], raw.Nonce)
+	plain, ok := secretbox.Open(nil, raw.Box, &nonce, k)
+	if !ok {
+		return nil, ErrWrongKey
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal(plain, &values); err != nil {
+		return nil, fmt.Errorf("invalid secret store file %s: %s", path, err)
+	}
+
+	return &Store{
+		path:   path,
+		kdf:    raw.KDF,
+		salt:   raw.Salt,
+		key:    k,
+		values: values,
+	}, nil
+}
+
+// Exists returns true if there is already a secret store file at the
+// given path.
+func Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Get returns the value of the secret with the given name, and a boolean
+// that is false if there is no such secret.
+func (s *Store) Get(name string) (string, bool) {
+	v, ok := s.values[name]
+	return v, ok
+}
+
+// Set creates or replaces the secret with the given name.
+func (s *Store) Set(name, value string) {
+	s.values[name] = value
+}
+
+// Remove deletes the secret with the given name, returning false if there
+// was no such secret.
+func (s *Store) Remove(name string) bool {
+	_, ok := s.values[name]
+	delete(s.values, name)
+	return ok
+}
+
+// Names returns the names of all of the secrets in the store, in
+// lexicographical order.
+func (s *Store) Names() []string {
+	ret := make([]string, 0, len(s.values))
+	for name := range s.values {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Save encrypts the current contents of the store and writes them to disk,
+// replacing the previous file, if any.
+func (s *Store) Save() error {
+	plain, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return fmt.Errorf("failed to generate nonce: %s", err)
+	}
+	raw := storeFile{
+		Version: storeFileVersion,
+		KDF:     s.kdf,
+		Salt:    s.salt,
+		Nonce:   nonce[:],
+		Box:     secretbox.Seal(nil, plain, &nonce, s.key),
+	}
+	src, err := json.MarshalIndent(&raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	// We write to a temporary file and then rename it into place so that
+	// a failure part way through can't leave the store corrupted.
+	f, err := ioutil.TempFile(dir, ".secrets-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secrets.json")
+
+	store, err := Open(path, PassphraseKey("hunter2"))
+	if err != nil {
+		t.Fatalf("unexpected error creating store: %s", err)
+	}
+	store.Set("github", "abc123")
+	store.Set("aws", "def456")
+	if err := store.Save(); err != nil {
+		t.Fatalf("unexpected error saving store: %s", err)
+	}
+
+	t.Run("reopen", func(t *testing.T) {
+		store, err := Open(path, PassphraseKey("hunter2"))
+		if err != nil {
+			t.Fatalf("unexpected error opening store: %s", err)
+		}
+		if got, want := store.Names(), []string{"aws", "github"}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("wrong names %#v; want %#v", got, want)
+		}
+		if got, _ := store.Get("github"); got != "abc123" {
+			t.Errorf("wrong value %q; want %q", got, "abc123")
+		}
+	})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		_, err := Open(path, PassphraseKey("hunter3"))
+		if err != ErrWrongKey {
+			t.Errorf("wrong error %v; want %v", err, ErrWrongKey)
+		}
+	})
+	t.Run("key file instead of passphrase", func(t *testing.T) {
+		keyPath := filepath.Join(dir, "key")
+		err := ioutil.WriteFile(keyPath, []byte("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ReadKeyFile(keyPath)
+		if err != nil {
+			t.Fatalf("unexpected error reading key file: %s", err)
+		}
+		_, err = Open(path, key)
+		if err == nil {
+			t.Errorf("unexpected success opening passphrase store with key file")
+		} else if !strings.Contains(err.Error(), "must be unlocked with a passphrase") {
+			t.Errorf("wrong error: %s", err)
+		}
+	})
+	t.Run("unsupported KDF", func(t *testing.T) {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var raw storeFile
+		if err := json.Unmarshal(src, &raw); err != nil {
+			t.Fatal(err)
+		}
+		raw.KDF = "argon2id"
+		src, err = json.Marshal(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		badPath := filepath.Join(dir, "unsupported.json")
+		if err := ioutil.WriteFile(badPath, src, 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = Open(badPath, PassphraseKey("hunter2"))
+		if err == nil {
+			t.Fatalf("unexpected success opening store with unsupported KDF")
+		}
+		if !strings.Contains(err.Error(), `unsupported key derivation function "argon2id"`) {
+			t.Errorf("wrong error: %s", err)
+		}
+	})
+}
@@ -1,6 +1,174 @@
 package states
 
+import (
+	"sync"
+
+	"envy.pw/cli/internal/addrs"
+
+	"github.com/zclconf/go-cty/cty"
+)
+
 // State encapsulates all of the state information that needs to propagate
 // between graph nodes in a flow.
+//
+// Operations on states are concurrency-safe.
 type State struct {
+	values map[addrs.Referenceable]cty.Value
+
+	// sensitive records the addresses whose values are sensitive, each
+	// with the set of names of any of its attributes that are nonetheless
+	// not sensitive.
+	sensitive map[addrs.Referenceable]map[string]struct{}
+
+	// declassified are the strings found in values that the configuration
+	// explicitly declared not to be sensitive.
+	declassified map[string]struct{}
+
+	l sync.RWMutex
+}
+
+// NewState returns a new, empty state.
+func NewState() *State {
+	return &State{
+		values:       make(map[addrs.Referenceable]cty.Value),
+		sensitive:    make(map[addrs.Referenceable]map[string]struct{}),
+		declassified: make(map[string]struct{}),
+	}
+}
+
+// Value returns the value most recently recorded for the given address, or
+// cty.NilVal if no value has been recorded.
+func (s *State) Value(addr addrs.Referenceable) cty.Value {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.values[addr]
+}
+
+// HasValue returns true if a value has been recorded for the given address.
+func (s *State) HasValue(addr addrs.Referenceable) bool {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	_, ok := s.values[addr]
+	return ok
+}
+
+// SetValue records the value for the given address, replacing any value
+// previously recorded.
+func (s *State) SetValue(addr addrs.Referenceable, v cty.Value) {
+	s.l.Lock()
+	s.values[addr] = v
+	s.l.Unlock()
+}
+
+// Values returns a snapshot of all of the values currently recorded in the
+// state.
+func (s *State) Values() map[addrs.Referenceable]cty.Value {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	ret := make(map[addrs.Referenceable]cty.Value, len(s.values))
+	for k, v := range s.values {
+		ret[k] = v
+	}
+	return ret
+}
+
+// MarkSensitive records that the value for the given address is sensitive,
+// and so must not be shown in any output, except for any of its attributes
+// named in nonsensitiveAttrs.
+func (s *State) MarkSensitive(addr addrs.Referenceable, nonsensitiveAttrs ...string) {
+	exempt := make(map[string]struct{}, len(nonsensitiveAttrs))
+	for _, name := range nonsensitiveAttrs {
+		exempt[name] = struct{}{}
+	}
+	s.l.Lock()
+	s.sensitive[addr] = exempt
+	s.l.Unlock()
+}
+
+// Sensitive returns true if any part of the value for the given address was
+// marked as sensitive.
+func (s *State) Sensitive(addr addrs.Referenceable) bool {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	_, ok := s.sensitive[addr]
+	return ok
+}
+
+// AttrSensitive returns true if the attribute with the given name of the
+// value for the given address is sensitive.
+func (s *State) AttrSensitive(addr addrs.Referenceable, name string) bool {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	exempt, ok := s.sensitive[addr]
+	if !ok {
+		return false
+	}
+	_, isExempt := exempt[name]
+	return !isExempt
+}
+
+// Declassify records that the strings within the given value were declared
+// not to be sensitive by the configuration, even if they derive from
+// sensitive values.
+func (s *State) Declassify(v cty.Value) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	cty.Walk(v, func(path cty.Path, v cty.Value) (bool, error) {
+		if !v.IsKnown() || v.IsNull() {
+			return false, nil
+		}
+		if v.Type() == cty.String {
+			s.declassified[v.AsString()] = struct{}{}
+		}
+		return true, nil
+	})
+}
+
+// SensitiveValues returns a snapshot of the sensitive parts of the values
+// whose addresses were marked as sensitive.
+func (s *State) SensitiveValues() []cty.Value {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	ret := make([]cty.Value, 0, len(s.sensitive))
+	for addr, exempt := range s.sensitive {
+		v, ok := s.values[addr]
+		if !ok {
+			continue
+		}
+		if len(exempt) == 0 || !v.Type().IsObjectType() || !v.IsKnown() || v.IsNull() {
+			ret = append(ret, v)
+			continue
+		}
+		for name, attr := range v.AsValueMap() {
+			if _, isExempt := exempt[name]; !isExempt {
+				ret = append(ret, attr)
+			}
+		}
+	}
+	return ret
+}
+
+// NonsensitiveValues returns a snapshot of the values that are known not to
+// be sensitive even though they may also appear within sensitive values:
+// the nonsensitive attributes of values marked as sensitive, and the
+// strings passed to Declassify.
+func (s *State) NonsensitiveValues() []cty.Value {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	var ret []cty.Value
+	for addr, exempt := range s.sensitive {
+		v, ok := s.values[addr]
+		if !ok || len(exempt) == 0 || !v.Type().IsObjectType() || !v.IsKnown() || v.IsNull() {
+			continue
+		}
+		for name := range exempt {
+			if v.Type().HasAttribute(name) {
+				ret = append(ret, v.GetAttr(name))
+			}
+		}
+	}
+	for str := range s.declassified {
+		ret = append(ret, cty.StringVal(str))
+	}
+	return ret
 }
@@ -0,0 +1,148 @@
+// Package textdiff produces line-based differences between two texts in
+// the unified diff format, for showing users how envy would change a file.
+package textdiff // import "envy.pw/cli/internal/textdiff"
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around each change.
+const contextLines = 3
+
+// op is a single step in transforming the old text into the new: keeping a
+// line (' '), deleting one ('-'), or inserting one ('+'). a and b are the
+// indices of the old and new lines at the point where the step applies.
+type op struct {
+	kind byte
+	a, b int
+}
+
+// Unified returns the differences between old and new in the unified diff
+// format, labelling them with the given names, or nil if they are equal.
+//
+// The differences are found using a longest common subsequence of lines,
+// which takes time and memory proportional to the product of the numbers
+// of lines, so this is intended only for modestly-sized files.
+func Unified(oldName, newName string, old, new []byte) []byte {
+	if bytes.Equal(old, new) {
+		return nil
+	}
+	a := splitLines(string(old))
+	b := splitLines(string(new))
+	ops := diff(a, b)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for start := 0; start < len(ops); {
+		// Find the next change, and then the extent of the hunk around it,
+		// which takes in any further changes whose context would overlap.
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			break
+		}
+		last := first
+		for i := first + 1; i < len(ops) && i <= last+2*contextLines; i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			}
+		}
+		from := first - contextLines
+		if from < start {
+			from = start
+		}
+		to := last + contextLines + 1
+		if to > len(ops) {
+			to = len(ops)
+		}
+
+		var aCount, bCount int
+		for _, o := range ops[from:to] {
+			if o.kind != '+' {
+				aCount++
+			}
+			if o.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n", hunkRange(ops[from].a, aCount), hunkRange(ops[from].b, bCount))
+		for _, o := range ops[from:to] {
+			line := ""
+			if o.kind == '+' {
+				line = b[o.b]
+			} else {
+				line = a[o.a]
+			}
+			buf.WriteByte(o.kind)
+			buf.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				buf.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+	return buf.Bytes()
+}
+
+// hunkRange formats the start line and count of one side of a hunk. By
+// convention, an empty range starts at the line before the hunk.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// splitLines splits text into lines, each including its newline except
+// possibly the last.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diff returns the steps that transform the lines a into the lines b.
+func diff(a, b []string) []op {
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', i, j})
+			j++
+		}
+	}
+	return ops
+}
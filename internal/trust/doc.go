@@ -0,0 +1,10 @@
+// Package trust decides whether envy may load a project configuration
+// directory, which could otherwise make envy run arbitrary commands with
+// the user's credentials just because the user changed into a directory
+// containing it.
+//
+// A directory is trusted either because the user has reviewed it and
+// recorded a digest of its contents in their trust store, much like
+// direnv's "allow", or because it carries a detached ed25519 signature by
+// a key that the user has recorded as trusted.
+package trust // import "envy.pw/cli/internal/trust"
@@ -0,0 +1,86 @@
+package trust
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Manifest returns a canonical listing of the files in the given directory
+// and its subdirectories, which is what a signature covers.
+//
+// Each line of the manifest has the hex-encoded SHA-256 hash of a file's
+// contents, two spaces, and the file's slash-separated path relative to the
+// directory, in the same format as the output of sha256sum. The lines are
+// sorted by path. The signature file itself is not included, and nor is
+// anything other than a regular file or a symlink to one.
+func Manifest(dir string) ([]byte, error) {
+	type entry struct {
+		path string
+		hash string
+	}
+	var entries []entry
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if rel == SignatureFileName {
+			return nil
+		}
+		// The configuration loader follows symlinks, so we do too.
+		if info.Mode()&os.ModeSymlink != 0 {
+			info, err = os.Stat(path)
+			if err != nil {
+				return err
+			}
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(src)
+		entries = append(entries, entry{
+			path: filepath.ToSlash(rel),
+			hash: hex.EncodeToString(sum[:]),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	var buf bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&buf, "%s  %s\n", e.hash, e.path)
+	}
+	return buf.Bytes(), nil
+}
+
+// Digest returns the hex-encoded SHA-256 hash of the manifest of the given
+// directory, which changes whenever any of the files in it change.
+func Digest(dir string) (string, error) {
+	manifest, err := Manifest(dir)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(manifest)
+	return hex.EncodeToString(sum[:]), nil
+}
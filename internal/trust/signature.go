@@ -0,0 +1,158 @@
+package trust
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// SignatureFileName is the name of the file in a configuration directory
+// that holds the directory's signature. It isn't a configuration file, so
+// the configuration loader ignores it.
+const SignatureFileName = "envy.sig"
+
+// ErrNotSigned is returned by VerifySignature if the directory has no
+// signature file.
+var ErrNotSigned = errors.New("directory is not signed")
+
+const signatureFileVersion = 1
+
+// signaturePrefix is prepended to the manifest to produce the message that
+// is signed, so that a signature can't be mistaken for one made for some
+// other purpose with the same key.
+const signaturePrefix = "envy config signature v1\n"
+
+// signatureFile is the JSON structure of a signature file.
+type signatureFile struct {
+	Version   int    `json:"version"`
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"`
+}
+
+// Sign signs the current contents of the given directory with the given
+// key, writing the signature file into the directory and replacing any
+// existing signature.
+func Sign(dir string, key ed25519.PrivateKey) error {
+	manifest, err := Manifest(dir)
+	if err != nil {
+		return err
+	}
+	raw := signatureFile{
+		Version:   signatureFileVersion,
+		PublicKey: key.Public().(ed25519.PublicKey),
+		Signature: ed25519.Sign(key, append([]byte(signaturePrefix), manifest...)),
+	}
+	src, err := json.MarshalIndent(&raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, SignatureFileName), append(src, '\n'), 0644)
+}
+
+// VerifySignature checks the signature file in the given directory against
+// the directory's current contents, returning the public key that made the
+// signature if it is valid.
+//
+// The result is ErrNotSigned if there is no signature file. A valid
+// signature shows only that the directory hasn't changed since the holder
+// of the returned key signed it; the caller must decide whether to trust
+// that key.
+func VerifySignature(dir string) (ed25519.PublicKey, error) {
+	path := filepath.Join(dir, SignatureFileName)
+	src, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotSigned
+	}
+	if err != nil {
+		return nil, err
+	}
+	var raw signatureFile
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, fmt.Errorf("invalid signature file %s: %s", path, err)
+	}
+	if raw.Version != signatureFileVersion {
+		return nil, fmt.Errorf("signature file %s has unsupported version %d", path, raw.Version)
+	}
+	if len(raw.PublicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid signature file %s: incorrect public key length", path)
+	}
+
+	manifest, err := Manifest(dir)
+	if err != nil {
+		return nil, err
+	}
+	pub := ed25519.PublicKey(raw.PublicKey)
+	if !ed25519.Verify(pub, append([]byte(signaturePrefix), manifest...), raw.Signature) {
+		return nil, fmt.Errorf("the signature in %s does not match the directory's contents", path)
+	}
+	return pub, nil
+}
+
+// EncodePublicKey returns the base64 form of a public key that users see
+// and give to "envy trust --key".
+func EncodePublicKey(pub ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(pub)
+}
+
+// ParsePublicKey parses a public key in the form that EncodePublicKey
+// produces.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("public key is not valid base64: %s", err)
+	}
+	if len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be exactly %d bytes, but has %d", ed25519.PublicKeySize, len(raw))
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// ReadKeyFile reads a signing key file from the given path. A key file
+// contains a 32-byte ed25519 seed encoded as base64.
+func ReadKeyFile(path string) (ed25519.PrivateKey, error) {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(src)))
+	if err != nil {
+		return nil, fmt.Errorf("key file %s does not contain valid base64: %s", path, err)
+	}
+	if len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("key file %s must contain exactly %d bytes of key material, but has %d", path, ed25519.SeedSize, len(raw))
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// GenerateKeyFile creates a new signing key and writes it to a key file at
+// the given path, which must not already exist.
+func GenerateKeyFile(path string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %s", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	_, err = fmt.Fprintln(f, base64.StdEncoding.EncodeToString(key.Seed()))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,212 @@
+package trust
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// Store is the user's record of the directories and signing keys that they
+// trust.
+type Store struct {
+	path string
+
+	// dirs maps the absolute path of each trusted directory to the Digest
+	// of its contents at the time it was trusted.
+	dirs map[string]string
+
+	// keys are the trusted public keys, in the form EncodePublicKey
+	// produces.
+	keys map[string]struct{}
+}
+
+const storeFileVersion = 1
+
+// storeFile is the JSON structure of a trust store file.
+type storeFile struct {
+	Version int               `json:"version"`
+	Dirs    map[string]string `json:"dirs,omitempty"`
+	Keys    []string          `json:"keys,omitempty"`
+}
+
+// UntrustedError is the error returned by Store.Check for a directory that
+// isn't trusted.
+type UntrustedError struct {
+	Dir string
+
+	// Reason completes a sentence starting with the directory, such as
+	// "has not been trusted".
+	Reason string
+}
+
+func (e *UntrustedError) Error() string {
+	return fmt.Sprintf("%s %s", e.Dir, e.Reason)
+}
+
+// OpenStore reads the trust store file at the given path. If the file
+// doesn't exist yet then the result is an empty store, which will create
+// the file when saved.
+func OpenStore(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		dirs: map[string]string{},
+		keys: map[string]struct{}{},
+	}
+	src, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var raw storeFile
+	if err := json.Unmarshal(src, &raw); err != nil {
+		return nil, fmt.Errorf("invalid trust store file %s: %s", path, err)
+	}
+	if raw.Version != storeFileVersion {
+		return nil, fmt.Errorf("trust store file %s has unsupported version %d", path, raw.Version)
+	}
+	for dir, digest := range raw.Dirs {
+		s.dirs[dir] = digest
+	}
+	for _, key := range raw.Keys {
+		s.keys[key] = struct{}{}
+	}
+	return s, nil
+}
+
+// TrustDir records that the given directory is trusted with its current
+// contents. If any of its files change later then it must be trusted again.
+func (s *Store) TrustDir(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	digest, err := Digest(dir)
+	if err != nil {
+		return err
+	}
+	s.dirs[dir] = digest
+	return nil
+}
+
+// RemoveDir forgets that the given directory was trusted, returning false
+// if it wasn't.
+func (s *Store) RemoveDir(dir string) bool {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	_, ok := s.dirs[dir]
+	delete(s.dirs, dir)
+	return ok
+}
+
+// TrustKey records that directories signed by the given key are trusted.
+func (s *Store) TrustKey(pub ed25519.PublicKey) {
+	s.keys[EncodePublicKey(pub)] = struct{}{}
+}
+
+// RemoveKey forgets that the given key was trusted, returning false if it
+// wasn't.
+func (s *Store) RemoveKey(pub ed25519.PublicKey) bool {
+	k := EncodePublicKey(pub)
+	_, ok := s.keys[k]
+	delete(s.keys, k)
+	return ok
+}
+
+// Check returns nil if the given directory is trusted, either because it
+// was trusted with exactly its current contents or because it has a valid
+// signature by a trusted key.
+//
+// If the directory isn't trusted then the error is usually an
+// *UntrustedError saying why, but may be some other error if the directory
+// couldn't be read.
+func (s *Store) Check(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	want, recorded := s.dirs[dir]
+	if recorded {
+		got, err := Digest(dir)
+		if err != nil {
+			return err
+		}
+		if got == want {
+			return nil
+		}
+	}
+
+	pub, err := VerifySignature(dir)
+	switch {
+	case err == nil:
+		if _, ok := s.keys[EncodePublicKey(pub)]; ok {
+			return nil
+		}
+		return &UntrustedError{
+			Dir:    dir,
+			Reason: fmt.Sprintf("is signed by the key %s, which has not been trusted", EncodePublicKey(pub)),
+		}
+	case err != ErrNotSigned:
+		return &UntrustedError{
+			Dir:    dir,
+			Reason: fmt.Sprintf("has an invalid signature: %s", err),
+		}
+	case recorded:
+		return &UntrustedError{
+			Dir:    dir,
+			Reason: "has changed since it was trusted",
+		}
+	default:
+		return &UntrustedError{
+			Dir:    dir,
+			Reason: "has not been trusted",
+		}
+	}
+}
+
+// Save writes the current contents of the store to disk, replacing the
+// previous file, if any.
+func (s *Store) Save() error {
+	raw := storeFile{
+		Version: storeFileVersion,
+		Dirs:    s.dirs,
+	}
+	for key := range s.keys {
+		raw.Keys = append(raw.Keys, key)
+	}
+	sort.Strings(raw.Keys)
+	src, err := json.MarshalIndent(&raw, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	// We write to a temporary file and then rename it into place so that
+	// a failure part way through can't leave the store corrupted.
+	f, err := ioutil.TempFile(dir, ".trust-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(src, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
@@ -0,0 +1,143 @@
+package trust
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configDir := filepath.Join(dir, "project", ".envy")
+	writeFile := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(configDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("commands.nv.hcl", `command "deploy" {}`)
+	writeFile("scripts/deploy.sh", "#!/bin/sh\n")
+
+	storePath := filepath.Join(dir, "data", "trust.json")
+	store, err := OpenStore(storePath)
+	if err != nil {
+		t.Fatalf("unexpected error opening missing store: %s", err)
+	}
+	wantUntrusted := func(t *testing.T, store *Store, reason string) {
+		t.Helper()
+		err := store.Check(configDir)
+		if err == nil {
+			t.Fatalf("unexpected success; want %q", reason)
+		}
+		untrusted, ok := err.(*UntrustedError)
+		if !ok {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.HasPrefix(untrusted.Reason, reason) {
+			t.Errorf("wrong reason %q; want %q", untrusted.Reason, reason)
+		}
+	}
+	wantUntrusted(t, store, "has not been trusted")
+
+	t.Run("trusted directory", func(t *testing.T) {
+		if err := store.TrustDir(configDir); err != nil {
+			t.Fatalf("unexpected error trusting directory: %s", err)
+		}
+		if err := store.Save(); err != nil {
+			t.Fatalf("unexpected error saving store: %s", err)
+		}
+		store, err := OpenStore(storePath)
+		if err != nil {
+			t.Fatalf("unexpected error reopening store: %s", err)
+		}
+		if err := store.Check(configDir); err != nil {
+			t.Fatalf("unexpected error for trusted directory: %s", err)
+		}
+
+		writeFile("scripts/deploy.sh", "#!/bin/sh\nrm -rf ~\n")
+		wantUntrusted(t, store, "has changed since it was trusted")
+
+		if !store.RemoveDir(configDir) {
+			t.Errorf("directory was not removed")
+		}
+		wantUntrusted(t, store, "has not been trusted")
+	})
+
+	t.Run("signed directory", func(t *testing.T) {
+		store, err := OpenStore(filepath.Join(dir, "data", "trust-keys.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := GenerateKeyFile(filepath.Join(dir, "data", "signing.key"))
+		if err != nil {
+			t.Fatalf("unexpected error generating key: %s", err)
+		}
+		if err := Sign(configDir, key); err != nil {
+			t.Fatalf("unexpected error signing: %s", err)
+		}
+		wantUntrusted(t, store, "is signed by the key")
+
+		key, err = ReadKeyFile(filepath.Join(dir, "data", "signing.key"))
+		if err != nil {
+			t.Fatalf("unexpected error reading key: %s", err)
+		}
+		pub, err := VerifySignature(configDir)
+		if err != nil {
+			t.Fatalf("unexpected error verifying signature: %s", err)
+		}
+		if got, want := EncodePublicKey(pub), EncodePublicKey(key.Public().(ed25519.PublicKey)); got != want {
+			t.Errorf("wrong public key %s; want %s", got, want)
+		}
+		store.TrustKey(pub)
+		if err := store.Check(configDir); err != nil {
+			t.Fatalf("unexpected error for signed directory: %s", err)
+		}
+
+		writeFile("extra.nv.hcl", `command "exfiltrate" {}`)
+		wantUntrusted(t, store, "has an invalid signature")
+	})
+}
+
+func TestManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "envy-trust")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"b.nv.hcl":        "",
+		"sub/a.txt":       "hello\n",
+		SignatureFileName: "{}",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Manifest(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855  b.nv.hcl\n" +
+		"5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03  sub/a.txt\n"
+	if string(got) != want {
+		t.Errorf("wrong manifest\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -6,8 +6,13 @@ package main
 
 import (
 	"envy.pw/cli/internal/cmd"
+	"envy.pw/cli/internal/runs"
 )
 
 func main() {
+	// When envy launches a copy of itself to set up a sandboxed child
+	// process, this replaces the copy with the child's program.
+	runs.RunChildSetup()
+
 	cmd.Execute()
 }